@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -449,6 +450,66 @@ func main() {
 					}
 				},
 			},
+			{
+				Name:        "routes",
+				Usage:       "Lists the routes of a Nova application",
+				Description: "Runs the routes command of the application in the given package, which it adds with nova.RoutesCommand, and prints every registered route as a table or as JSON.",
+				Flags: []nova.Flag{
+					&nova.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Default: "table",
+						Usage:   "Output format: table or json",
+					},
+					&nova.StringFlag{
+						Name:    "package",
+						Aliases: []string{"p"},
+						Default: ".",
+						Usage:   "Main package of the application, e.g. ./cmd/myapp",
+					},
+				},
+				Action: func(ctx *nova.Context) error {
+					cmd := exec.Command("go", "run", ctx.String("package"), "routes", "--format", ctx.String("format"))
+					cmd.Stdout = os.Stdout
+					cmd.Stderr = os.Stderr
+					if err := cmd.Run(); err != nil {
+						return fmt.Errorf("failed to list routes (does the application add nova.RoutesCommand?): %w", err)
+					}
+					return nil
+				},
+			},
+			{
+				Name:        "i18n",
+				Usage:       "Reports missing keys in translation catalogs",
+				Description: "Reads the JSON and TOML message catalogs in a directory, as loaded by nova.LoadMessages, and lists the keys each locale lacks compared to the others. Keys inherited from a less specific locale, such as pt for pt-BR, count as present. Exits with an error if any key is missing.",
+				Flags: []nova.Flag{
+					&nova.StringFlag{
+						Name:    "dir",
+						Aliases: []string{"d"},
+						Default: "locales",
+						Usage:   "Directory containing the catalogs",
+					},
+				},
+				Action: func(ctx *nova.Context) error {
+					dir := ctx.String("dir")
+					missing, err := nova.MissingMessages(os.DirFS(dir), ".")
+					if err != nil {
+						return err
+					}
+					if len(missing) == 0 {
+						fmt.Printf("All catalogs in %s are complete.\n", dir)
+						return nil
+					}
+					locales := slices.Sorted(maps.Keys(missing))
+					for _, locale := range locales {
+						fmt.Printf("%s is missing %d keys:\n", locale, len(missing[locale]))
+						for _, key := range missing[locale] {
+							fmt.Printf("  %s\n", key)
+						}
+					}
+					return fmt.Errorf("%d catalogs in %s have missing keys", len(missing), dir)
+				},
+			},
 			{
 				Name:        "new",
 				Aliases:     []string{"n"},
@@ -492,7 +553,10 @@ func main() {
 		log.Fatalf("Failed to initialize CLI: %v", err)
 	}
 
-	fmt.Println(logo)
+	// The route listing may be piped into other tools, so it is printed alone.
+	if len(os.Args) < 2 || os.Args[1] != "routes" {
+		fmt.Println(logo)
+	}
 	err = cli.Run(os.Args)
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,149 @@
+package nova
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// HTTPError is an error that carries an HTTP status code and a message that is
+// safe to show to clients. Return it from a HandlerFunc to control the response
+// sent by the router's error handler.
+type HTTPError struct {
+	// Status is the HTTP status code sent to the client.
+	Status int
+	// Message is the public error message sent to the client.
+	// Defaults to the status text of Status when empty.
+	Message string
+	// Err is the internal cause. It is logged but never sent to the client.
+	Err error
+	// Details holds optional extra information sent to the client, such as a
+	// list of field errors.
+	Details any
+
+	// fields holds the violations of a validation error, sent to the client
+	// as the "fields" member of JSON and problem details responses.
+	fields ValidationErrors
+}
+
+// NewHTTPError creates an HTTPError with the given status and public message.
+// An optional cause can be passed, which is logged but not exposed.
+func NewHTTPError(status int, message string, cause ...error) *HTTPError {
+	he := &HTTPError{Status: status, Message: message}
+	if len(cause) > 0 {
+		he.Err = cause[0]
+	}
+	return he
+}
+
+// Error returns the public message followed by the internal cause, if any.
+func (e *HTTPError) Error() string {
+	msg := e.publicMessage()
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+// Unwrap returns the internal cause so errors.Is and errors.As can inspect it.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// publicMessage returns Message, falling back to the status text.
+func (e *HTTPError) publicMessage() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return http.StatusText(e.Status)
+}
+
+// ErrorHandlerFunc handles an error returned by a HandlerFunc. It is responsible
+// for writing the response. See Router.SetErrorHandler.
+type ErrorHandlerFunc func(rc *ResponseContext, err error)
+
+// DefaultErrorHandler is the error handler used when none is set with
+// Router.SetErrorHandler. It maps errors to responses as follows:
+//   - ValidationErrors result in 422 Unprocessable Entity with every violation
+//     listed in the details. JSON responses also contain the violations keyed
+//     by field path in "fields", see ValidationErrors.MarshalJSON.
+//   - *HTTPError results in its status, public message and details.
+//   - *http.MaxBytesError, returned when reading a body limited by
+//     MaxRequestBodySizeMiddleware, results in 413 Request Entity Too Large.
+//   - Any other error results in 500 Internal Server Error.
+//
+// The response is JSON when the client wants JSON (see ResponseContext.WantsJSON)
+// and plain text otherwise. Internal causes and server errors are logged
+// through slog and never sent to the client: server errors at error level,
+// causes of 4xx errors at debug level.
+func DefaultErrorHandler(rc *ResponseContext, err error) {
+	he := resolveError(rc, err)
+
+	if rc.WantsJSON() {
+		body := map[string]any{"error": he.publicMessage()}
+		if he.Details != nil {
+			body["details"] = he.Details
+		}
+		if he.fields != nil {
+			body["fields"] = he.fields
+		}
+		_ = rc.JSON(he.Status, body)
+		return
+	}
+
+	text := he.publicMessage()
+	if msgs, ok := he.Details.([]string); ok {
+		text += "\n" + strings.Join(msgs, "\n")
+	}
+	http.Error(rc.w, text, he.Status)
+}
+
+// resolveError maps err to the HTTPError that describes the response and logs
+// internal causes and server errors: server errors at error level, causes of
+// client errors at debug level.
+func resolveError(rc *ResponseContext, err error) *HTTPError {
+	var (
+		ve  ValidationErrors
+		he  *HTTPError
+		mbe *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &ve):
+		msgs := make([]string, len(ve))
+		for i, e := range ve {
+			msgs[i] = e.Error()
+		}
+		he = &HTTPError{
+			Status:  http.StatusUnprocessableEntity,
+			Message: "Validation failed",
+			Details: msgs,
+			fields:  ve,
+		}
+	case errors.As(err, &he):
+	case errors.As(err, &mbe):
+		he = &HTTPError{Status: http.StatusRequestEntityTooLarge}
+	default:
+		he = &HTTPError{Status: http.StatusInternalServerError, Err: err}
+	}
+
+	switch {
+	case he.Status >= http.StatusInternalServerError:
+		slog.Error("Handler error",
+			"method", rc.r.Method,
+			"path", rc.r.URL.Path,
+			"status", he.Status,
+			"error", err,
+		)
+	case he.Err != nil:
+		// Client errors are expected; their causes only help when debugging.
+		slog.Debug("Handler error",
+			"method", rc.r.Method,
+			"path", rc.r.URL.Path,
+			"status", he.Status,
+			"error", err,
+		)
+	}
+	return he
+}
@@ -0,0 +1,178 @@
+package nova
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestHTTPErrorMessage tests that HTTPError falls back to the status text and
+// includes the internal cause only in Error, not in the public message.
+func TestHTTPErrorMessage(t *testing.T) {
+	cause := errors.New("db down")
+	he := NewHTTPError(http.StatusServiceUnavailable, "", cause)
+	if got := he.Error(); got != "Service Unavailable: db down" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := he.publicMessage(); got != "Service Unavailable" {
+		t.Errorf("publicMessage() = %q", got)
+	}
+	if !errors.Is(he, cause) {
+		t.Error("errors.Is(he, cause) = false; want true")
+	}
+}
+
+// TestDefaultErrorHandler tests how the default error handler maps errors to
+// status codes and negotiates between JSON and plain text.
+func TestDefaultErrorHandler(t *testing.T) {
+	cases := []struct {
+		name       string
+		err        error
+		accept     string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "plain error",
+			err:        errors.New("secret internals"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   "Internal Server Error",
+		},
+		{
+			name:       "http error json",
+			err:        &HTTPError{Status: http.StatusNotFound, Message: "user not found", Details: map[string]string{"id": "7"}},
+			accept:     "application/json",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"details":{"id":"7"},"error":"user not found"}`,
+		},
+		{
+			name:       "validation errors json",
+			err:        ValidationErrors{&FieldError{Field: "/name", Code: "required", Message: "Field 'name' is required"}},
+			accept:     "application/json",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"details":["Field 'name' is required"],"error":"Validation failed","fields":{"/name":[{"code":"required","message":"Field 'name' is required"}]}}`,
+		},
+		{
+			name:       "body too large",
+			err:        &http.MaxBytesError{Limit: 4},
+			wantStatus: http.StatusRequestEntityTooLarge,
+			wantBody:   "Request Entity Too Large",
+		},
+		{
+			name:       "wrapped validation errors text",
+			err:        errors.Join(errors.New("validation failed"), ValidationErrors{errors.New("Field 'age' must be at least 18")}),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   "Validation failed\nField 'age' must be at least 18",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := NewRouter()
+			r.GetFunc("/", func(rc *ResponseContext) error { return c.err })
+			req := httptest.NewRequest("GET", "/", nil)
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != c.wantStatus {
+				t.Errorf("status = %d; want %d", rr.Code, c.wantStatus)
+			}
+			if got := strings.TrimSpace(rr.Body.String()); got != c.wantBody {
+				t.Errorf("body = %q; want %q", got, c.wantBody)
+			}
+		})
+	}
+}
+
+// TestBindValidatedErrorStatus tests that malformed JSON passed through
+// BindValidated is answered with 400 Bad Request.
+func TestBindValidatedErrorStatus(t *testing.T) {
+	type input struct {
+		Name string `json:"name"`
+	}
+	r := NewRouter()
+	r.PostFunc("/", func(rc *ResponseContext) error {
+		var in input
+		if err := rc.BindValidated(&in); err != nil {
+			return err
+		}
+		return rc.JSON(http.StatusOK, in)
+	})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("{bad"))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusBadRequest)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body["error"] != "invalid JSON" {
+		t.Errorf("body = %q; want JSON error \"invalid JSON\"", rr.Body.String())
+	}
+}
+
+// TestSetErrorHandler tests that a custom error handler receives errors from
+// router, group and subrouter handlers.
+func TestSetErrorHandler(t *testing.T) {
+	r := NewRouter()
+	r.SetErrorHandler(func(rc *ResponseContext, err error) {
+		rc.Text(http.StatusTeapot, "handled: "+err.Error())
+	})
+	fail := func(rc *ResponseContext) error { return errors.New("boom") }
+	r.GetFunc("/a", fail)
+	r.Group("/g").GetFunc("/b", fail)
+	r.Subrouter("/sub").GetFunc("/c", fail)
+
+	for _, path := range []string{"/a", "/g/b", "/sub/c"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusTeapot || rr.Body.String() != "handled: boom" {
+			t.Errorf("GET %s = %d,%q; want %d,%q", path, rr.Code, rr.Body.String(),
+				http.StatusTeapot, "handled: boom")
+		}
+	}
+}
+
+// TestResolveErrorLogLevel tests that causes of client errors are logged at
+// debug level and server errors at error level.
+func TestResolveErrorLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{NewHTTPError(http.StatusBadRequest, "bad input", errors.New("parse failure")), "level=DEBUG"},
+		{NewHTTPError(http.StatusBadGateway, "upstream down", errors.New("dial failure")), "level=ERROR"},
+		{errors.New("boom"), "level=ERROR"},
+	}
+	for _, c := range cases {
+		buf.Reset()
+		r := NewRouter()
+		r.GetFunc("/", func(rc *ResponseContext) error { return c.err })
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if !strings.Contains(buf.String(), c.want) {
+			t.Errorf("%v: log = %q; want %s", c.err, buf.String(), c.want)
+		}
+	}
+
+	buf.Reset()
+	r := NewRouter()
+	r.GetFunc("/", func(rc *ResponseContext) error { return NewHTTPError(http.StatusNotFound, "not found") })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if buf.Len() != 0 {
+		t.Errorf("4xx without cause logged %q", buf.String())
+	}
+}
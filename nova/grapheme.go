@@ -0,0 +1,129 @@
+package nova
+
+import "unicode"
+
+// graphemeClass is the grapheme cluster break property of a rune, as far as
+// graphemeCount distinguishes it.
+type graphemeClass uint8
+
+const (
+	gcOther graphemeClass = iota
+	gcCR
+	gcLF
+	gcControl
+	gcExtend
+	gcZWJ
+	gcSpacingMark
+	gcPrepend
+	gcRegional
+	gcPictographic
+	gcL
+	gcV
+	gcT
+	gcLV
+	gcLVT
+)
+
+// graphemeCount returns the number of user-perceived characters in s. It
+// approximates the extended grapheme clusters of UAX #29 without the Unicode
+// data files, deriving the break properties from the standard library tables
+// (see classifyGrapheme), and implements every rule except GB9c: Indic conjunct
+// clusters such as "क्षि" count as more than one character. Combining marks,
+// Hangul syllables, flags and emoji ZWJ sequences such as "👨‍👩‍👧" count as one.
+func graphemeCount(s string) int {
+	n, regional, emoji := 0, 0, false
+	prev := gcOther
+	for _, r := range s {
+		cur := classifyGrapheme(r)
+		if n == 0 || graphemeBreak(prev, cur, regional, emoji) {
+			n++
+		}
+		if cur == gcRegional {
+			regional++
+		} else {
+			regional = 0
+		}
+		switch cur {
+		case gcPictographic:
+			emoji = true
+		case gcExtend, gcZWJ:
+		default:
+			emoji = false
+		}
+		prev = cur
+	}
+	return n
+}
+
+// graphemeBreak reports whether there is a grapheme cluster boundary between
+// runes of class prev and cur. regional is the number of regional indicators
+// directly before cur and emoji whether prev ends a pictographic rune followed
+// by extenders.
+func graphemeBreak(prev, cur graphemeClass, regional int, emoji bool) bool {
+	switch {
+	case prev == gcCR && cur == gcLF:
+		return false
+	case prev == gcCR || prev == gcLF || prev == gcControl:
+		return true
+	case cur == gcCR || cur == gcLF || cur == gcControl:
+		return true
+	case prev == gcL && (cur == gcL || cur == gcV || cur == gcLV || cur == gcLVT):
+		return false
+	case (prev == gcLV || prev == gcV) && (cur == gcV || cur == gcT):
+		return false
+	case (prev == gcLVT || prev == gcT) && cur == gcT:
+		return false
+	case cur == gcExtend || cur == gcZWJ || cur == gcSpacingMark:
+		return false
+	case prev == gcPrepend:
+		return false
+	case prev == gcZWJ && cur == gcPictographic && emoji:
+		return false
+	case prev == gcRegional && cur == gcRegional && regional%2 == 1:
+		return false
+	}
+	return true
+}
+
+// classifyGrapheme returns the grapheme cluster break class of r. The classes
+// are approximations of the Grapheme_Cluster_Break property built from the
+// standard library tables, which follow its Unicode version rather than the
+// data files: Extended_Pictographic is the emoji and symbol blocks, Prepend
+// only the prepended concatenation marks, and SpacingMark every Mc rune.
+func classifyGrapheme(r rune) graphemeClass {
+	switch {
+	case r == '\r':
+		return gcCR
+	case r == '\n':
+		return gcLF
+	case r == '\u200d':
+		return gcZWJ
+	case r == '\u200c', r >= 0x1F3FB && r <= 0x1F3FF, r >= 0xE0020 && r <= 0xE007F,
+		unicode.In(r, unicode.Mn, unicode.Me, unicode.Other_Grapheme_Extend):
+		return gcExtend
+	case unicode.Is(unicode.Prepended_Concatenation_Mark, r):
+		return gcPrepend
+	case unicode.In(r, unicode.Cc, unicode.Zl, unicode.Zp, unicode.Cf):
+		return gcControl
+	case unicode.Is(unicode.Mc, r):
+		return gcSpacingMark
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return gcRegional
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return gcL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return gcV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return gcT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return gcLV
+		}
+		return gcLVT
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139,
+		r >= 0x2190 && r <= 0x21FF, r >= 0x2300 && r <= 0x23FF, r >= 0x2600 && r <= 0x27BF,
+		r >= 0x2B00 && r <= 0x2BFF, r >= 0x1F000 && r <= 0x1FAFF:
+		return gcPictographic
+	}
+	return gcOther
+}
@@ -0,0 +1,513 @@
+package nova
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultLanguage is the language used when negotiation finds no match, and
+// the last fallback of every message lookup.
+const defaultLanguage = "en"
+
+// RegisterMessages adds the messages of a catalog for the given BCP 47
+// language tag, e.g. "pt-BR", replacing existing messages with the same keys.
+// Keys are either validation keys such as "required" or application keys
+// looked up with Translate. Messages are fmt format strings. RegisterMessages
+// panics if lang is not a well-formed language tag.
+func RegisterMessages(lang string, messages map[string]string) {
+	tag, ok := canonicalLanguage(lang)
+	if !ok {
+		panic(fmt.Sprintf("RegisterMessages: invalid language tag %q", lang))
+	}
+	validationMu.Lock()
+	defer validationMu.Unlock()
+	if validationMessages[tag] == nil {
+		validationMessages[tag] = make(map[string]string, len(messages))
+	}
+	for key, template := range messages {
+		validationMessages[tag][key] = template
+	}
+}
+
+// LoadMessages registers the catalogs in the directory dir of fsys, such as an
+// embed.FS. Every file named after a language tag with a .json or .toml
+// extension is a catalog, e.g. "en.json" or "pt-BR.toml"; other files are
+// ignored. JSON catalogs are objects with string values, TOML catalogs
+// "key = value" lines. Nested objects and TOML tables prefix their keys with
+// their name and a dot:
+//
+//	# nl.toml
+//	required = "Veld '%s' is verplicht"
+//
+//	[greeting]
+//	hello = "Hallo %s"    # looked up as "greeting.hello"
+//
+// Nothing is registered if a catalog cannot be read or parsed.
+func LoadMessages(fsys fs.FS, dir string) error {
+	catalogs, err := readCatalogs(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for lang, messages := range catalogs {
+		RegisterMessages(lang, messages)
+	}
+	return nil
+}
+
+// MissingMessages reads the catalogs in the directory dir of fsys like
+// LoadMessages and reports, per language tag, the keys that other catalogs
+// define but that the catalog neither defines nor inherits from a less
+// specific one, e.g. "pt-BR" from "pt". The keys are sorted; complete
+// catalogs are left out.
+func MissingMessages(fsys fs.FS, dir string) (map[string][]string, error) {
+	catalogs, err := readCatalogs(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool)
+	for _, messages := range catalogs {
+		for key := range messages {
+			keys[key] = true
+		}
+	}
+
+	missing := make(map[string][]string)
+	for lang := range catalogs {
+		for key := range keys {
+			found := false
+			for _, tag := range languageFallbacks(lang) {
+				if _, found = catalogs[tag][key]; found {
+					break
+				}
+			}
+			if !found {
+				missing[lang] = append(missing[lang], key)
+			}
+		}
+		slices.Sort(missing[lang])
+	}
+	return missing, nil
+}
+
+// Translate returns the message for key in lang, formatted with args. The
+// lookup falls back to less specific tags and then to English, e.g. "pt-BR",
+// "pt", "en". If no catalog has the key, the key itself is returned.
+func Translate(lang, key string, args ...any) string {
+	template, ok := lookupMessage(lang, key)
+	if !ok {
+		return key
+	}
+	return fmt.Sprintf(template, args...)
+}
+
+// Language returns the language for the request, negotiated from its
+// Accept-Language header against the registered catalogs. It is the language
+// of validation messages and of T.
+func (rc *ResponseContext) Language() string {
+	return detectLanguage(rc.r.Header.Get("Accept-Language"))
+}
+
+// T translates key into the language of the request. See Translate.
+func (rc *ResponseContext) T(key string, args ...any) string {
+	return Translate(rc.Language(), key, args...)
+}
+
+// getMessage formats the validation message for key in lang with args, with
+// the field name first. It falls back to a generic message naming the field.
+func getMessage(lang, key string, args ...any) string {
+	if template, ok := lookupMessage(lang, key); ok {
+		return fmt.Sprintf(template, args...)
+	}
+	return fmt.Sprintf("Validation error for field '%v'", args[0])
+}
+
+// lookupMessage returns the template for key from the first catalog in the
+// fallback chain of lang that defines it.
+func lookupMessage(lang, key string) (string, bool) {
+	validationMu.RLock()
+	defer validationMu.RUnlock()
+	chain := languageFallbacks(lang)
+	if !slices.Contains(chain, defaultLanguage) {
+		chain = append(chain, defaultLanguage)
+	}
+	for _, tag := range chain {
+		if template, ok := validationMessages[tag][key]; ok {
+			return template, true
+		}
+	}
+	return "", false
+}
+
+// detectLanguage selects the registered language that best matches an
+// Accept-Language header. Language ranges are tried in order of their q-value,
+// each with its fallback chain as in RFC 4647 lookup, so "pt-BR" matches a
+// "pt" catalog. It returns "en" if nothing matches.
+func detectLanguage(acceptLanguage string) string {
+	type languageRange struct {
+		tag string
+		q   float64
+	}
+	var ranges []languageRange
+	for part := range strings.SplitSeq(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		lr := languageRange{tag: strings.TrimSpace(fields[0]), q: 1}
+		for _, param := range fields[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+				lr.q = q
+			}
+		}
+		if lr.q > 0 && lr.tag != "" {
+			ranges = append(ranges, lr)
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	validationMu.RLock()
+	defer validationMu.RUnlock()
+	for _, lr := range ranges {
+		if lr.tag == "*" {
+			break
+		}
+		for _, tag := range languageFallbacks(lr.tag) {
+			if _, exists := validationMessages[tag]; exists {
+				return tag
+			}
+		}
+	}
+	return defaultLanguage
+}
+
+// languageFallbacks returns the canonical form of the language tag lang
+// followed by its less specific prefixes, e.g. "zh-Hant-TW", "zh-Hant", "zh".
+// It returns nil for a malformed tag.
+func languageFallbacks(lang string) []string {
+	tag, ok := canonicalLanguage(lang)
+	if !ok {
+		return nil
+	}
+	subtags := strings.Split(tag, "-")
+	var chain []string
+	for n := len(subtags); n > 0; n-- {
+		// A singleton such as "x" in "en-x-pirate" is never left at the end.
+		if len(subtags[n-1]) == 1 && n > 1 {
+			continue
+		}
+		chain = append(chain, strings.Join(subtags[:n], "-"))
+	}
+	return chain
+}
+
+// canonicalLanguage returns the BCP 47 language tag lang in its canonical
+// case: the language lowercase, a script titlecase and a region uppercase,
+// e.g. "zh-Hant-TW" for "ZH_hant_tw". ok is false if lang is malformed. Only
+// the syntax is checked, without the IANA subtag registry, so the package needs
+// no Unicode data; aliases such as "iw" for "he" are kept as is.
+func canonicalLanguage(lang string) (string, bool) {
+	subtags := strings.FieldsFunc(lang, func(r rune) bool { return r == '-' || r == '_' })
+	if len(subtags) == 0 || len(subtags) != strings.Count(lang, "-")+strings.Count(lang, "_")+1 {
+		return "", false
+	}
+	extension := false
+	for i, s := range subtags {
+		if len(s) > 8 || !isAlphanumeric(s) || (i == 0 && (len(s) < 2 || !isAlpha(s))) {
+			return "", false
+		}
+		s = strings.ToLower(s)
+		// Subtags after a singleton belong to an extension and stay lowercase.
+		extension = extension || len(s) == 1
+		if i > 0 && !extension {
+			switch {
+			case len(s) == 4 && isAlpha(s):
+				s = strings.ToUpper(s[:1]) + s[1:]
+			case len(s) == 2 && isAlpha(s), len(s) == 3 && isNumeric(s):
+				s = strings.ToUpper(s)
+			}
+		}
+		subtags[i] = s
+	}
+	return strings.Join(subtags, "-"), true
+}
+
+// isAlpha reports whether s consists of ASCII letters only, as the alpha
+// subtags of a language tag do.
+func isAlpha(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') }) < 0
+}
+
+// isNumeric reports whether s consists of ASCII digits only.
+func isNumeric(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }) < 0
+}
+
+// isAlphanumeric reports whether s consists of ASCII letters and digits only.
+func isAlphanumeric(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+	}) < 0
+}
+
+// readCatalogs parses the catalogs in the directory dir of fsys, keyed by
+// canonical language tag.
+func readCatalogs(fsys fs.FS, dir string) (map[string]map[string]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading catalogs: %w", err)
+	}
+	catalogs := make(map[string]map[string]string)
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".json" && ext != ".toml") {
+			continue
+		}
+		tag, ok := canonicalLanguage(strings.TrimSuffix(entry.Name(), ext))
+		if !ok {
+			continue
+		}
+		file := path.Join(dir, entry.Name())
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("reading catalog %s: %w", file, err)
+		}
+		messages := make(map[string]string)
+		if ext == ".json" {
+			err = parseJSONCatalog(data, messages)
+		} else {
+			err = parseTOMLCatalog(data, messages)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing catalog %s: %w", file, err)
+		}
+		if catalogs[tag] != nil {
+			return nil, fmt.Errorf("catalog %s: duplicate catalog for %s", file, tag)
+		}
+		catalogs[tag] = messages
+	}
+	return catalogs, nil
+}
+
+// parseJSONCatalog adds the messages of a JSON catalog to messages, joining
+// the keys of nested objects with dots.
+func parseJSONCatalog(data []byte, messages map[string]string) error {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	var flatten func(prefix string, obj map[string]any) error
+	flatten = func(prefix string, obj map[string]any) error {
+		for key, value := range obj {
+			switch v := value.(type) {
+			case string:
+				messages[prefix+key] = v
+			case map[string]any:
+				if err := flatten(prefix+key+".", v); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("key %q: message must be a string", prefix+key)
+			}
+		}
+		return nil
+	}
+	return flatten("", root)
+}
+
+// parseTOMLCatalog adds the messages of a TOML catalog to messages. It
+// supports the subset of TOML 1.0 a catalog needs: comments, [table] headers,
+// bare, quoted and dotted keys, and single-line basic and literal strings with
+// the escapes of unescapeTOML. Multi-line strings, arrays, inline tables and
+// values other than strings are rejected.
+func parseTOMLCatalog(data []byte, messages map[string]string) error {
+	prefix := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") {
+				return fmt.Errorf("line %d: arrays of tables are not supported", lineNo)
+			}
+			table, rest, err := parseTOMLKey(line[1:])
+			if err == nil && !strings.HasPrefix(rest, "]") {
+				err = fmt.Errorf("invalid table header")
+			}
+			if err == nil {
+				err = checkTOMLTrailer(rest[1:])
+			}
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			prefix = table + "."
+			continue
+		}
+
+		key, rest, err := parseTOMLKey(line)
+		if err == nil && !strings.HasPrefix(rest, "=") {
+			err = fmt.Errorf("expected key = value")
+		}
+		var value string
+		if err == nil {
+			value, err = parseTOMLString(strings.TrimSpace(rest[1:]))
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		messages[prefix+key] = value
+	}
+	return scanner.Err()
+}
+
+// parseTOMLKey parses the possibly dotted TOML key at the start of s. It
+// returns the key parts joined by dots and the rest of s after the key and
+// any following whitespace.
+func parseTOMLKey(s string) (key, rest string, err error) {
+	var parts []string
+	for {
+		s = strings.TrimSpace(s)
+		var part string
+		if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+			end := tomlStringEnd(s)
+			if end < 0 {
+				return "", "", fmt.Errorf("unterminated key %s", s)
+			}
+			if part, err = parseTOMLString(s[:end]); err != nil {
+				return "", "", err
+			}
+			s = s[end:]
+		} else {
+			end := strings.IndexFunc(s, func(r rune) bool {
+				return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' && r != '-'
+			})
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return "", "", fmt.Errorf("invalid key at %q", s)
+			}
+			part, s = s[:end], s[end:]
+		}
+		parts = append(parts, part)
+
+		s = strings.TrimSpace(s)
+		if !strings.HasPrefix(s, ".") {
+			return strings.Join(parts, "."), s, nil
+		}
+		s = s[1:]
+	}
+}
+
+// tomlStringEnd returns the index just past the basic or literal string at the
+// start of s, or -1 if it is not terminated.
+func tomlStringEnd(s string) int {
+	if s[0] == '\'' {
+		if end := strings.IndexByte(s[1:], '\''); end >= 0 {
+			return end + 2
+		}
+		return -1
+	}
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// parseTOMLString parses a basic ("...") or literal ('...') TOML string,
+// optionally followed by a comment.
+func parseTOMLString(raw string) (string, error) {
+	if !strings.HasPrefix(raw, `"`) && !strings.HasPrefix(raw, "'") {
+		return "", fmt.Errorf("expected a string, got %q", raw)
+	}
+	end := tomlStringEnd(raw)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string %s", raw)
+	}
+	value := raw[1 : end-1]
+	if strings.ContainsFunc(value, func(r rune) bool { return r < ' ' && r != '\t' || r == 0x7f }) {
+		return "", fmt.Errorf("control character in string %s", raw[:end])
+	}
+	if raw[0] == '"' {
+		var err error
+		if value, err = unescapeTOML(value); err != nil {
+			return "", fmt.Errorf("string %s: %w", raw[:end], err)
+		}
+	}
+	return value, checkTOMLTrailer(raw[end:])
+}
+
+// unescapeTOML replaces the escape sequences of a TOML 1.0 basic string: \b,
+// \t, \n, \f, \r, \", \\, \uXXXX and \UXXXXXXXX. Any other escape, and a
+// \u or \U escape that is not a Unicode scalar value, is an error.
+func unescapeTOML(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i++; i == len(s) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		switch c := s[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(c)
+		case 'u', 'U':
+			n := 4
+			if c == 'U' {
+				n = 8
+			}
+			if i+1+n > len(s) {
+				return "", fmt.Errorf("short escape \\%s", s[i:])
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:i+1+n])
+			}
+			b.WriteRune(rune(code))
+			i += n
+		default:
+			return "", fmt.Errorf("invalid escape \\%c", c)
+		}
+	}
+	return b.String(), nil
+}
+
+// checkTOMLTrailer reports an error if rest, the text after a value, is more
+// than a comment.
+func checkTOMLTrailer(rest string) error {
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return fmt.Errorf("unexpected %q after value", rest)
+	}
+	return nil
+}
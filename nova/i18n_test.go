@@ -0,0 +1,185 @@
+package nova
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var testCatalogs = fstest.MapFS{
+	"locales/pt.json": {Data: []byte(`{
+		"required": "O campo '%s' é obrigatório",
+		"greeting": {"hello": "Olá %s", "bye": "Tchau"}
+	}`)},
+	"locales/pt-BR.toml": {Data: []byte(`
+# Brazilian overrides
+[greeting]
+hello = "Oi %s" # informal
+"farewell.formal" = 'Até logo'
+`)},
+	"locales/zh_hant.toml": {Data: []byte(`greeting.hello = "你好 %s"`)},
+	"locales/README.md":    {Data: []byte("not a catalog")},
+}
+
+// TestLoadMessages tests loading JSON and TOML catalogs and translating with
+// fallback chains.
+func TestLoadMessages(t *testing.T) {
+	if err := LoadMessages(testCatalogs, "locales"); err != nil {
+		t.Fatal(err)
+	}
+	RegisterMessages("en", map[string]string{"greeting.hello": "Hello %s"})
+
+	cases := []struct {
+		lang, key string
+		args      []any
+		want      string
+	}{
+		{"pt-BR", "greeting.hello", []any{"Ana"}, "Oi Ana"},
+		{"pt-br", "greeting.bye", nil, "Tchau"},
+		{"pt-BR", "greeting.farewell.formal", nil, "Até logo"},
+		{"pt-PT", "greeting.hello", []any{"Ana"}, "Olá Ana"},
+		{"zh-Hant-TW", "greeting.hello", []any{"Ana"}, "你好 Ana"},
+		{"zh", "greeting.hello", []any{"Ana"}, "Hello Ana"},
+		{"pt", "missing.key", nil, "missing.key"},
+	}
+	for _, c := range cases {
+		if got := Translate(c.lang, c.key, c.args...); got != c.want {
+			t.Errorf("Translate(%q, %q) = %q; want %q", c.lang, c.key, got, c.want)
+		}
+	}
+	if got := getMessage("pt-BR", "required", "nome"); got != "O campo 'nome' é obrigatório" {
+		t.Errorf("validation message = %q", got)
+	}
+	if got := getMessage("pt-BR", "email", "email"); got != "Field 'email' must be a valid email address" {
+		t.Errorf("English fallback = %q", got)
+	}
+
+	r := NewRouter()
+	r.GetFunc("/hello", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, rc.Language()+" "+rc.T("greeting.hello", "Ana"))
+	})
+	req := httptest.NewRequest("GET", "/hello", nil)
+	req.Header.Set("Accept-Language", "pt-BR,pt;q=0.9,en;q=0.8")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Body.String() != "pt-BR Oi Ana" {
+		t.Errorf("body = %q", rr.Body.String())
+	}
+
+	escapes := fstest.MapFS{"eo.toml": {Data: []byte(`
+tab = "a\tb\\n"
+quote = "\"\u00e9\U0001F600\""
+literal = 'C:\path\n'
+`)}}
+	if err := LoadMessages(escapes, "."); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"tab":     "a\tb\\n",
+		"quote":   "\"é😀\"",
+		"literal": `C:\path\n`,
+	} {
+		if got := Translate("eo", key); got != want {
+			t.Errorf("Translate(eo, %q) = %q; want %q", key, got, want)
+		}
+	}
+
+	for name, data := range map[string]string{
+		"json value":     `{"a": 1}`,
+		"toml string":    `a = 1`,
+		"toml table":     "[a",
+		"toml key":       `a b = "x"`,
+		"toml unclosed":  `a = "x`,
+		"toml trailing":  `a = "x" y`,
+		"toml array tbl": "[[a]]",
+		"toml escape":    `a = "\a"`,
+		"toml go escape": `a = "\x41"`,
+		"toml surrogate": `a = "\ud800"`,
+		"toml short":     `a = "\u00e"`,
+		"toml control":   "a = \"\x01\"",
+	} {
+		ext := ".toml"
+		if strings.HasPrefix(name, "json") {
+			ext = ".json"
+		}
+		fsys := fstest.MapFS{"fr" + ext: {Data: []byte(data)}}
+		if err := LoadMessages(fsys, "."); err == nil {
+			t.Errorf("%s: LoadMessages succeeded", name)
+		}
+	}
+}
+
+// TestDetectLanguage tests Accept-Language negotiation with q-values and
+// fallback chains.
+func TestDetectLanguage(t *testing.T) {
+	RegisterMessages("sv", map[string]string{})
+	RegisterMessages("zh-Hant", map[string]string{})
+	cases := map[string]string{
+		"":                              "en",
+		"nl":                            "nl",
+		"en-US":                         "en",
+		"sv-FI":                         "sv",
+		"SV_fi":                         "sv",
+		"zh-Hant-HK":                    "zh-Hant",
+		"zh-Hans":                       "en",
+		"ja, de;q=0.5":                  "de",
+		"de;q=0.5, fr;q=0.8":            "fr",
+		"fr;q=0, nl;q=0.1":              "nl",
+		"*, nl;q=0.5":                   "en",
+		"x-klingon, 1nvalid, es;q=0.2":  "es",
+		"fr-CA;q=0.9, de-CH;q=0.9, nl":  "nl",
+		"fr-CA;q=0.9, de-CH;q=0.95":     "de",
+		"en-u-ca-gregory;q=0.5, es;q=1": "es",
+	}
+	for header, want := range cases {
+		if got := detectLanguage(header); got != want {
+			t.Errorf("detectLanguage(%q) = %q; want %q", header, got, want)
+		}
+	}
+
+	for lang, want := range map[string]string{
+		"zh_hant_tw":      "zh-Hant-TW",
+		"ES-419":          "es-419",
+		"en-u-co-phonebk": "en-u-co-phonebk",
+	} {
+		if got, ok := canonicalLanguage(lang); !ok || got != want {
+			t.Errorf("canonicalLanguage(%q) = %q, %v; want %q", lang, got, ok, want)
+		}
+	}
+	for _, lang := range []string{"", "e", "en--US", "en-toolongsubtag", "1en"} {
+		if got, ok := canonicalLanguage(lang); ok {
+			t.Errorf("canonicalLanguage(%q) = %q; want malformed", lang, got)
+		}
+	}
+	if got := languageFallbacks("en-a-bbb-x-ccc"); !slices.Equal(got, []string{"en-a-bbb-x-ccc", "en-a-bbb", "en"}) {
+		t.Errorf("languageFallbacks() = %q", got)
+	}
+}
+
+// TestMissingMessages tests that keys missing from a catalog are reported
+// unless a less specific catalog provides them.
+func TestMissingMessages(t *testing.T) {
+	missing, err := MissingMessages(testCatalogs, "locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"pt":      {"greeting.farewell.formal"},
+		"zh-Hant": {"greeting.bye", "greeting.farewell.formal", "required"},
+	}
+	if len(missing) != len(want) {
+		t.Errorf("missing = %q; want %q", missing, want)
+	}
+	for lang, keys := range want {
+		if !slices.Equal(missing[lang], keys) {
+			t.Errorf("missing[%q] = %q; want %q", lang, missing[lang], keys)
+		}
+	}
+
+	if _, err := MissingMessages(testCatalogs, "nope"); err == nil {
+		t.Error("MissingMessages succeeded for a missing directory")
+	}
+}
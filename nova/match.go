@@ -0,0 +1,275 @@
+package nova
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// matcher holds the host and header conditions a request must meet to be
+// handled by a route or subrouter. A nil matcher accepts every request.
+type matcher struct {
+	// hostPattern is the host pattern as registered, e.g. "{tenant}.example.com".
+	hostPattern string
+	// host contains the compiled labels of hostPattern, or nil for any host.
+	host []segment
+	// headers contains the required request headers.
+	headers []headerCondition
+}
+
+// headerCondition requires a request header to be present, or to have a value.
+type headerCondition struct {
+	// name is the canonical header name.
+	name string
+	// value is the required value. Empty means any value.
+	value string
+	// mediaType reports whether value is a media type, which is compared
+	// without parameters.
+	mediaType bool
+}
+
+// compileHost compiles a host pattern such as "{tenant}.example.com" or
+// "{region:[a-z]+}.api.example.com". Each label may be a literal or a parameter.
+// Host names are case-insensitive: literal labels are lowercased, while regexes
+// are compiled as written with the (?i) flag, so "\D" and "[A-Z]" keep their
+// meaning.
+func compileHost(pattern string) ([]segment, error) {
+	pattern = strings.Trim(pattern, ".")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty host pattern")
+	}
+	segs, err := compileSegments(strings.Split(pattern, "."))
+	if err != nil {
+		return nil, err
+	}
+	for i, seg := range segs {
+		if seg.catchAll || seg.optional {
+			return nil, fmt.Errorf("host parameter %q cannot be a catch-all or optional", seg.paramName)
+		}
+		if seg.isLiteral() {
+			segs[i].literal = strings.ToLower(seg.literal)
+		}
+		if seg.regex != nil {
+			segs[i].regex, err = regexp.Compile("(?i)" + seg.regex.String())
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return segs, nil
+}
+
+// withHost returns a copy of m that also requires the host pattern. It panics if
+// the pattern is invalid.
+func (m *matcher) withHost(pattern string) *matcher {
+	host, err := compileHost(pattern)
+	if err != nil {
+		panic("invalid host pattern: " + err.Error())
+	}
+	c := m.clone()
+	c.hostPattern = pattern
+	c.host = host
+	return c
+}
+
+// withHeaders returns a copy of m that also requires the given headers.
+func (m *matcher) withHeaders(headers map[string]string) *matcher {
+	c := m.clone()
+	for name, value := range headers {
+		value = strings.TrimSpace(value)
+		c.headers = append(c.headers, headerCondition{
+			name:      http.CanonicalHeaderKey(name),
+			value:     value,
+			mediaType: isMediaType(value),
+		})
+	}
+	return c
+}
+
+// clone returns a copy of m, or an empty matcher if m is nil.
+func (m *matcher) clone() *matcher {
+	if m == nil {
+		return &matcher{}
+	}
+	c := *m
+	c.headers = append([]headerCondition(nil), m.headers...)
+	return &c
+}
+
+// equal reports whether m and o impose the same conditions.
+func (m *matcher) equal(o *matcher) bool {
+	if m == nil || o == nil {
+		return m == o
+	}
+	if !strings.EqualFold(strings.Trim(m.hostPattern, "."), strings.Trim(o.hostPattern, ".")) ||
+		len(m.headers) != len(o.headers) {
+		return false
+	}
+	for _, h := range m.headers {
+		if !slices.Contains(o.headers, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether req meets the conditions and returns the parameters
+// captured from the host.
+func (m *matcher) match(req *http.Request) (map[string]string, bool) {
+	if m == nil {
+		return nil, true
+	}
+	for _, h := range m.headers {
+		if !headerMatches(req.Header, h) {
+			return nil, false
+		}
+	}
+	if m.host == nil {
+		return nil, true
+	}
+
+	labels := strings.Split(strings.ToLower(requestHost(req)), ".")
+	if len(labels) != len(m.host) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, seg := range m.host {
+		if !seg.isLiteral() && labels[i] == "" {
+			return nil, false
+		}
+		if !seg.matches(labels[i]) {
+			return nil, false
+		}
+		if seg.isLiteral() {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		seg.capture(labels[i], params)
+	}
+	return params, true
+}
+
+// headerMatches reports whether the header is present and, if a value is
+// required, whether the header or one of its comma-separated elements equals it.
+// A media type is compared with the type of each element, ignoring q and other
+// parameters, so "application/json" matches "application/json; charset=utf-8";
+// Accept entries with a quality of 0 never match.
+func headerMatches(header http.Header, cond headerCondition) bool {
+	values := header.Values(cond.name)
+	if cond.value == "" {
+		return len(values) > 0
+	}
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), cond.value) {
+			return true
+		}
+		if cond.mediaType {
+			for _, mr := range parseAccept(v) {
+				if mr.q > 0 && strings.EqualFold(mr.typ+"/"+mr.subtype, cond.value) {
+					return true
+				}
+			}
+			continue
+		}
+		for elem := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(elem), cond.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isMediaType reports whether v is a media type without parameters, such as
+// "application/json".
+func isMediaType(v string) bool {
+	typ, subtype, ok := strings.Cut(v, "/")
+	return ok && typ != "" && subtype != "" && !strings.ContainsAny(subtype, "/,; \t")
+}
+
+// requestHost returns the request's host without port and trailing dot.
+func requestHost(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// Host returns a subrouter that only handles requests whose Host header matches
+// pattern. Labels in braces are parameters, optionally with a regex, e.g.
+// "{tenant}.example.com" or "{tenant:[a-z]+}.example.com". The port is ignored
+// and matching is case-insensitive. Captured values are available through
+// URLParam like path parameters. Requests for other hosts fall through to the
+// parent router.
+//
+//	tenants := router.Host("{tenant}.example.com")
+//	tenants.GetFunc("/", func(rc *nova.ResponseContext) error {
+//		return rc.Text(http.StatusOK, "Hello "+rc.URLParam("tenant"))
+//	})
+func (r *Router) Host(pattern string) *Router {
+	sr := r.Subrouter("")
+	sr.match = sr.match.withHost(pattern)
+	return sr
+}
+
+// Headers returns a subrouter that only handles requests carrying the given
+// headers, passed as name/value pairs. An empty value only requires the header
+// to be present. Otherwise the header, or one of its comma-separated elements,
+// must equal the value case-insensitively. A media type value ignores the
+// parameters of the elements, so "Accept", "application/vnd.example.v2+json"
+// also matches "text/html, application/vnd.example.v2+json;q=0.9", but not an
+// entry with q=0.
+// Requests without the headers fall through to the parent router.
+func (r *Router) Headers(pairs ...string) *Router {
+	if len(pairs)%2 != 0 {
+		panic("Headers: odd number of arguments")
+	}
+	headers := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		headers[pairs[i]] = pairs[i+1]
+	}
+	sr := r.Subrouter("")
+	sr.match = sr.match.withHeaders(headers)
+	return sr
+}
+
+// routeMatcher returns the matcher for the Host and Headers route options, or nil.
+func routeMatcher(opts *RouteOptions) *matcher {
+	if opts == nil || (opts.Host == "" && len(opts.Headers) == 0) {
+		return nil
+	}
+	var m *matcher
+	if opts.Host != "" {
+		m = m.withHost(opts.Host)
+	}
+	if len(opts.Headers) > 0 {
+		m = m.withHeaders(opts.Headers)
+	}
+	return m
+}
+
+// withParams returns req with params added to the URL parameters stored in its
+// context by the router.
+func (r *Router) withParams(req *http.Request, params map[string]string) *http.Request {
+	if len(params) == 0 {
+		return req
+	}
+	if existing, ok := req.Context().Value(r.paramsKey).(map[string]string); ok {
+		merged := make(map[string]string, len(existing)+len(params))
+		for k, v := range existing {
+			merged[k] = v
+		}
+		for k, v := range params {
+			merged[k] = v
+		}
+		params = merged
+	}
+	return req.WithContext(context.WithValue(req.Context(), r.paramsKey, params))
+}
@@ -0,0 +1,171 @@
+package nova
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestHostRouting tests host subrouters, host parameters and route level host
+// and header conditions.
+func TestHostRouting(t *testing.T) {
+	r := NewRouter()
+	r.GetFunc("/", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "main")
+	})
+	r.GetFunc("/status", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "status")
+	})
+	r.GetFunc("/status", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "admin status")
+	}, &RouteOptions{Host: "admin.example.com"})
+
+	tenants := r.Host("{tenant:[a-z]+}.example.com")
+	tenants.GetFunc("/", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "tenant "+rc.URLParam("tenant"))
+	})
+	tenants.GetFunc("/users/{id}", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, rc.URLParam("tenant")+" user "+rc.URLParam("id"))
+	})
+	tenants.PostFunc("/users", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusCreated, "created")
+	})
+
+	r.Host(`{region:[A-Z]+}-{n:\d+}.API.example.com`).GetFunc("/", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, rc.URLParam("region")+" "+rc.URLParam("n"))
+	})
+	r.Host(`{code:\D+}.codes.example.com`).GetFunc("/", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "code "+rc.URLParam("code"))
+	})
+
+	v2 := r.Headers("Accept", "application/vnd.example.v2+json")
+	v2.GetFunc("/items", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "items v2")
+	})
+	r.GetFunc("/items", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "items v1")
+	})
+	r.GetFunc("/beta", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "beta")
+	}, &RouteOptions{Headers: map[string]string{"X-Beta": ""}})
+
+	cases := []struct {
+		name       string
+		method     string
+		host       string
+		path       string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+	}{
+		{"tenant root", "GET", "acme.example.com", "/", nil, http.StatusOK, "tenant acme"},
+		{"tenant with port and case", "GET", "ACME.Example.com:8080", "/", nil, http.StatusOK, "tenant acme"},
+		{"tenant path params", "GET", "acme.example.com", "/users/7", nil, http.StatusOK, "acme user 7"},
+		{"regex rejects host", "GET", "acme1.example.com", "/", nil, http.StatusOK, "main"},
+		{"regex ignores case", "GET", "EU-12.api.example.com", "/", nil, http.StatusOK, "eu 12"},
+		{"regex class kept", "GET", "abc.codes.example.com", "/", nil, http.StatusOK, "code abc"},
+		{"regex class rejects", "GET", "123.codes.example.com", "/", nil, http.StatusOK, "main"},
+		{"other host falls through", "GET", "example.org", "/", nil, http.StatusOK, "main"},
+		{"fall through to parent route", "GET", "acme.example.com", "/status", nil, http.StatusOK, "status"},
+		{"route host wins", "GET", "admin.example.com", "/status", nil, http.StatusOK, "admin status"},
+		{"host route only on its host", "POST", "example.org", "/users", nil, http.StatusNotFound, ""},
+		{"tenant method mismatch", "DELETE", "acme.example.com", "/users", nil, http.StatusMethodNotAllowed, ""},
+		{"header subrouter", "GET", "example.org", "/items", http.Header{"Accept": {"text/html, application/vnd.example.v2+json"}}, http.StatusOK, "items v2"},
+		{"header fallback", "GET", "example.org", "/items", http.Header{"Accept": {"application/json"}}, http.StatusOK, "items v1"},
+		{"header media type with quality", "GET", "example.org", "/items", http.Header{"Accept": {"text/html, Application/vnd.example.v2+json;q=0.9"}}, http.StatusOK, "items v2"},
+		{"header media type with parameters", "GET", "example.org", "/items", http.Header{"Accept": {"application/vnd.example.v2+json; charset=utf-8; q=0.5"}}, http.StatusOK, "items v2"},
+		{"header media type refused", "GET", "example.org", "/items", http.Header{"Accept": {"application/vnd.example.v2+json;q=0, */*"}}, http.StatusOK, "items v1"},
+		{"header media type prefix", "GET", "example.org", "/items", http.Header{"Accept": {"application/vnd.example.v2+json-seq"}}, http.StatusOK, "items v1"},
+		{"header present", "GET", "example.org", "/beta", http.Header{"X-Beta": {"1"}}, http.StatusOK, "beta"},
+		{"header missing", "GET", "example.org", "/beta", nil, http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, nil)
+			req.Host = c.host
+			for k, v := range c.header {
+				req.Header[k] = v
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != c.wantStatus {
+				t.Errorf("status = %d; want %d", rr.Code, c.wantStatus)
+			}
+			if c.wantBody != "" && rr.Body.String() != c.wantBody {
+				t.Errorf("body = %q; want %q", rr.Body.String(), c.wantBody)
+			}
+		})
+	}
+
+	req := httptest.NewRequest("DELETE", "/users", nil)
+	req.Host = "acme.example.com"
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if allow := rr.Header().Get("Allow"); allow != "OPTIONS, POST" {
+		t.Errorf("Allow = %q; want OPTIONS, POST", allow)
+	}
+}
+
+// TestHostRoutingOpenAPI tests that host conditions become servers and header
+// conditions become header parameters.
+func TestHostRoutingOpenAPI(t *testing.T) {
+	r := NewRouter()
+	r.GetFunc("/health", func(rc *ResponseContext) error { return nil })
+	r.Host("{tenant}.example.com").GetFunc("/dashboard", func(rc *ResponseContext) error { return nil })
+	r.GetFunc("/dashboard", func(rc *ResponseContext) error { return nil },
+		&RouteOptions{Host: "admin.example.com"})
+	r.GetFunc("/settings", func(rc *ResponseContext) error { return nil })
+	r.GetFunc("/settings", func(rc *ResponseContext) error { return nil },
+		&RouteOptions{Host: "admin.example.com"})
+	r.GetFunc("/reports", func(rc *ResponseContext) error { return nil },
+		&RouteOptions{Headers: map[string]string{"X-Api-Version": "2", "Accept": "text/csv"}})
+
+	spec := GenerateOpenAPISpec(r, OpenAPIConfig{
+		Title:   "t",
+		Version: "1",
+		Servers: []ServerObject{{URL: "https://api.example.com"}},
+	})
+
+	if len(spec.Servers) != 1 || spec.Servers[0].URL != "https://api.example.com" {
+		t.Errorf("top-level servers = %+v", spec.Servers)
+	}
+	if servers := spec.Paths["/health"].Get.Servers; servers != nil {
+		t.Errorf("/health servers = %+v; want none", servers)
+	}
+
+	op := spec.Paths["/dashboard"].Get
+	if len(op.Servers) != 2 {
+		t.Fatalf("/dashboard servers = %+v; want both hosts", op.Servers)
+	}
+	if op.Servers[0].URL != "https://admin.example.com" {
+		t.Errorf("admin server = %+v", op.Servers[0])
+	}
+	tenant := op.Servers[1]
+	if tenant.URL != "https://{tenant}.example.com" || tenant.Variables["tenant"] == nil {
+		t.Errorf("tenant server = %+v", tenant)
+	}
+
+	// Without a host condition the route is served on the top-level servers.
+	settings := spec.Paths["/settings"].Get.Servers
+	if len(settings) != 2 || settings[0].URL != "https://api.example.com" ||
+		settings[1].URL != "https://admin.example.com" {
+		t.Errorf("/settings servers = %+v; want default and admin", settings)
+	}
+	bare := GenerateOpenAPISpec(r, OpenAPIConfig{Title: "t", Version: "1"})
+	if settings := bare.Paths["/settings"].Get.Servers; len(settings) != 2 || settings[0].URL != "/" {
+		t.Errorf("/settings servers without top-level servers = %+v; want / and admin", settings)
+	}
+
+	var found bool
+	for _, p := range spec.Paths["/reports"].Get.Parameters {
+		if p.Name == "Accept" {
+			t.Error("Accept header documented as a parameter")
+		}
+		if p.Name == "X-Api-Version" && p.In == "header" && p.Required {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("/reports parameters = %+v; want X-Api-Version header", spec.Paths["/reports"].Get.Parameters)
+	}
+}
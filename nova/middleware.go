@@ -1,6 +1,7 @@
 package nova
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"context"
@@ -33,6 +34,12 @@ const (
 	basicAuthUserKey contextKey = "basicAuthUser"
 	// csrfTokenKey is the context key used for storing the generated CSRF token.
 	csrfTokenKey contextKey = "csrfToken"
+	// matchedRouteKey is the context key used for storing the matched route.
+	matchedRouteKey contextKey = "matchedRoute"
+	// apiVersionKey is the context key used for storing the requested API version.
+	apiVersionKey contextKey = "apiVersion"
+	// routeObserverKey is the context key used for storing the route observer.
+	routeObserverKey contextKey = "routeObserver"
 )
 
 // GetRequestID retrieves the request ID from the context, if available via
@@ -129,19 +136,38 @@ func (w *responseWriterInterceptor) Write(b []byte) (int, error) {
 
 // Flush implements the http.Flusher interface if the underlying writer supports it.
 func (w *responseWriterInterceptor) Flush() {
-	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
-		flusher.Flush()
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *responseWriterInterceptor) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it, so
+// WebSocket upgrades work. The status is recorded as 101 Switching Protocols.
+func (w *responseWriterInterceptor) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.statusCode = http.StatusSwitchingProtocols
+		w.wroteHeader = true
 	}
+	return conn, brw, err
 }
 
 // bufferingResponseWriterInterceptor wraps http.ResponseWriter to capture status, size,
-// and buffer the response body.
+// and buffer the response body. Once the handler flushes, the buffered data is written
+// and the rest of the response is streamed to the underlying writer.
 type bufferingResponseWriterInterceptor struct {
 	http.ResponseWriter
 	statusCode  int
 	size        int64
 	wroteHeader bool
 	buffer      *bytes.Buffer // Buffer to hold the response body
+	streaming   bool          // Set once Flush has written the buffered data
 }
 
 // NewBufferingResponseWriterInterceptor creates a new buffering interceptor.
@@ -168,20 +194,50 @@ func (w *bufferingResponseWriterInterceptor) Write(b []byte) (int, error) {
 	if !w.wroteHeader {
 		w.WriteHeader(http.StatusOK)
 	}
+	if w.streaming {
+		size, err := w.ResponseWriter.Write(b)
+		w.size += int64(size)
+		return size, err
+	}
 	size, err := w.buffer.Write(b)
 	w.size += int64(size)
 	return size, err
 }
 
-// Flush implements the http.Flusher interface if the underlying writer supports it.
+// Flush writes the captured status code and buffered body to the underlying writer
+// and flushes it. Subsequent writes bypass the buffer, so streamed responses such as
+// Server-Sent Events reach the client immediately.
 func (w *bufferingResponseWriterInterceptor) Flush() {
-	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
-		// We could potentially write the buffer and flush here,
-		// but standard middleware pattern usually writes fully at the end.
-		// For simplicity, we might just flush the underlying if needed,
-		// though the buffer hasn't been written yet.
-		flusher.Flush()
+	if !w.streaming {
+		w.streaming = true
+		if _, err := w.WriteCapturedData(); err != nil {
+			return
+		}
 	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *bufferingResponseWriterInterceptor) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it. A hijacked
+// response is treated like a streamed one and nothing is written afterwards.
+func (w *bufferingResponseWriterInterceptor) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.statusCode = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+		w.streaming = true
+	}
+	return conn, brw, err
+}
+
+// Streaming reports whether the response was flushed or hijacked and is no longer
+// buffered.
+func (w *bufferingResponseWriterInterceptor) Streaming() bool {
+	return w.streaming
 }
 
 // WriteCapturedData writes the captured status code, headers, and buffered body
@@ -226,6 +282,9 @@ type CSRFConfig struct {
 	// ErrorHandler is called when CSRF validation fails.
 	// Defaults to sending a 403 Forbidden response.
 	ErrorHandler http.HandlerFunc
+	// ProblemDetails makes the default ErrorHandler respond with RFC 9457
+	// problem details instead of plain text.
+	ProblemDetails bool
 	// CookiePath sets the path attribute of the CSRF cookie. Defaults to "/".
 	CookiePath string
 	// CookieDomain sets the domain attribute of the CSRF cookie. Defaults to "".
@@ -274,6 +333,13 @@ func CSRFMiddleware(config *CSRFConfig) Middleware {
 	}
 	if cfg.ErrorHandler == nil {
 		cfg.ErrorHandler = func(w http.ResponseWriter, r *http.Request) {
+			if cfg.ProblemDetails {
+				_ = writeProblem(w, r, ProblemDetails{
+					Status: http.StatusForbidden,
+					Detail: "CSRF token missing or invalid",
+				})
+				return
+			}
 			http.Error(
 				w,
 				http.StatusText(http.StatusForbidden),
@@ -431,6 +497,11 @@ func ETagMiddleware(config *ETagConfig) Middleware {
 			// Call the next handler, which writes to the interceptor's buffer
 			next.ServeHTTP(interceptor, r)
 
+			// A flushed response has already been sent and cannot get an ETag
+			if interceptor.Streaming() {
+				return
+			}
+
 			// Get response data from interceptor
 			statusCode := interceptor.StatusCode()
 			body := interceptor.Body()
@@ -546,6 +617,8 @@ type LoggingConfig struct {
 }
 
 // LoggingMiddleware logs request details including method, path, status, size, and duration.
+// When registered with Router.Use, the completion line names the matched route
+// pattern, e.g. "/users/{id}", instead of the request path.
 func LoggingMiddleware(config *LoggingConfig) Middleware {
 	cfg := config
 	if cfg == nil {
@@ -577,12 +650,18 @@ func LoggingMiddleware(config *LoggingConfig) Middleware {
 
 			next.ServeHTTP(interceptor, r)
 
+			// Use the route pattern when the middleware runs inside the router.
+			path := r.URL.Path
+			if rt := GetMatchedRoute(r.Context()); rt != nil {
+				path = rt.Pattern
+			}
+
 			duration := time.Since(start)
 			cfg.Logger.Printf(
 				"[INFO] %sCompleted %s %s %d %s (%d bytes) in %v",
 				requestIDStr,
 				r.Method,
-				r.URL.Path,
+				path,
 				interceptor.statusCode,
 				http.StatusText(interceptor.statusCode),
 				interceptor.size,
@@ -734,7 +813,8 @@ type CORSConfig struct {
 	MaxAgeSeconds int
 }
 
-// CORSMiddleware sets Cross-Origin Resource Sharing headers.
+// CORSMiddleware sets Cross-Origin Resource Sharing headers. Register it with
+// Router.Pre so preflight requests for any path are answered before routing.
 func CORSMiddleware(config CORSConfig) Middleware {
 	// Set defaults
 	if len(config.AllowedMethods) == 0 {
@@ -906,33 +986,119 @@ func SecurityHeadersMiddleware(config SecurityHeadersConfig) Middleware {
 	}
 }
 
-// timeoutResponseWriter wraps http.ResponseWriter to prevent panics from
-// superfluous WriteHeader calls after a timeout.
+// timeoutResponseWriter buffers the response of a handler running under
+// TimeoutMiddleware, so a timeout response can replace it. A handler that
+// flushes or hijacks the connection starts a stream: the buffered response is
+// written through, later writes go straight to the client and the deadline no
+// longer applies.
 type timeoutResponseWriter struct {
-	http.ResponseWriter
+	w           http.ResponseWriter
 	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
 	wroteHeader bool
+	timedOut    bool
+	streaming   bool
+	// stream is closed when a stream starts, stopping the deadline.
+	stream chan struct{}
 }
 
-func (w *timeoutResponseWriter) WriteHeader(statusCode int) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	if w.wroteHeader {
+func (tw *timeoutResponseWriter) Header() http.Header {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.streaming {
+		return tw.w.Header()
+	}
+	return tw.header
+}
+
+func (tw *timeoutResponseWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
 		return
 	}
-	w.ResponseWriter.WriteHeader(statusCode)
-	w.wroteHeader = true
+	tw.wroteHeader = true
+	tw.code = statusCode
+	if tw.streaming {
+		tw.w.WriteHeader(statusCode)
+	}
 }
 
-func (w *timeoutResponseWriter) Write(b []byte) (int, error) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	if !w.wroteHeader {
-		// Implicitly write 200 OK if not already written
-		w.ResponseWriter.WriteHeader(http.StatusOK)
-		w.wroteHeader = true
+func (tw *timeoutResponseWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.streaming {
+		return tw.w.Write(b)
+	}
+	if !tw.wroteHeader {
+		tw.wroteHeader, tw.code = true, http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+// FlushError starts a stream, if not started yet, and flushes the response.
+func (tw *timeoutResponseWriter) FlushError() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	if !tw.streaming {
+		tw.startStream()
+		tw.writeBuffered()
+	}
+	return http.NewResponseController(tw.w).Flush()
+}
+
+// Flush implements http.Flusher. See FlushError.
+func (tw *timeoutResponseWriter) Flush() {
+	_ = tw.FlushError()
+}
+
+// Hijack starts a stream, discarding the buffered response, and hands over the
+// connection, e.g. for a WebSocket.
+func (tw *timeoutResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	if !tw.streaming {
+		tw.startStream()
+	}
+	return http.NewResponseController(tw.w).Hijack()
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (tw *timeoutResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// startStream switches to streaming. tw.mu must be held.
+func (tw *timeoutResponseWriter) startStream() {
+	tw.streaming = true
+	close(tw.stream)
+}
+
+// writeBuffered writes the buffered headers, status and body to the client.
+// tw.mu must be held.
+func (tw *timeoutResponseWriter) writeBuffered() {
+	dst := tw.w.Header()
+	for k, vv := range tw.header {
+		dst[k] = vv
+	}
+	if tw.wroteHeader {
+		tw.w.WriteHeader(tw.code)
+	}
+	if tw.buf.Len() > 0 {
+		_, _ = tw.w.Write(tw.buf.Bytes())
+		tw.buf.Reset()
 	}
-	return w.ResponseWriter.Write(b)
 }
 
 // TimeoutConfig holds configuration for TimeoutMiddleware.
@@ -942,12 +1108,21 @@ type TimeoutConfig struct {
 	// TimeoutMessage is the message sent in the response body on timeout.
 	// Defaults to "Service timed out".
 	TimeoutMessage string
-	// TimeoutHandler allows custom logic to run on timeout. If nil, the default
-	// http.TimeoutHandler behavior (503 Service Unavailable with message) is used.
+	// TimeoutHandler allows custom logic to run on timeout. If nil, a 503
+	// Service Unavailable response with TimeoutMessage is sent.
 	TimeoutHandler http.Handler
-}
-
-// TimeoutMiddleware sets a maximum duration for handling requests.
+	// ProblemDetails makes the default timeout response an RFC 9457 problem
+	// details response with TimeoutMessage as the detail. Ignored when
+	// TimeoutHandler is set.
+	ProblemDetails bool
+}
+
+// TimeoutMiddleware sets a maximum duration for handling requests. The response
+// is buffered until the handler returns; on timeout the handler's context is
+// canceled and the timeout response is sent instead. Responses that start a
+// stream by flushing, such as Server-Sent Events, or that hijack the connection,
+// such as WebSocket upgrades, are long-lived by design: from then on they are
+// written through and the deadline no longer applies.
 func TimeoutMiddleware(config TimeoutConfig) Middleware {
 	if config.Duration <= 0 {
 		// No timeout, return identity middleware
@@ -956,55 +1131,75 @@ func TimeoutMiddleware(config TimeoutConfig) Middleware {
 	if config.TimeoutMessage == "" {
 		config.TimeoutMessage = "Service timed out"
 	}
+	if config.TimeoutHandler == nil {
+		config.TimeoutHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.ProblemDetails {
+				_ = writeProblem(w, r, ProblemDetails{
+					Status: http.StatusServiceUnavailable,
+					Detail: config.TimeoutMessage,
+				})
+				return
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, config.TimeoutMessage)
+		})
+	}
 
 	return func(next http.Handler) http.Handler {
-		if config.TimeoutHandler != nil {
-			// Use custom timeout handler if provided
-			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				ctx, cancel := context.WithTimeout(r.Context(), config.Duration)
-				defer cancel()
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
 
-				done := make(chan struct{})
-				panicChan := make(chan any, 1)
-				tw := &timeoutResponseWriter{ResponseWriter: w}
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+			tw := &timeoutResponseWriter{w: w, header: make(http.Header), stream: make(chan struct{})}
 
-				go func() {
-					defer func() {
-						if p := recover(); p != nil {
-							panicChan <- p
-						}
-						close(done)
-					}()
-					next.ServeHTTP(tw, r.WithContext(ctx))
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+					close(done)
 				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+			}()
 
-				select {
-				case <-done:
-					// Handler finished within timeout. Re-panic if it panicked
-					select {
-					case p := <-panicChan:
-						panic(p)
-					default:
-						// No panic.
-					}
-				case <-ctx.Done():
-					// Timeout occurred.
-					// Ensure we don't call the timeout handler if the main handler
-					// already wrote the headers
-					tw.mu.Lock()
-					if tw.wroteHeader {
-						tw.mu.Unlock()
-						return
-					}
-					config.TimeoutHandler.ServeHTTP(w, r)
-					tw.wroteHeader = true
+			timer := time.NewTimer(config.Duration)
+			defer timer.Stop()
+			select {
+			case <-done:
+			case <-tw.stream:
+				// A stream started; wait for the handler without a deadline.
+				<-done
+			case <-timer.C:
+				tw.mu.Lock()
+				if tw.streaming {
 					tw.mu.Unlock()
+					<-done
+					break
 				}
-			})
-		} else {
-			// Use standard http.TimeoutHandler for the simple case
-			return http.TimeoutHandler(next, config.Duration, config.TimeoutMessage)
-		}
+				tw.timedOut = true
+				tw.mu.Unlock()
+				cancel()
+				config.TimeoutHandler.ServeHTTP(w, r)
+				return
+			}
+
+			// Handler finished. Re-panic if it panicked
+			select {
+			case p := <-panicChan:
+				panic(p)
+			default:
+			}
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			if !tw.streaming {
+				tw.writeBuffered()
+				if !tw.wroteHeader {
+					w.WriteHeader(http.StatusOK)
+				}
+			}
+		})
 	}
 }
 
@@ -1069,6 +1264,7 @@ type MethodOverrideConfig struct {
 }
 
 // MethodOverrideMiddleware checks a header or form field to override the request method.
+// Register it with Router.Pre so the route is matched with the overridden method.
 func MethodOverrideMiddleware(config *MethodOverrideConfig) Middleware {
 	cfg := config
 	if cfg == nil {
@@ -1246,14 +1442,18 @@ func (w *gzipResponseWriter) Write(b []byte) (int, error) {
 	return w.Writer.Write(b)
 }
 
-// Flush implements http.Flusher if the underlying ResponseWriter supports it.
+// Flush writes any pending compressed data and flushes the underlying
+// ResponseWriter if it supports it, so streamed responses are not held back.
 func (w *gzipResponseWriter) Flush() {
 	if err := w.Writer.Flush(); err != nil {
 		log.Printf("[DEBUG] GzipMiddleware: Error flushing gzip writer: %v", err)
 	}
-	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
-		flusher.Flush()
-	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
 
 // GzipConfig holds configuration options for the GzipMiddleware.
@@ -1311,8 +1511,8 @@ func GzipMiddleware(config *GzipConfig) Middleware {
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Check if client accepts gzip
-			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			// Check if client accepts gzip. Upgraded connections have no body to compress.
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") || isWebSocketUpgrade(r) {
 				next.ServeHTTP(w, r) // Pass through if gzip not accepted
 				return
 			}
@@ -1459,6 +1659,9 @@ type MaxRequestBodySizeConfig struct {
 	// OnError allows custom handling when the body size limit is exceeded.
 	// If nil, sends 413 Request Entity Too Large.
 	OnError func(w http.ResponseWriter, r *http.Request)
+	// ProblemDetails makes the default OnError respond with RFC 9457 problem
+	// details instead of plain text.
+	ProblemDetails bool
 }
 
 // MaxRequestBodySizeMiddleware limits the size of incoming request bodies.
@@ -1470,6 +1673,13 @@ func MaxRequestBodySizeMiddleware(config MaxRequestBodySizeConfig) Middleware {
 	onError := config.OnError
 	if onError == nil {
 		onError = func(w http.ResponseWriter, r *http.Request) {
+			if config.ProblemDetails {
+				_ = writeProblem(w, r, ProblemDetails{
+					Status: http.StatusRequestEntityTooLarge,
+					Detail: fmt.Sprintf("Request body exceeds the limit of %d bytes", config.LimitBytes),
+				})
+				return
+			}
 			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
 		}
 	}
@@ -1498,6 +1708,7 @@ type TrailingSlashRedirectConfig struct {
 }
 
 // TrailingSlashRedirectMiddleware redirects requests to add or remove a trailing slash.
+// Register it with Router.Pre so it also redirects paths that match no route.
 func TrailingSlashRedirectMiddleware(config TrailingSlashRedirectConfig) Middleware {
 	if config.RedirectCode == 0 {
 		config.RedirectCode = http.StatusMovedPermanently
@@ -1550,6 +1761,7 @@ type ForceHTTPSConfig struct {
 }
 
 // ForceHTTPSMiddleware redirects HTTP requests to HTTPS.
+// Register it with Router.Pre so it also redirects paths that match no route.
 func ForceHTTPSMiddleware(config ForceHTTPSConfig) Middleware {
 	if config.RedirectCode == 0 {
 		config.RedirectCode = http.StatusMovedPermanently
@@ -1868,11 +2080,15 @@ type RateLimiterConfig struct {
 	// Defaults to the value of Requests (no extra burst capacity).
 	Burst int
 	// KeyFunc extracts a unique key from the request to identify the client.
-	// Defaults to using the client's IP address (r.RemoteAddr).
+	// Defaults to using the client's IP address (r.RemoteAddr). Use
+	// RouteRateLimitKey to limit each route separately.
 	KeyFunc func(r *http.Request) string
 	// OnLimitExceeded allows custom handling when the rate limit is hit.
 	// If nil, sends 429 Too Many Requests.
 	OnLimitExceeded func(w http.ResponseWriter, r *http.Request)
+	// ProblemDetails makes the default OnLimitExceeded respond with RFC 9457
+	// problem details instead of plain text.
+	ProblemDetails bool
 	// CleanupInterval specifies how often to scan and remove old entries from memory.
 	// If zero or negative, no automatic cleanup occurs (potential memory leak).
 	// A value like 10*time.Minute is reasonable.
@@ -1881,6 +2097,37 @@ type RateLimiterConfig struct {
 	Logger *log.Logger
 }
 
+// clientIPKey returns the client's IP address from r.RemoteAddr. It is the
+// default RateLimiterConfig.KeyFunc.
+func clientIPKey(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// RouteRateLimitKey is a RateLimiterConfig.KeyFunc that limits every client
+// per matched route: the key is the client's IP address followed by the
+// method and pattern of the route, e.g. "203.0.113.7 GET /users/{id}", so
+// "/users/1" and "/users/2" share a budget while "/login" has its own. The
+// route is only known when the middleware is registered with Router.Use,
+// Group.Use or RouteOptions.Middleware; otherwise the key is the IP address
+// alone, as by default.
+//
+//	router.Use(nova.RateLimitMiddleware(nova.RateLimiterConfig{
+//		Requests: 10,
+//		Duration: time.Minute,
+//		KeyFunc:  nova.RouteRateLimitKey,
+//	}))
+func RouteRateLimitKey(r *http.Request) string {
+	ip := clientIPKey(r)
+	if rt := GetMatchedRoute(r.Context()); rt != nil {
+		return ip + " " + rt.Method + " " + rt.Pattern
+	}
+	return ip
+}
+
 // visitor tracks request counts and timestamps for rate limiting.
 type visitor struct {
 	tokens    float64   // Current number of available tokens
@@ -1906,13 +2153,7 @@ func RateLimitMiddleware(config RateLimiterConfig) Middleware {
 
 	keyFunc := config.KeyFunc
 	if keyFunc == nil {
-		keyFunc = func(r *http.Request) string {
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
-			if err != nil {
-				return r.RemoteAddr
-			}
-			return ip
-		}
+		keyFunc = clientIPKey
 	}
 
 	onLimitExceeded := config.OnLimitExceeded
@@ -1921,6 +2162,13 @@ func RateLimitMiddleware(config RateLimiterConfig) Middleware {
 			// Suggest retrying after the window duration passes
 			retryAfter := strconv.Itoa(int(config.Duration.Seconds()))
 			w.Header().Set("Retry-After", retryAfter)
+			if config.ProblemDetails {
+				_ = writeProblem(w, r, ProblemDetails{
+					Status: http.StatusTooManyRequests,
+					Detail: "Rate limit exceeded, retry after " + retryAfter + " seconds",
+				})
+				return
+			}
 			http.Error(
 				w,
 				http.StatusText(http.StatusTooManyRequests),
@@ -0,0 +1,270 @@
+package nova
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// defaultMultipartMemory is the number of bytes of a multipart/form-data body kept
+// in memory when the router has no threshold set. Larger files are stored on disk.
+const defaultMultipartMemory = 32 << 20
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// isFileField reports whether t is *multipart.FileHeader or []*multipart.FileHeader.
+func isFileField(t reflect.Type) bool {
+	return t == fileHeaderType || t == fileHeaderSliceType
+}
+
+// hasFileFields reports whether the request type v has file upload fields,
+// also in nested structs.
+func hasFileFields(v any) bool {
+	t, ok := structType(v)
+	return ok && structHasFileFields(t, map[reflect.Type]bool{})
+}
+
+// structHasFileFields reports whether the struct type t or a struct nested in
+// it has file upload fields. seen guards against recursive types.
+func structHasFileFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	seen[t] = true
+	for _, f := range reflect.VisibleFields(t) {
+		if f.PkgPath != "" {
+			continue
+		}
+		if isFileField(f.Type) {
+			return true
+		}
+		nested := f.Type
+		if nested.Kind() == reflect.Pointer {
+			nested = nested.Elem()
+		}
+		if isStructLike(nested) && !seen[nested] && structHasFileFields(nested, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaxMultipartMemory sets how many bytes of a multipart/form-data body are kept
+// in memory while parsing. The remaining file parts are stored in temporary files.
+// Defaults to 32 MB. It does not limit the body size; use MaxRequestBodySizeMiddleware
+// for that. Subrouters created afterwards inherit the threshold.
+func (r *Router) SetMaxMultipartMemory(bytes int64) {
+	r.multipartMemory = bytes
+}
+
+// maxMultipartMemory returns the router's multipart memory threshold.
+func (r *Router) maxMultipartMemory() int64 {
+	if r.multipartMemory > 0 {
+		return r.multipartMemory
+	}
+	return defaultMultipartMemory
+}
+
+// parseForm parses the request form. multipart/form-data bodies are parsed with
+// the router's memory threshold so their files become available for binding.
+func (rc *ResponseContext) parseForm() error {
+	mediaType, _, _ := mime.ParseMediaType(rc.r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return rc.r.ParseMultipartForm(rc.router.maxMultipartMemory())
+	}
+	return rc.r.ParseForm()
+}
+
+// bindFilesToStruct binds uploaded files to fields of type *multipart.FileHeader
+// (the first file) and []*multipart.FileHeader (all files). Field names are matched
+// using JSON tags or struct field names, like bindFormToStruct. Fields of nested
+// structs and pointers to structs use dot notation ("profile.avatar"); embedded
+// structs share the prefix of their parent. Files are not bound to elements of
+// slices of structs.
+func bindFilesToStruct(files map[string][]*multipart.FileHeader, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("v must be a pointer to struct")
+	}
+	keys := make(url.Values, len(files))
+	for key := range files {
+		keys[key] = nil
+	}
+	bindFileValues(files, keys, rv.Elem(), "")
+	return nil
+}
+
+// bindFileValues binds the file fields of the struct value rv, and of the
+// structs nested in it, from the file keys starting with prefix. keys holds the
+// keys of files, so hasFormKey can look for nested keys.
+func bindFileValues(files map[string][]*multipart.FileHeader, keys url.Values, rv reflect.Value, prefix string) {
+	rt := rv.Type()
+
+	for i := range rv.NumField() {
+		field := rv.Field(i)
+		fieldType := rt.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		jsonTag := fieldType.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		if _, _, _, ok := paramTag(fieldType); ok {
+			continue
+		}
+		name := strings.Split(jsonTag, ",")[0]
+		t := fieldType.Type
+		if fieldType.Anonymous && name == "" && t.Kind() == reflect.Struct {
+			bindFileValues(files, keys, field, prefix)
+			continue
+		}
+		if name == "" {
+			name = fieldType.Name
+		}
+
+		switch {
+		case isFileField(t):
+			headers := files[prefix+name]
+			if len(headers) == 0 {
+				continue
+			}
+			if t == fileHeaderType {
+				field.Set(reflect.ValueOf(headers[0]))
+			} else {
+				field.Set(reflect.ValueOf(headers))
+			}
+		case isStructLike(t) && hasFormKey(keys, prefix+name):
+			if t.Kind() == reflect.Pointer {
+				if field.IsNil() {
+					field.Set(reflect.New(t.Elem()))
+				}
+				field = field.Elem()
+			}
+			bindFileValues(files, keys, field, prefix+name+".")
+		}
+	}
+}
+
+// validateFileField applies maxFileSize, accept, minItems and maxItems to a
+// *multipart.FileHeader or []*multipart.FileHeader field.
+func validateFileField(
+	fv reflect.Value,
+	f reflect.StructField,
+	fieldName, lang, custom string,
+) ValidationErrors {
+	var headers []*multipart.FileHeader
+	if fh, ok := fv.Interface().(*multipart.FileHeader); ok {
+		if fh != nil {
+			headers = []*multipart.FileHeader{fh}
+		}
+	} else {
+		headers = fv.Interface().([]*multipart.FileHeader)
+	}
+
+	var errs ValidationErrors
+	fail := func(code string, params map[string]any, args ...any) {
+		errs = append(errs, newFieldError(fieldName, code, params, lang, custom, args...))
+	}
+
+	if fv.Kind() == reflect.Slice {
+		if minTag := f.Tag.Get("minItems"); minTag != "" {
+			if min, _ := strconv.Atoi(minTag); len(headers) < min {
+				fail("minItems", map[string]any{"limit": min}, min)
+			}
+		}
+		if maxTag := f.Tag.Get("maxItems"); maxTag != "" {
+			if max, _ := strconv.Atoi(maxTag); len(headers) > max {
+				fail("maxItems", map[string]any{"limit": max}, max)
+			}
+		}
+	}
+
+	// The tag was checked by checkTags, so it parses.
+	maxSize, _ := parseByteSize(f.Tag.Get("maxFileSize"))
+	hasMaxSize := f.Tag.Get("maxFileSize") != ""
+	var accept []string
+	if acceptTag := f.Tag.Get("accept"); acceptTag != "" {
+		for t := range strings.SplitSeq(acceptTag, ",") {
+			accept = append(accept, strings.TrimSpace(t))
+		}
+	}
+
+	for _, fh := range headers {
+		if hasMaxSize && fh.Size > maxSize {
+			fail("maxFileSize", map[string]any{"file": fh.Filename, "limit": f.Tag.Get("maxFileSize")},
+				fh.Filename, f.Tag.Get("maxFileSize"))
+		}
+		if len(accept) > 0 {
+			contentType, err := sniffContentType(fh)
+			if err != nil || !mediaTypeAllowed(contentType, accept) {
+				fail("accept", map[string]any{"file": fh.Filename, "types": accept},
+					fh.Filename, strings.Join(accept, ", "))
+			}
+		}
+	}
+	return errs
+}
+
+// sniffContentType detects the media type of an uploaded file from its first
+// 512 bytes using http.DetectContentType. The Content-Type sent by the client
+// is not trusted.
+func sniffContentType(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	return mediaType, err
+}
+
+// mediaTypeAllowed reports whether mediaType matches one of the allowed types.
+// Allowed types may use wildcards such as "image/*" or "*/*".
+func mediaTypeAllowed(mediaType string, allowed []string) bool {
+	for _, a := range allowed {
+		switch {
+		case a == "*/*", strings.EqualFold(a, mediaType):
+			return true
+		case strings.HasSuffix(a, "/*"):
+			if strings.HasPrefix(mediaType, strings.ToLower(strings.TrimSuffix(a, "*"))) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseByteSize parses a size such as "512", "200KB", "5MB" or "1GB".
+// Units are powers of 1024 and case-insensitive.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
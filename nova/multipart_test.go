@@ -0,0 +1,182 @@
+package nova
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// pngHeader is the signature http.DetectContentType recognizes as image/png.
+const pngHeader = "\x89PNG\r\n\x1a\n"
+
+// uploadFile is a file part of a multipart test request.
+type uploadFile struct {
+	field, name, contentType, content string
+}
+
+// newMultipartRequest builds a multipart/form-data POST request with the given
+// form values and files.
+func newMultipartRequest(t *testing.T, values map[string]string, files []uploadFile) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range values {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", `form-data; name="`+f.field+`"; filename="`+f.name+`"`)
+		h.Set("Content-Type", f.contentType)
+		part, err := mw.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(f.content))
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// TestBindValidatedMultipart tests binding and validating uploaded files.
+func TestBindValidatedMultipart(t *testing.T) {
+	type banner struct {
+		Image *multipart.FileHeader `json:"image,omitempty" maxFileSize:"1KB"`
+	}
+	type upload struct {
+		Title       string                  `json:"title"`
+		Avatar      *multipart.FileHeader   `json:"avatar" accept:"image/png,image/jpeg" maxFileSize:"1KB"`
+		Attachments []*multipart.FileHeader `json:"attachments,omitempty" maxItems:"2"`
+		Banner      *banner                 `json:"banner,omitempty"`
+	}
+
+	var got upload
+	r := NewRouter()
+	r.SetMaxMultipartMemory(512)
+	r.PostFunc("/upload", func(rc *ResponseContext) error {
+		got = upload{}
+		if err := rc.BindValidated(&got); err != nil {
+			return err
+		}
+		return rc.Text(http.StatusOK, "ok")
+	})
+
+	avatar := uploadFile{"avatar", "me.png", "image/png", pngHeader + "data"}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newMultipartRequest(t, map[string]string{"title": "hello"}, []uploadFile{
+		avatar,
+		{"attachments", "a.txt", "text/plain", "a"},
+		{"attachments", "b.txt", "text/plain", "b"},
+		{"banner.image", "top.png", "image/png", pngHeader},
+	}))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200, body %q", rr.Code, rr.Body.String())
+	}
+	if got.Title != "hello" || got.Avatar == nil || got.Avatar.Filename != "me.png" || len(got.Attachments) != 2 ||
+		got.Banner == nil || got.Banner.Image == nil || got.Banner.Image.Filename != "top.png" {
+		t.Errorf("bound %+v", got)
+	}
+
+	cases := []struct {
+		name     string
+		files    []uploadFile
+		wantBody string
+	}{
+		{"missing file", nil, "Field 'avatar' is required"},
+		{"spoofed content type", []uploadFile{{"avatar", "me.png", "image/png", "#!/bin/sh"}}, "must be of type: image/png, image/jpeg"},
+		{"file too large", []uploadFile{{"avatar", "big.png", "image/png", pngHeader + strings.Repeat("x", 2048)}}, "must be at most 1KB"},
+		{"too many files", []uploadFile{avatar,
+			{"attachments", "a", "text/plain", "a"},
+			{"attachments", "b", "text/plain", "b"},
+			{"attachments", "c", "text/plain", "c"},
+		}, "at most 2 items"},
+		{"nested file too large", []uploadFile{avatar,
+			{"banner.image", "big.png", "image/png", pngHeader + strings.Repeat("x", 2048)},
+		}, "file 'big.png' must be at most 1KB"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, newMultipartRequest(t, map[string]string{"title": "t"}, c.files))
+			if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), c.wantBody) {
+				t.Errorf("got %d %q; want 422 containing %q", rr.Code, rr.Body.String(), c.wantBody)
+			}
+		})
+	}
+}
+
+// TestMalformedFileTags tests that a malformed maxFileSize tag fails every
+// validation of the type, also without an uploaded file.
+func TestMalformedFileTags(t *testing.T) {
+	type upload struct {
+		Title  string                `json:"title"`
+		Avatar *multipart.FileHeader `json:"avatar,omitempty" maxFileSize:"5M"`
+	}
+	r := NewRouter()
+	r.PostFunc("/upload", func(rc *ResponseContext) error {
+		var u upload
+		return rc.BindValidated(&u)
+	})
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, newMultipartRequest(t, map[string]string{"title": "t"}, nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d; want 500", rr.Code)
+	}
+
+	type wrapper struct {
+		Uploads []upload `json:"uploads"`
+	}
+	if err := checkTags(reflect.TypeFor[wrapper]()); err == nil || !strings.Contains(err.Error(), "maxFileSize") {
+		t.Errorf("checkTags() = %v; want the maxFileSize error", err)
+	}
+}
+
+// TestParseByteSize tests the size notation of the maxFileSize tag.
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{"512": 512, "10B": 10, "2kb": 2048, "5MB": 5 << 20, "1 GB": 1 << 30}
+	for in, want := range cases {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "MB", "-1", "1TB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q) succeeded; want error", in)
+		}
+	}
+}
+
+// TestOpenAPIMultipart tests that request types with file fields are documented
+// as multipart/form-data with binary file properties.
+func TestOpenAPIMultipart(t *testing.T) {
+	type upload struct {
+		Title  string                  `json:"title"`
+		Avatar *multipart.FileHeader   `json:"avatar"`
+		Photos []*multipart.FileHeader `json:"photos"`
+	}
+	r := NewRouter()
+	r.PostFunc("/upload", func(rc *ResponseContext) error { return nil }, &RouteOptions{RequestBody: upload{}})
+
+	spec := GenerateOpenAPISpec(r, OpenAPIConfig{Title: "t", Version: "1"})
+	body := spec.Paths["/upload"].Post.RequestBody
+	if body == nil || body.Content["multipart/form-data"] == nil {
+		t.Fatalf("request body = %+v; want multipart/form-data", body)
+	}
+	schema := spec.Components.Schemas["upload"]
+	if p := schema.Properties["avatar"]; p.Type != "string" || p.Format != "binary" {
+		t.Errorf("avatar schema = %+v; want binary string", p)
+	}
+	if p := schema.Properties["photos"]; p.Type != "array" || p.Items.Format != "binary" {
+		t.Errorf("photos schema = %+v; want array of binary strings", p)
+	}
+}
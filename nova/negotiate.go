@@ -0,0 +1,285 @@
+package nova
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EncoderFunc writes v to w in a specific media type. See Router.RegisterEncoder.
+type EncoderFunc func(w io.Writer, v any) error
+
+// DecoderFunc reads a request body from r into v. See Router.RegisterDecoder.
+type DecoderFunc func(r io.Reader, v any) error
+
+// JSONEncoder encodes v as JSON. It is registered for "application/json" by default.
+func JSONEncoder(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// JSONDecoder decodes a JSON body into v. It is registered for "application/json"
+// by default.
+func JSONDecoder(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// XMLEncoder encodes v as XML. It is not registered by default, since many Go
+// values such as maps cannot be encoded as XML; enable it with
+//
+//	router.RegisterEncoder("application/xml", nova.XMLEncoder)
+func XMLEncoder(w io.Writer, v any) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// XMLDecoder decodes an XML body into v. It is not registered by default; enable
+// it with
+//
+//	router.RegisterDecoder("application/xml", nova.XMLDecoder)
+func XMLDecoder(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// mediaEncoder is an encoder registered for a media type.
+type mediaEncoder struct {
+	mediaType string
+	encode    EncoderFunc
+}
+
+// codecRegistry holds the response encoders and request decoders of a router
+// tree. Encoders are kept in registration order, which is the server preference
+// when the client accepts several media types equally.
+type codecRegistry struct {
+	encoders []mediaEncoder
+	decoders map[string]DecoderFunc
+}
+
+// newCodecRegistry returns a registry with the JSON codecs.
+func newCodecRegistry() *codecRegistry {
+	return &codecRegistry{
+		encoders: []mediaEncoder{
+			{"application/json", JSONEncoder},
+		},
+		decoders: map[string]DecoderFunc{
+			"application/json": JSONDecoder,
+		},
+	}
+}
+
+// RegisterEncoder registers enc for responses of the given media type, e.g.
+// "text/csv" or "application/x-ndjson". Registering a media type again replaces its
+// encoder. The router and all its subrouters share the registered encoders.
+// When the client accepts several types equally, the first registered wins;
+// only "application/json" is registered by default.
+func (r *Router) RegisterEncoder(mediaType string, enc EncoderFunc) {
+	mediaType = strings.ToLower(mediaType)
+	for i, e := range r.codecs.encoders {
+		if e.mediaType == mediaType {
+			r.codecs.encoders[i].encode = enc
+			return
+		}
+	}
+	r.codecs.encoders = append(r.codecs.encoders, mediaEncoder{mediaType, enc})
+}
+
+// RegisterDecoder registers dec for request bodies of the given media type. Bind and
+// BindValidated select the decoder by the request's Content-Type. The router and all
+// its subrouters share the registered decoders. Only "application/json" is
+// registered by default.
+func (r *Router) RegisterDecoder(mediaType string, dec DecoderFunc) {
+	r.codecs.decoders[strings.ToLower(mediaType)] = dec
+}
+
+// decoder returns the decoder for mediaType and the media type it is registered
+// for. Structured syntax suffixes fall back to their base type, so
+// "application/problem+json" uses the "application/json" decoder.
+func (c *codecRegistry) decoder(mediaType string) (DecoderFunc, string, bool) {
+	if dec, ok := c.decoders[mediaType]; ok {
+		return dec, mediaType, true
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		base := "application/" + mediaType[i+1:]
+		if dec, ok := c.decoders[base]; ok {
+			return dec, base, true
+		}
+	}
+	return nil, "", false
+}
+
+// negotiate selects the encoder for the Accept header. An empty header accepts
+// the first registered encoder. ok is false if no encoder is acceptable.
+func (c *codecRegistry) negotiate(accept string) (mediaEncoder, bool) {
+	if len(c.encoders) == 0 {
+		return mediaEncoder{}, false
+	}
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return c.encoders[0], true
+	}
+
+	best, bestQ, bestPos := -1, 0.0, 0
+	for i, e := range c.encoders {
+		q, pos := acceptQuality(ranges, e.mediaType)
+		if q <= 0 {
+			continue
+		}
+		if best < 0 || q > bestQ || (q == bestQ && pos < bestPos) {
+			best, bestQ, bestPos = i, q, pos
+		}
+	}
+	if best < 0 {
+		return mediaEncoder{}, false
+	}
+	return c.encoders[best], true
+}
+
+// mediaRange is a single entry of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header into its media ranges in header order.
+// Entries without a valid q parameter get a quality of 1; malformed entries
+// are skipped.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for part := range strings.SplitSeq(header, ",") {
+		fields := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(fields[0])), "/")
+		if !ok || typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+		mr := mediaRange{typ: typ, subtype: subtype, q: 1}
+		for _, param := range fields[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+				mr.q = q
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality the client assigns to mediaType and the
+// position of the range that determined it. The most specific matching range
+// wins, as described in RFC 9110. A quality of 0 means not acceptable.
+func acceptQuality(ranges []mediaRange, mediaType string) (float64, int) {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	q, pos, specificity := 0.0, len(ranges), -1
+	for i, mr := range ranges {
+		s := -1
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, pos, specificity = mr.q, i, s
+		}
+	}
+	return q, pos
+}
+
+// requestMediaType returns the lower-case media type of the request's
+// Content-Type header without parameters, or "" if it is absent.
+func requestMediaType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	return mediaType
+}
+
+// isFormMediaType reports whether the request body is bound as a form. Requests
+// without a Content-Type are treated as forms, so query values can be bound.
+func isFormMediaType(mediaType string) bool {
+	return mediaType == "" ||
+		mediaType == "application/x-www-form-urlencoded" ||
+		mediaType == "multipart/form-data"
+}
+
+// decodeBody decodes the request body with the decoder registered for mediaType.
+// An unsupported media type results in an *HTTPError with status 415.
+func (rc *ResponseContext) decodeBody(mediaType string, v any) error {
+	dec, _, ok := rc.router.codecs.decoder(mediaType)
+	if !ok {
+		return NewHTTPError(http.StatusUnsupportedMediaType,
+			fmt.Sprintf("unsupported content type %q", mediaType))
+	}
+	if rc.r.Body == nil {
+		return fmt.Errorf("request body is nil")
+	}
+	return dec(rc.r.Body, v)
+}
+
+// Negotiate sends data with the given status code, encoded in the media type the
+// client prefers according to the Accept header and its q-values. Encoders are
+// registered with Router.RegisterEncoder; JSON is available by default. Without
+// an Accept header the first registered encoder (JSON) is used. If no
+// registered media type is acceptable, Negotiate writes nothing and returns an
+// *HTTPError with status 406 Not Acceptable for the error handler. data is
+// encoded before anything is written, so an encoder error is returned with the
+// response still untouched and the error handler can pick the status.
+func (rc *ResponseContext) Negotiate(statusCode int, data any) error {
+	rc.w.Header().Add("Vary", "Accept")
+	enc, ok := rc.router.codecs.negotiate(rc.r.Header.Get("Accept"))
+	if !ok {
+		available := make([]string, len(rc.router.codecs.encoders))
+		for i, e := range rc.router.codecs.encoders {
+			available[i] = e.mediaType
+		}
+		return &HTTPError{
+			Status:  http.StatusNotAcceptable,
+			Details: map[string][]string{"available": available},
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := enc.encode(&buf, data); err != nil {
+		return err
+	}
+	contentType := enc.mediaType
+	if strings.HasPrefix(contentType, "text/") {
+		contentType += "; charset=utf-8"
+	}
+	rc.w.Header().Set("Content-Type", contentType)
+	rc.w.WriteHeader(statusCode)
+	_, err := buf.WriteTo(rc.w)
+	return err
+}
+
+// prefersJSON reports whether the Accept header asks for JSON explicitly, i.e.
+// through "application/json" or "application/*" rather than "*/*", and ranks it
+// at least as high as HTML.
+func prefersJSON(accept string) bool {
+	ranges := parseAccept(accept)
+	jsonQ, jsonPos := acceptQuality(ranges, "application/json")
+	if jsonQ <= 0 || ranges[jsonPos].typ == "*" {
+		return false
+	}
+	htmlQ, _ := acceptQuality(ranges, "text/html")
+	return jsonQ >= htmlQ
+}
+
+// isJSONMediaType reports whether mediaType is JSON, including structured
+// syntax suffixes such as "application/problem+json".
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,203 @@
+package nova
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestAcceptQuality tests q-value parsing and that the most specific range
+// determines the quality of a media type.
+func TestAcceptQuality(t *testing.T) {
+	cases := []struct {
+		accept    string
+		mediaType string
+		want      float64
+	}{
+		{"application/json", "application/json", 1},
+		{"application/json;q=0.5", "application/json", 0.5},
+		{"text/*;q=0.3, text/html;q=0.7, */*;q=0.1", "text/html", 0.7},
+		{"text/*;q=0.3, text/html;q=0.7, */*;q=0.1", "text/csv", 0.3},
+		{"text/*;q=0.3, text/html;q=0.7, */*;q=0.1", "application/json", 0.1},
+		{"application/json;q=0, */*", "application/json", 0},
+		{"APPLICATION/JSON; Q=0.4", "application/json", 0.4},
+		{"application/json;q=2", "application/json", 1},
+		{"text/html", "application/json", 0},
+		{"invalid, */json", "application/json", 0},
+	}
+	for _, c := range cases {
+		if got, _ := acceptQuality(parseAccept(c.accept), c.mediaType); got != c.want {
+			t.Errorf("quality of %s in %q = %v; want %v", c.mediaType, c.accept, got, c.want)
+		}
+	}
+}
+
+// TestNegotiate tests encoder selection, custom encoders and 406 responses.
+func TestNegotiate(t *testing.T) {
+	type point struct {
+		X int `json:"x" xml:"x"`
+		Y int `json:"y" xml:"y"`
+	}
+
+	r := NewRouter()
+	r.RegisterEncoder("text/csv", func(w io.Writer, v any) error {
+		cw := csv.NewWriter(w)
+		for _, p := range v.([]point) {
+			cw.Write([]string{fmt.Sprint(p.X), fmt.Sprint(p.Y)})
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	r.RegisterEncoder("application/xml", XMLEncoder)
+	r.Subrouter("/api").GetFunc("/points", func(rc *ResponseContext) error {
+		return rc.Negotiate(http.StatusOK, []point{{1, 2}, {3, 4}})
+	})
+	r.GetFunc("/map", func(rc *ResponseContext) error {
+		return rc.Negotiate(http.StatusCreated, map[string]int{"x": 1})
+	})
+
+	cases := []struct {
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{"", http.StatusOK, "application/json", `[{"x":1,"y":2},{"x":3,"y":4}]`},
+		{"*/*", http.StatusOK, "application/json", `[{"x":1,"y":2}`},
+		{"application/xml", http.StatusOK, "application/xml", `<point><x>1</x><y>2</y></point>`},
+		{"text/csv, application/json;q=0.9", http.StatusOK, "text/csv; charset=utf-8", "1,2\n3,4\n"},
+		{"application/xml;q=0.5, text/*;q=0.8", http.StatusOK, "text/csv; charset=utf-8", "1,2"},
+		{"application/xml, application/json", http.StatusOK, "application/xml", "<point>"},
+		{"image/png", http.StatusNotAcceptable, "", "Not Acceptable"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/api/points", nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		if rr.Code != c.wantStatus {
+			t.Errorf("Accept %q: status = %d; want %d", c.accept, rr.Code, c.wantStatus)
+		}
+		if c.wantContentType != "" && rr.Header().Get("Content-Type") != c.wantContentType {
+			t.Errorf("Accept %q: Content-Type = %q; want %q", c.accept, rr.Header().Get("Content-Type"), c.wantContentType)
+		}
+		if !strings.Contains(rr.Body.String(), c.wantBody) {
+			t.Errorf("Accept %q: body = %q; want it to contain %q", c.accept, rr.Body.String(), c.wantBody)
+		}
+		if rr.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q; want Accept", c.accept, rr.Header().Get("Vary"))
+		}
+	}
+
+	// An encoder error reaches the error handler before anything is written.
+	req := httptest.NewRequest("GET", "/map", nil)
+	req.Header.Set("Accept", "application/xml")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Header().Get("Content-Type"), "xml") {
+		t.Errorf("unencodable map: got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	// XML is opt-in.
+	d := NewRouter()
+	d.GetFunc("/map", func(rc *ResponseContext) error {
+		return rc.Negotiate(http.StatusOK, map[string]int{"x": 1})
+	})
+	req = httptest.NewRequest("GET", "/map", nil)
+	req.Header.Set("Accept", "application/xml")
+	rr = httptest.NewRecorder()
+	d.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotAcceptable {
+		t.Errorf("default router, Accept application/xml: status = %d; want 406", rr.Code)
+	}
+}
+
+// TestBindDecoders tests that Bind and BindValidated pick the decoder by
+// Content-Type and reject unsupported types with 415.
+func TestBindDecoders(t *testing.T) {
+	type note struct {
+		Text string `json:"text" xml:"text"`
+	}
+
+	r := NewRouter()
+	r.RegisterDecoder("application/xml", XMLDecoder)
+	r.RegisterDecoder("text/plain", func(body io.Reader, v any) error {
+		data, err := io.ReadAll(body)
+		v.(*note).Text = string(data)
+		return err
+	})
+	r.PostFunc("/validated", func(rc *ResponseContext) error {
+		var n note
+		if err := rc.BindValidated(&n); err != nil {
+			return err
+		}
+		return rc.Text(http.StatusOK, n.Text)
+	})
+	r.PostFunc("/plain", func(rc *ResponseContext) error {
+		var n note
+		if err := rc.Bind(&n); err != nil {
+			return err
+		}
+		return rc.Text(http.StatusOK, n.Text)
+	})
+
+	cases := []struct {
+		path, contentType, body string
+		wantStatus              int
+		wantBody                string
+	}{
+		{"/validated", "application/json; charset=utf-8", `{"text":"json"}`, http.StatusOK, "json"},
+		{"/validated", "application/merge-patch+json", `{"text":"patch"}`, http.StatusOK, "patch"},
+		{"/validated", "application/xml", `<note><text>xml</text></note>`, http.StatusOK, "xml"},
+		{"/validated", "text/plain", "plain", http.StatusOK, "plain"},
+		{"/validated", "application/x-www-form-urlencoded", "text=form", http.StatusOK, "form"},
+		{"/validated", "application/xml", `<note>`, http.StatusBadRequest, "invalid request body"},
+		{"/validated", "application/yaml", "text: yaml", http.StatusUnsupportedMediaType, `unsupported content type "application/yaml"`},
+		{"/plain", "application/yaml", "text: yaml", http.StatusUnsupportedMediaType, "unsupported content type"},
+		{"/plain", "application/xml", `<note><text>xml</text></note>`, http.StatusOK, "xml"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", c.path, strings.NewReader(c.body))
+		req.Header.Set("Content-Type", c.contentType)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != c.wantStatus || !strings.Contains(rr.Body.String(), c.wantBody) {
+			t.Errorf("POST %s (%s): got %d %q; want %d containing %q",
+				c.path, c.contentType, rr.Code, rr.Body.String(), c.wantStatus, c.wantBody)
+		}
+	}
+}
+
+// TestWantsJSON tests that WantsJSON honours q-values and ignores bare wildcards.
+func TestWantsJSON(t *testing.T) {
+	cases := []struct {
+		contentType, accept string
+		want                bool
+	}{
+		{"", "", false},
+		{"application/json", "", true},
+		{"application/problem+json", "", true},
+		{"", "application/json", true},
+		{"", "*/*", false},
+		{"", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"", "application/json, text/plain, */*", true},
+		{"", "text/html, application/json;q=0.5", false},
+		{"", "application/json;q=0", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Content-Type", c.contentType)
+		req.Header.Set("Accept", c.accept)
+		rc := &ResponseContext{w: httptest.NewRecorder(), r: req, router: NewRouter()}
+		if got := rc.WantsJSON(); got != c.want {
+			t.Errorf("WantsJSON(Content-Type %q, Accept %q) = %v; want %v", c.contentType, c.accept, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Package novanorm registers the Unicode normalization forms of
+// golang.org/x/text/unicode/norm for nova's normalize struct tag.
+//
+// Nova itself ships no normalization tables so that it stays free of
+// dependencies. Import this package for its side effect to enable the NFC,
+// NFD, NFKC and NFKD forms:
+//
+//	import _ "github.com/xlc-dev/nova/nova/novanorm"
+//
+//	type Signup struct {
+//		Name string `json:"name" trim:"true" normalize:"NFC" maxlength:"40"`
+//	}
+package novanorm
+
+import (
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// forms maps the normalize tag names to their x/text forms.
+var forms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+func init() {
+	for name, f := range forms {
+		nova.RegisterNormalizer(name, f.String)
+	}
+}
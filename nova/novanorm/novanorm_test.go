@@ -0,0 +1,82 @@
+package novanorm
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+type profile struct {
+	Name    string `json:"name" trim:"true" normalize:"NFC" maxlength:"5"`
+	Decomp  string `json:"decomp,omitempty" normalize:"nfd"`
+	Compat  string `json:"compat,omitempty" normalize:"NFKC"`
+	Ligated string `json:"ligated,omitempty" normalize:"NFKD"`
+}
+
+func TestNormalize(t *testing.T) {
+	var got profile
+	r := nova.NewRouter()
+	r.PostFunc("/profile", func(rc *nova.ResponseContext) error {
+		if err := rc.BindValidated(&got); err != nil {
+			return err
+		}
+		return rc.JSON(http.StatusOK, got)
+	})
+
+	tests := []struct {
+		name   string
+		body   profile
+		status int
+		want   profile
+	}{
+		{
+			// Five code points decomposed, four once composed.
+			"combining accent composed",
+			profile{Name: " Rene\u0301e "},
+			http.StatusOK,
+			profile{Name: "Ren\u00e9e"},
+		},
+		{
+			"angstrom sign and conjoining jamo",
+			profile{Name: "\u212b\u1100\u1161"},
+			http.StatusOK,
+			profile{Name: "\u00c5\uac00"},
+		},
+		{
+			"decomposed and compatibility forms",
+			profile{Name: "a", Decomp: "\u00e9", Compat: "\ufb01\u2460", Ligated: "\ufb03\u00e9"},
+			http.StatusOK,
+			profile{Name: "a", Decomp: "e\u0301", Compat: "fi1", Ligated: "ffie\u0301"},
+		},
+		{
+			// Still six code points after composition.
+			"too long after composing",
+			profile{Name: "Rene\u0301es"},
+			http.StatusUnprocessableEntity,
+			profile{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = profile{}
+			body, err := json.Marshal(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/profile", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK && got != tt.want {
+				t.Errorf("bound = %+q, want %+q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,556 @@
+// Package novatest provides an in-process test client for nova routers.
+//
+// A Client sends requests straight to a *nova.Router without opening a
+// socket, keeps cookies between requests like a browser and records which
+// routes were exercised:
+//
+//	func TestCreateUser(t *testing.T) {
+//		c := novatest.New(t, api.NewRouter())
+//		c.Post("/users").JSON(map[string]any{"name": "Ada"}).Do().
+//			AssertStatus(http.StatusCreated).
+//			AssertJSONPath("name", "Ada")
+//	}
+package novatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// DefaultHost is the Host of requests sent by a Client unless overridden.
+const DefaultHost = "example.com"
+
+// Client sends requests to a router in process. Cookies set by responses are
+// stored in a cookie jar and sent with later requests, so flows that depend on
+// cookies, such as sessions or CSRFMiddleware, work across calls.
+type Client struct {
+	// t reports failed assertions.
+	t testing.TB
+	// router serves the requests.
+	router *nova.Router
+	// jar stores the cookies set by responses.
+	jar *cookiejar.Jar
+	// header is sent with every request.
+	header http.Header
+	// coverage records the matched routes.
+	coverage *Coverage
+}
+
+// New returns a Client for router that reports failures to t and records
+// the routes it exercises in DefaultCoverage.
+func New(t testing.TB, router *nova.Router) *Client {
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		t:        t,
+		router:   router,
+		jar:      jar,
+		header:   make(http.Header),
+		coverage: DefaultCoverage,
+	}
+}
+
+// SetHeader sets a header sent with every request, e.g. an Authorization header.
+func (c *Client) SetHeader(name, value string) {
+	c.header.Set(name, value)
+}
+
+// SetCoverage makes the client record the routes it exercises in cov instead
+// of DefaultCoverage. A nil Coverage disables recording.
+func (c *Client) SetCoverage(cov *Coverage) {
+	c.coverage = cov
+}
+
+// Cookie returns the named cookie stored for host, or DefaultHost if host is
+// empty, or nil if there is none.
+func (c *Client) Cookie(host, name string) *http.Cookie {
+	if host == "" {
+		host = DefaultHost
+	}
+	for _, cookie := range c.jar.Cookies(&url.URL{Scheme: "http", Host: host, Path: "/"}) {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// ClearCookies removes every stored cookie.
+func (c *Client) ClearCookies() {
+	c.jar, _ = cookiejar.New(nil)
+}
+
+// Request starts a request with the given method and path. The path may
+// contain a query string.
+func (c *Client) Request(method, path string) *Request {
+	return &Request{client: c, method: method, path: path, header: c.header.Clone(), query: make(url.Values)}
+}
+
+// Get starts a GET request.
+func (c *Client) Get(path string) *Request { return c.Request(http.MethodGet, path) }
+
+// Post starts a POST request.
+func (c *Client) Post(path string) *Request { return c.Request(http.MethodPost, path) }
+
+// Put starts a PUT request.
+func (c *Client) Put(path string) *Request { return c.Request(http.MethodPut, path) }
+
+// Patch starts a PATCH request.
+func (c *Client) Patch(path string) *Request { return c.Request(http.MethodPatch, path) }
+
+// Delete starts a DELETE request.
+func (c *Client) Delete(path string) *Request { return c.Request(http.MethodDelete, path) }
+
+// Head starts a HEAD request.
+func (c *Client) Head(path string) *Request { return c.Request(http.MethodHead, path) }
+
+// Options starts an OPTIONS request.
+func (c *Client) Options(path string) *Request { return c.Request(http.MethodOptions, path) }
+
+// Unexercised returns the routes of the client's router that no client
+// sharing its coverage has exercised yet.
+func (c *Client) Unexercised() []nova.RouteInfo {
+	return c.coverage.Unexercised(c.router)
+}
+
+// Request is a request being built. Its methods return the request so calls
+// can be chained, ending with Do.
+type Request struct {
+	client *Client
+	method string
+	path   string
+	host   string
+	header http.Header
+	query  url.Values
+	// body and contentType are set by JSON, Form and Body.
+	body        []byte
+	contentType string
+	// fields and files are set by Field and File for a multipart body.
+	fields []formField
+	files  []formFile
+	// err is the first error building the request, reported by Do.
+	err error
+}
+
+// formField is a multipart form value.
+type formField struct {
+	name, value string
+}
+
+// formFile is a file of a multipart form.
+type formFile struct {
+	field, filename string
+	content         []byte
+}
+
+// Header sets a request header.
+func (r *Request) Header(name, value string) *Request {
+	r.header.Set(name, value)
+	return r
+}
+
+// Host sets the Host of the request. Defaults to DefaultHost.
+func (r *Request) Host(host string) *Request {
+	r.host = host
+	return r
+}
+
+// Query adds a query parameter.
+func (r *Request) Query(name, value string) *Request {
+	r.query.Add(name, value)
+	return r
+}
+
+// Cookie adds a cookie to the request, in addition to those in the jar.
+func (r *Request) Cookie(cookie *http.Cookie) *Request {
+	r.header.Add("Cookie", cookie.String())
+	return r
+}
+
+// JSON sets the body to v encoded as JSON.
+func (r *Request) JSON(v any) *Request {
+	body, err := json.Marshal(v)
+	if err != nil && r.err == nil {
+		r.err = fmt.Errorf("encoding JSON body: %w", err)
+	}
+	return r.Body("application/json", body)
+}
+
+// Form sets the body to the URL-encoded values.
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// Field adds a value to a multipart/form-data body.
+func (r *Request) Field(name, value string) *Request {
+	r.fields = append(r.fields, formField{name, value})
+	return r
+}
+
+// File adds a file to a multipart/form-data body.
+func (r *Request) File(field, filename string, content []byte) *Request {
+	r.files = append(r.files, formFile{field, filename, content})
+	return r
+}
+
+// Body sets the raw body and its content type.
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.contentType = contentType
+	r.body = body
+	return r
+}
+
+// build returns the http.Request described by r.
+func (r *Request) build() (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	body, contentType := r.body, r.contentType
+	if len(r.fields) > 0 || len(r.files) > 0 {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for _, f := range r.fields {
+			if err := mw.WriteField(f.name, f.value); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range r.files {
+			fw, err := mw.CreateFormFile(f.field, f.filename)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := fw.Write(f.content); err != nil {
+				return nil, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return nil, err
+		}
+		body, contentType = buf.Bytes(), mw.FormDataContentType()
+	}
+
+	target, err := url.Parse(r.path)
+	if err != nil {
+		return nil, err
+	}
+	query := target.Query()
+	for name, values := range r.query {
+		query[name] = append(query[name], values...)
+	}
+	target.RawQuery = query.Encode()
+
+	req := httptest.NewRequest(r.method, target.RequestURI(), bytes.NewReader(body))
+	req.Host = DefaultHost
+	if r.host != "" {
+		req.Host = r.host
+	}
+	req.Header = r.header.Clone()
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, cookie := range r.client.jar.Cookies(r.cookieURL(req)) {
+		req.AddCookie(cookie)
+	}
+	return req, nil
+}
+
+// cookieURL returns the URL cookies of req are stored under.
+func (r *Request) cookieURL(req *http.Request) *url.URL {
+	return &url.URL{Scheme: "http", Host: req.Host, Path: req.URL.Path}
+}
+
+// Do sends the request to the router and returns the recorded response. It
+// stops the test if the request cannot be built.
+func (r *Request) Do() *Response {
+	t := r.client.t
+	t.Helper()
+	req, err := r.build()
+	if err != nil {
+		t.Fatalf("novatest: %s %s: %v", r.method, r.path, err)
+	}
+	if cov := r.client.coverage; cov != nil {
+		req = req.WithContext(nova.WithRouteObserver(req.Context(), cov.record))
+	}
+
+	rec := httptest.NewRecorder()
+	r.client.router.ServeHTTP(rec, req)
+	res := rec.Result()
+	r.client.jar.SetCookies(r.cookieURL(req), res.Cookies())
+
+	return &Response{
+		t:          t,
+		Method:     r.method,
+		Path:       r.path,
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       rec.Body.Bytes(),
+		cookies:    res.Cookies(),
+	}
+}
+
+// Response is a recorded response. Its assertion methods report failures with
+// t.Errorf and return the response, so they can be chained.
+type Response struct {
+	t testing.TB
+	// Method and Path identify the request in failure messages.
+	Method string
+	Path   string
+	// StatusCode, Header and Body are the recorded response.
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	cookies    []*http.Cookie
+}
+
+// String returns the body as a string.
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// Cookie returns the named cookie set by the response, or nil.
+func (r *Response) Cookie(name string) *http.Cookie {
+	for _, cookie := range r.cookies {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+// DecodeJSON decodes the body into v. It stops the test if the body is not
+// valid JSON for v.
+func (r *Response) DecodeJSON(v any) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		r.t.Fatalf("%s %s: decoding JSON body %q: %v", r.Method, r.Path, r.Body, err)
+	}
+	return r
+}
+
+// JSONPath returns the value at path in the JSON body. A path is a list of
+// object keys and array indexes separated by dots, e.g. "items.0.name"; the
+// empty path is the whole body. Numbers are float64, as with encoding/json.
+func (r *Response) JSONPath(path string) (any, bool) {
+	var v any
+	if err := json.Unmarshal(r.Body, &v); err != nil {
+		return nil, false
+	}
+	return lookupPath(v, path)
+}
+
+// lookupPath returns the value at the dotted path in v.
+func lookupPath(v any, path string) (any, bool) {
+	if path == "" {
+		return v, true
+	}
+	for key := range strings.SplitSeq(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// AssertStatus checks the status code.
+func (r *Response) AssertStatus(code int) *Response {
+	r.t.Helper()
+	if r.StatusCode != code {
+		r.t.Errorf("%s %s: status = %d; want %d, body %q", r.Method, r.Path, r.StatusCode, code, r.Body)
+	}
+	return r
+}
+
+// AssertHeader checks that the header has the given value.
+func (r *Response) AssertHeader(name, want string) *Response {
+	r.t.Helper()
+	if got := r.Header.Get(name); got != want {
+		r.t.Errorf("%s %s: header %s = %q; want %q", r.Method, r.Path, name, got, want)
+	}
+	return r
+}
+
+// AssertBodyContains checks that the body contains s.
+func (r *Response) AssertBodyContains(s string) *Response {
+	r.t.Helper()
+	if !bytes.Contains(r.Body, []byte(s)) {
+		r.t.Errorf("%s %s: body %q does not contain %q", r.Method, r.Path, r.Body, s)
+	}
+	return r
+}
+
+// AssertJSONPath checks the value at path in the JSON body, see JSONPath. want
+// is compared after a round trip through JSON, so any value encoding to the
+// same JSON matches, e.g. 42 matches the number 42 and a struct matches an
+// object with the same fields.
+func (r *Response) AssertJSONPath(path string, want any) *Response {
+	r.t.Helper()
+	got, ok := r.JSONPath(path)
+	if !ok {
+		r.t.Errorf("%s %s: no JSON value at %q in body %q", r.Method, r.Path, path, r.Body)
+		return r
+	}
+	normalized, err := roundTrip(want)
+	if err != nil {
+		r.t.Errorf("%s %s: encoding expected value for %q: %v", r.Method, r.Path, path, err)
+		return r
+	}
+	if !reflect.DeepEqual(got, normalized) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(normalized)
+		r.t.Errorf("%s %s: JSON value at %q = %s; want %s", r.Method, r.Path, path, gotJSON, wantJSON)
+	}
+	return r
+}
+
+// roundTrip encodes v as JSON and decodes it into an untyped value.
+func roundTrip(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// ValidationErrors returns the validation messages of a 422 response written
+// by nova's error handlers: the "details" of a JSON or problem details body,
+// or the lines after the first of a plain text body.
+func (r *Response) ValidationErrors() []string {
+	if details, ok := r.JSONPath("details"); ok {
+		var msgs []string
+		if list, ok := details.([]any); ok {
+			for _, d := range list {
+				msgs = append(msgs, fmt.Sprint(d))
+			}
+		}
+		return msgs
+	}
+	lines := strings.Split(strings.TrimSpace(string(r.Body)), "\n")
+	return lines[1:]
+}
+
+// AssertValidationErrors checks that the response is a 422 Unprocessable
+// Entity and that, for every entry of want, a validation message contains it.
+// Pass field names to check which fields failed, or full messages.
+func (r *Response) AssertValidationErrors(want ...string) *Response {
+	r.t.Helper()
+	if r.StatusCode != http.StatusUnprocessableEntity {
+		r.t.Errorf("%s %s: status = %d; want %d, body %q", r.Method, r.Path, r.StatusCode,
+			http.StatusUnprocessableEntity, r.Body)
+		return r
+	}
+	msgs := r.ValidationErrors()
+	for _, w := range want {
+		if !slices.ContainsFunc(msgs, func(m string) bool { return strings.Contains(m, w) }) {
+			r.t.Errorf("%s %s: no validation error mentions %q, got %q", r.Method, r.Path, w, msgs)
+		}
+	}
+	return r
+}
+
+// Coverage records which routes were matched by requests of the clients
+// using it. It is safe for concurrent use.
+type Coverage struct {
+	mu   sync.Mutex
+	hits map[string]int
+}
+
+// DefaultCoverage is the Coverage used by clients created with New. It
+// collects the routes exercised by every test of a package, to be checked in
+// TestMain once all tests ran.
+var DefaultCoverage = NewCoverage()
+
+// NewCoverage returns an empty Coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{hits: make(map[string]int)}
+}
+
+// record counts a request matched by rt.
+func (c *Coverage) record(rt *nova.MatchedRoute) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hits[coverageKey(rt.Method, rt.Pattern)]++
+}
+
+// coverageKey identifies a route by method and pattern. Routes that only
+// differ in their host, header or version conditions share a key.
+func coverageKey(method, pattern string) string {
+	return method + " " + pattern
+}
+
+// Hits returns how many requests matched the route with the given method and
+// pattern. Routes that only differ in their host, header or version conditions
+// are counted together.
+func (c *Coverage) Hits(method, pattern string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits[coverageKey(method, pattern)]
+}
+
+// Unexercised returns the routes of router that no request has matched. A GET
+// route answering a HEAD request counts as exercised.
+func (c *Coverage) Unexercised(router *nova.Router) []nova.RouteInfo {
+	var hits map[string]int
+	if c != nil {
+		c.mu.Lock()
+		hits = maps.Clone(c.hits)
+		c.mu.Unlock()
+	}
+
+	var routes []nova.RouteInfo
+	for _, rt := range router.Routes() {
+		if hits[coverageKey(rt.Method, rt.Pattern)] == 0 {
+			routes = append(routes, rt)
+		}
+	}
+	return routes
+}
+
+// Report writes the routes of router that no request has matched to w, as a
+// table, and returns how many there are. It writes nothing if every route was
+// exercised.
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		novatest.DefaultCoverage.Report(os.Stderr, api.NewRouter())
+//		os.Exit(code)
+//	}
+func (c *Coverage) Report(w io.Writer, router *nova.Router) (int, error) {
+	routes := c.Unexercised(router)
+	if len(routes) == 0 {
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(w, "%d of %d routes were never exercised:\n", len(routes), len(router.Routes())); err != nil {
+		return len(routes), err
+	}
+	return len(routes), nova.WriteRoutes(w, routes, "table")
+}
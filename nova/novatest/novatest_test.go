@@ -0,0 +1,184 @@
+package novatest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xlc-dev/nova/nova"
+)
+
+// recorder is a testing.TB that records failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+type user struct {
+	Name  string `json:"name" minlength:"2"`
+	Email string `json:"email" format:"email"`
+}
+
+func newRouter() *nova.Router {
+	r := nova.NewRouter()
+	r.PostFunc("/users", func(rc *nova.ResponseContext) error {
+		var u user
+		if err := rc.BindValidated(&u); err != nil {
+			return err
+		}
+		return rc.JSON(http.StatusCreated, map[string]any{"user": u, "tags": []string{"new"}})
+	})
+	r.PostFunc("/upload", func(rc *nova.ResponseContext) error {
+		file, header, err := rc.Request().FormFile("doc")
+		if err != nil {
+			return err
+		}
+		data, _ := io.ReadAll(file)
+		return rc.Text(http.StatusOK, rc.Request().FormValue("title")+" "+header.Filename+" "+string(data))
+	})
+	r.GetFunc("/search", func(rc *nova.ResponseContext) error {
+		return rc.Text(http.StatusOK, rc.Request().URL.Query().Get("q")+" "+rc.Request().Host)
+	})
+	r.Get("/unused", func(w http.ResponseWriter, req *http.Request) {})
+
+	forms := r.Subrouter("/forms")
+	forms.Use(nova.CSRFMiddleware(nil))
+	forms.GetFunc("/", func(rc *nova.ResponseContext) error {
+		return rc.Text(http.StatusOK, "form")
+	})
+	forms.PostFunc("/", func(rc *nova.ResponseContext) error {
+		return rc.Text(http.StatusOK, "saved")
+	})
+	return r
+}
+
+// TestClientBuilders tests the JSON, form, multipart and query builders and
+// the JSON path assertions.
+func TestClientBuilders(t *testing.T) {
+	c := New(t, newRouter())
+	c.SetCoverage(NewCoverage())
+
+	c.Post("/users").Header("Accept", "application/json").
+		JSON(user{Name: "Ada", Email: "ada@example.com"}).Do().
+		AssertStatus(http.StatusCreated).
+		AssertHeader("Content-Type", "application/json").
+		AssertJSONPath("user.name", "Ada").
+		AssertJSONPath("tags.0", "new").
+		AssertJSONPath("user", map[string]string{"name": "Ada", "email": "ada@example.com"})
+
+	c.Post("/users").Form(url.Values{"name": {"Bo"}, "email": {"bo@example.com"}}).Do().
+		AssertStatus(http.StatusCreated).
+		AssertJSONPath("user.email", "bo@example.com")
+
+	c.Post("/upload").Field("title", "Report").File("doc", "r.txt", []byte("contents")).Do().
+		AssertStatus(http.StatusOK).
+		AssertBodyContains("Report r.txt contents")
+
+	c.Get("/search?q=a").Query("page", "2").Host("api.example.org").Do().
+		AssertBodyContains("a api.example.org")
+}
+
+// TestClientAssertionsFail tests that failed assertions are reported.
+func TestClientAssertionsFail(t *testing.T) {
+	rec := &recorder{TB: t}
+	c := New(rec, newRouter())
+	c.SetCoverage(nil)
+
+	c.Post("/users").JSON(user{Name: "Ada", Email: "ada@example.com"}).Do().
+		AssertStatus(http.StatusOK).
+		AssertHeader("X-Missing", "1").
+		AssertJSONPath("user.name", "Bob").
+		AssertJSONPath("user.age", 1).
+		AssertBodyContains("nope")
+	if len(rec.errors) != 5 {
+		t.Errorf("recorded %d failures; want 5: %q", len(rec.errors), rec.errors)
+	}
+}
+
+// TestClientValidationErrors tests the validation assertions for JSON and
+// plain text error responses.
+func TestClientValidationErrors(t *testing.T) {
+	c := New(t, newRouter())
+	c.SetCoverage(NewCoverage())
+
+	res := c.Post("/users").Header("Accept", "application/json").
+		JSON(user{Name: "A", Email: "nope"}).Do().
+		AssertValidationErrors("'name'", "'email'")
+	if msgs := res.ValidationErrors(); len(msgs) != 2 {
+		t.Errorf("ValidationErrors() = %q", msgs)
+	}
+
+	c.Post("/users").JSON(user{Name: "A", Email: "a@example.com"}).Do().
+		AssertValidationErrors("'name'")
+
+	rec := &recorder{TB: t}
+	New(rec, newRouter()).Post("/users").JSON(user{Name: "Ada", Email: "a@example.com"}).Do().
+		AssertValidationErrors("'name'")
+	if len(rec.errors) != 1 {
+		t.Errorf("recorded %q; want a status failure", rec.errors)
+	}
+}
+
+// TestClientCookies tests that cookies persist across requests, which the
+// CSRF double submit flow relies on.
+func TestClientCookies(t *testing.T) {
+	c := New(t, newRouter())
+	c.SetCoverage(NewCoverage())
+
+	res := c.Get("/forms/").Do().AssertStatus(http.StatusOK)
+	if res.Cookie("_csrf") == nil {
+		t.Fatal("response did not set the CSRF cookie")
+	}
+	c.Post("/forms/").Do().AssertStatus(http.StatusForbidden)
+	token := c.Cookie("", "_csrf")
+	if token == nil {
+		t.Fatal("CSRF cookie not stored in the jar")
+	}
+	c.Post("/forms/").Header("X-CSRF-Token", token.Value).Do().
+		AssertStatus(http.StatusOK).
+		AssertBodyContains("saved")
+
+	c.ClearCookies()
+	c.Post("/forms/").Header("X-CSRF-Token", token.Value).Do().AssertStatus(http.StatusForbidden)
+}
+
+// TestCoverage tests that routes matched by requests are recorded and the
+// others are reported.
+func TestCoverage(t *testing.T) {
+	router := newRouter()
+	cov := NewCoverage()
+	c := New(t, router)
+	c.SetCoverage(cov)
+
+	c.Get("/search").Do()
+	c.Head("/search").Do()
+	c.Get("/forms/").Do()
+	c.Get("/missing").Do().AssertStatus(http.StatusNotFound)
+
+	if hits := cov.Hits("GET", "/search"); hits != 2 {
+		t.Errorf("GET /search hits = %d; want 2", hits)
+	}
+	var unexercised []string
+	for _, rt := range c.Unexercised() {
+		unexercised = append(unexercised, rt.Method+" "+rt.Pattern)
+	}
+	if got := strings.Join(unexercised, ", "); got != "POST /users, POST /upload, GET /unused, POST /forms" {
+		t.Errorf("Unexercised() = %s", got)
+	}
+
+	var out strings.Builder
+	n, err := cov.Report(&out, router)
+	if err != nil || n != 4 || !strings.HasPrefix(out.String(), "4 of 6 routes were never exercised:") ||
+		!strings.Contains(out.String(), "/unused") {
+		t.Errorf("Report() = %d, %v, %q", n, err, out.String())
+	}
+}
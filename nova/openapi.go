@@ -18,17 +18,34 @@ import (
 )
 
 // OpenAPIConfig holds metadata for generating an OpenAPI specification.
+// When ProblemDetails is set, every operation documents a default error
+// response in the RFC 9457 problem details format. Servers are listed at the
+// top level of the spec; operations restricted to a host pattern get their
+// own servers entry instead.
 type OpenAPIConfig struct {
-	Title       string
-	Version     string
-	Description string
+	Title          string
+	Version        string
+	Description    string
+	ProblemDetails bool
+	Servers        []ServerObject
 }
 
 // RouteOptions holds OpenAPI metadata for a single route.
 // Tags, Summary, Description, and OperationID map directly into the
 // corresponding Operation fields. RequestBody, Responses, and Parameters
 // drive schema generation for request bodies, responses, and parameters.
+// Fields of RequestBody tagged with path, query, header or cookie are
+// documented as parameters instead of body properties. A RequestBody with
+// *multipart.FileHeader fields is documented as "multipart/form-data".
+// Name registers the route for reverse URL building with Router.URL.
+// Host and Headers restrict the route to matching requests, like Router.Host
+// and Router.Headers do for subrouters. Middleware wraps only this route's
+// handler, inside the router and group middleware.
 type RouteOptions struct {
+	Name        string
+	Host        string
+	Headers     map[string]string
+	Middleware  []Middleware
 	Tags        []string
 	Summary     string
 	Description string
@@ -41,6 +58,7 @@ type RouteOptions struct {
 
 // ResponseOption configures a single HTTP response in an Operation.
 // Description is required; Body, if non-nil, is used to generate a schema.
+// A ProblemDetails body is documented as "application/problem+json".
 type ResponseOption struct {
 	Description string
 	Body        any
@@ -62,6 +80,7 @@ type ParameterOption struct {
 type OpenAPI struct {
 	OpenAPI    string               `json:"openapi"`
 	Info       Info                 `json:"info"`
+	Servers    []ServerObject       `json:"servers,omitempty"`
 	Paths      map[string]*PathItem `json:"paths"`
 	Components *Components          `json:"components,omitempty"`
 }
@@ -73,6 +92,21 @@ type Info struct {
 	Description string `json:"description,omitempty"`
 }
 
+// ServerObject describes a server the API is available on. The URL may contain
+// variables in braces, e.g. "https://{tenant}.example.com".
+type ServerObject struct {
+	URL         string                     `json:"url"`
+	Description string                     `json:"description,omitempty"`
+	Variables   map[string]*ServerVariable `json:"variables,omitempty"`
+}
+
+// ServerVariable describes a variable of a server URL.
+type ServerVariable struct {
+	Default     string   `json:"default"`
+	Enum        []string `json:"enum,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
 // PathItem describes the operations available on a single API path.
 // One of Get, Post, Put, Delete, Patch may be non-nil.
 type PathItem struct {
@@ -96,6 +130,7 @@ type Operation struct {
 	RequestBody *RequestBodyObject         `json:"requestBody,omitempty"`
 	Responses   map[string]*ResponseObject `json:"responses"`
 	Deprecated  bool                       `json:"deprecated,omitempty"`
+	Servers     []ServerObject             `json:"servers,omitempty"`
 }
 
 // ParameterObject describes a single parameter for an Operation or PathItem.
@@ -172,14 +207,16 @@ func newSchemaGenCtx() *schemaGenCtx {
 
 // collectRoutes traverses the router hierarchy starting at r, and populates
 // the OpenAPI spec with PathItems and Operations based on registered routes.
-func collectRoutes(r *Router, spec *OpenAPI, schemaCtx *schemaGenCtx, parentPath string) {
-	for _, route := range r.routes {
-		fullPath := buildPathString(route.segments, parentPath)
-		if fullPath == "" {
-			fullPath = "/"
-		} else if !strings.HasPrefix(fullPath, "/") {
-			fullPath = "/" + fullPath
-		}
+func collectRoutes(r *Router, spec *OpenAPI, schemaCtx *schemaGenCtx) {
+	walkRoutes(r, nil, func(_ *Router, rt *route, conds []*matcher) {
+		addRoute(spec, schemaCtx, rt, conds)
+	})
+}
+
+// addRoute adds the operations of rt, one per path variant, to the spec.
+func addRoute(spec *OpenAPI, schemaCtx *schemaGenCtx, rt *route, conds []*matcher) {
+	for _, route := range pathVariants(rt) {
+		fullPath := buildPathString(route.segments)
 
 		pathItem, exists := spec.Paths[fullPath]
 		if !exists {
@@ -188,52 +225,153 @@ func collectRoutes(r *Router, spec *OpenAPI, schemaCtx *schemaGenCtx, parentPath
 		}
 
 		op := buildOperation(route, schemaCtx)
+		if route != rt {
+			// Operation IDs must be unique, so only the full path keeps it,
+			// and the omitted optional parameters are not in this path.
+			op.OperationID = ""
+			op.Parameters = slices.DeleteFunc(op.Parameters, func(p ParameterObject) bool {
+				return p.In == "path" && !strings.Contains(fullPath, "{"+p.Name+"}")
+			})
+		}
+		applyConditions(op, conds)
 
+		var slot **Operation
 		switch route.method {
 		case http.MethodGet:
-			pathItem.Get = op
+			slot = &pathItem.Get
 		case http.MethodPost:
-			pathItem.Post = op
+			slot = &pathItem.Post
 		case http.MethodPut:
-			pathItem.Put = op
+			slot = &pathItem.Put
 		case http.MethodDelete:
-			pathItem.Delete = op
+			slot = &pathItem.Delete
 		case http.MethodPatch:
-			pathItem.Patch = op
+			slot = &pathItem.Patch
+		default:
+			continue
 		}
+		// The same path served on several hosts is documented once, with
+		// the servers of every host. Operation servers replace the top-level
+		// ones, so a route without a host condition adds those.
+		if existing := *slot; existing != nil && (len(existing.Servers) > 0 || len(op.Servers) > 0) {
+			servers := operationServers(spec, existing)
+			for _, srv := range operationServers(spec, op) {
+				if !slices.ContainsFunc(servers, func(s ServerObject) bool { return s.URL == srv.URL }) {
+					servers = append(servers, srv)
+				}
+			}
+			existing.Servers = servers
+			continue
+		}
+		*slot = op
 	}
+}
 
-	for _, sr := range r.subrouters {
-		collectRoutes(sr, spec, schemaCtx, sr.basePath)
+// operationServers returns the servers op is served on: its own, or else the
+// top-level servers of spec, which default to "/" in OpenAPI.
+func operationServers(spec *OpenAPI, op *Operation) []ServerObject {
+	switch {
+	case len(op.Servers) > 0:
+		return op.Servers
+	case len(spec.Servers) > 0:
+		return slices.Clone(spec.Servers)
 	}
+	return []ServerObject{{URL: "/"}}
 }
 
-// buildPathString constructs a URI path string from segments and an optional
-// parent prefix. Parameters are wrapped in curly braces, e.g., "{id}".
-func buildPathString(segments []segment, prefix string) string {
-	var parts []string
-	if prefix != "" {
-		parts = append(parts, strings.Trim(prefix, "/"))
+// applyConditions documents the host and header conditions of a route. The
+// innermost host pattern becomes the operation's server, and required headers
+// become header parameters.
+func applyConditions(op *Operation, conds []*matcher) {
+	for i := len(conds) - 1; i >= 0; i-- {
+		if conds[i].host != nil {
+			op.Servers = []ServerObject{hostServer(conds[i])}
+			break
+		}
 	}
-	for _, seg := range segments {
-		if seg.isParam {
-			parts = append(parts, fmt.Sprintf("{%s}", seg.paramName))
-		} else {
-			parts = append(parts, seg.literal)
+
+	for _, m := range conds {
+		for _, h := range m.headers {
+			// OpenAPI ignores header parameters with these names.
+			if h.name == "Accept" || h.name == "Content-Type" || h.name == "Authorization" {
+				continue
+			}
+			if slices.ContainsFunc(op.Parameters, func(p ParameterObject) bool {
+				return p.In == "header" && strings.EqualFold(p.Name, h.name)
+			}) {
+				continue
+			}
+			schema := &SchemaObject{Type: "string"}
+			if h.value != "" {
+				schema.Enum = []string{h.value}
+			}
+			op.Parameters = append(op.Parameters, ParameterObject{
+				Name:     h.name,
+				In:       "header",
+				Required: true,
+				Schema:   schema,
+			})
+		}
+	}
+}
+
+// hostServer returns the server entry for a host pattern. Host parameters
+// become server variables.
+func hostServer(m *matcher) ServerObject {
+	server := ServerObject{}
+	labels := make([]string, len(m.host))
+	for i, seg := range m.host {
+		labels[i] = seg.pattern()
+		params := []segment{seg}
+		if seg.pieces != nil {
+			params = seg.pieces
+		}
+		for _, p := range params {
+			if !p.isParam {
+				continue
+			}
+			if server.Variables == nil {
+				server.Variables = make(map[string]*ServerVariable)
+			}
+			v := &ServerVariable{Default: p.paramName}
+			if p.regex != nil {
+				v.Description = "Must match " + strings.TrimSuffix(strings.TrimPrefix(p.regex.String(), "^"), "$")
+			}
+			server.Variables[p.paramName] = v
 		}
 	}
-	finalParts := []string{}
-	for _, p := range parts {
-		if p != "" {
-			finalParts = append(finalParts, p)
+	server.URL = "https://" + strings.Join(labels, ".")
+	return server
+}
+
+// pathVariants returns the route followed by a copy of it for every path its
+// optional segments allow, since OpenAPI path parameters are always required.
+// "/posts/{page?}" yields "/posts/{page}" and "/posts".
+func pathVariants(rt *route) []*route {
+	variants := []*route{rt}
+	for i := len(rt.segments) - 1; i >= 0 && rt.segments[i].optional; i-- {
+		v := *rt
+		v.segments = rt.segments[:i]
+		variants = append(variants, &v)
+	}
+	return variants
+}
+
+// buildPathString constructs a URI path string from segments. Parameters are
+// wrapped in curly braces, e.g., "/users/{id}".
+func buildPathString(segments []segment) string {
+	parts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if p := seg.pattern(); p != "" {
+			parts = append(parts, p)
 		}
 	}
-	return strings.Join(finalParts, "/")
+	return "/" + strings.Join(parts, "/")
 }
 
 // buildOperation creates an OpenAPI OperationObject from a route and schema
 // generation context, adding parameters, requestBody, and responses.
-func buildOperation(route route, schemaCtx *schemaGenCtx) *Operation {
+func buildOperation(route *route, schemaCtx *schemaGenCtx) *Operation {
 	op := &Operation{
 		Responses:  make(map[string]*ResponseObject),
 		Parameters: []ParameterObject{},
@@ -247,11 +385,15 @@ func buildOperation(route route, schemaCtx *schemaGenCtx) *Operation {
 		op.OperationID = opts.OperationID
 		op.Deprecated = opts.Deprecated
 
-		if opts.RequestBody != nil {
+		if opts.RequestBody != nil && hasBodyFields(opts.RequestBody) {
+			mediaType := "application/json"
+			if hasFileFields(opts.RequestBody) {
+				mediaType = "multipart/form-data"
+			}
 			op.RequestBody = &RequestBodyObject{
 				Required: true,
 				Content: map[string]*MediaTypeObject{
-					"application/json": {
+					mediaType: {
 						Schema: generateSchema(opts.RequestBody, schemaCtx),
 					},
 				},
@@ -261,8 +403,12 @@ func buildOperation(route route, schemaCtx *schemaGenCtx) *Operation {
 		for statusCode, respOpt := range opts.Responses {
 			resp := &ResponseObject{Description: respOpt.Description}
 			if respOpt.Body != nil {
+				mediaType := "application/json"
+				if isProblemDetails(respOpt.Body) {
+					mediaType = ProblemContentType
+				}
 				resp.Content = map[string]*MediaTypeObject{
-					"application/json": {
+					mediaType: {
 						Schema: generateSchema(respOpt.Body, schemaCtx),
 					},
 				}
@@ -289,6 +435,10 @@ func buildOperation(route route, schemaCtx *schemaGenCtx) *Operation {
 			}
 			op.Parameters = append(op.Parameters, paramObj)
 		}
+
+		if opts.RequestBody != nil {
+			op.Parameters = appendTaggedParams(op.Parameters, opts.RequestBody, schemaCtx)
+		}
 	}
 
 	// Ensure path parameters are included
@@ -298,16 +448,19 @@ func buildOperation(route route, schemaCtx *schemaGenCtx) *Operation {
 	}
 
 	for _, seg := range route.segments {
-		if seg.isParam && !existingParams[seg.paramName] {
+		for _, paramName := range seg.names() {
+			if existingParams[paramName] {
+				continue
+			}
 			param := ParameterObject{
-				Name:     seg.paramName,
+				Name:     paramName,
 				In:       "path",
 				Required: true,
 				Schema:   &SchemaObject{Type: "string"},
 			}
 			if route.options != nil {
 				for _, pOpt := range route.options.Parameters {
-					if pOpt.Name == seg.paramName && pOpt.In == "path" {
+					if pOpt.Name == paramName && pOpt.In == "path" {
 						param.Description = pOpt.Description
 						param.Example = pOpt.Example
 						if pOpt.Schema != nil {
@@ -331,6 +484,70 @@ func buildOperation(route route, schemaCtx *schemaGenCtx) *Operation {
 	return op
 }
 
+// structType returns the struct type of v, dereferencing pointers. ok is false
+// if v is not a struct or pointer to struct.
+func structType(v any) (reflect.Type, bool) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t, t != nil && t.Kind() == reflect.Struct
+}
+
+// hasBodyFields reports whether the request type v has fields that are read
+// from the request body, as opposed to path, query, header or cookie fields.
+func hasBodyFields(v any) bool {
+	t, ok := structType(v)
+	if !ok {
+		return true
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if f.PkgPath != "" || f.Anonymous || f.Tag.Get("json") == "-" {
+			continue
+		}
+		if _, _, _, ok := paramTag(f); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// appendTaggedParams appends a ParameterObject for every field of the request
+// type v tagged with path, query, header or cookie, unless a parameter with the
+// same name and location is already present.
+func appendTaggedParams(params []ParameterObject, v any, ctx *schemaGenCtx) []ParameterObject {
+	t, ok := structType(v)
+	if !ok {
+		return params
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if f.PkgPath != "" {
+			continue
+		}
+		in, name, optional, ok := paramTag(f)
+		if !ok {
+			continue
+		}
+		if slices.ContainsFunc(params, func(p ParameterObject) bool {
+			return p.Name == name && p.In == in
+		}) {
+			continue
+		}
+		param := ParameterObject{
+			Name:        name,
+			In:          in,
+			Description: f.Tag.Get("description"),
+			Required:    in == "path" || !optional,
+			Schema:      generateSchema(reflect.Zero(f.Type).Interface(), ctx),
+		}
+		if example := f.Tag.Get("example"); example != "" {
+			param.Example = example
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
 // generateSchema inspects an example instance via reflection and adds or reuses
 // a SchemaObject in the context for components. Supports structs, arrays,
 // maps, and basic Go types.
@@ -350,6 +567,13 @@ func generateSchema(instance any, ctx *schemaGenCtx) *SchemaObject {
 		val = val.Elem()
 	}
 
+	if typ == reflect.TypeOf(ProblemDetails{}) {
+		return problemSchemaRef(ctx)
+	}
+	if typ == fileHeaderType.Elem() {
+		return &SchemaObject{Type: "string", Format: "binary"}
+	}
+
 	// Reuse existing named schema for struct types
 	if name, exists := ctx.generatedNames[typ]; exists && typ.Kind() == reflect.Struct {
 		return &SchemaObject{Ref: "#/components/schemas/" + name}
@@ -401,6 +625,10 @@ func generateSchema(instance any, ctx *schemaGenCtx) *SchemaObject {
 			if parts[0] == "-" {
 				continue
 			}
+			// path, query, header and cookie fields are documented as parameters
+			if _, _, _, ok := paramTag(field); ok {
+				continue
+			}
 
 			fieldName := field.Name
 			if parts[0] != "" {
@@ -497,9 +725,91 @@ func generateSchema(instance any, ctx *schemaGenCtx) *SchemaObject {
 	return schema
 }
 
+// isProblemDetails reports whether v is a ProblemDetails value or pointer.
+func isProblemDetails(v any) bool {
+	switch v.(type) {
+	case ProblemDetails, *ProblemDetails:
+		return true
+	}
+	return false
+}
+
+// problemSchemaRef registers the ProblemDetails component schema, if needed,
+// and returns a reference to it.
+func problemSchemaRef(ctx *schemaGenCtx) *SchemaObject {
+	ref := &SchemaObject{Ref: "#/components/schemas/ProblemDetails"}
+	if _, exists := ctx.componentsSchemas["ProblemDetails"]; exists {
+		return ref
+	}
+	ctx.generatedNames[reflect.TypeOf(ProblemDetails{})] = "ProblemDetails"
+	ctx.componentsSchemas["ProblemDetails"] = &SchemaObject{
+		Type:        "object",
+		Description: "An RFC 9457 problem details object.",
+		Properties: map[string]*SchemaObject{
+			"type": {
+				Type:        "string",
+				Format:      "uri-reference",
+				Description: "A URI reference that identifies the problem type.",
+				Example:     "about:blank",
+			},
+			"title": {
+				Type:        "string",
+				Description: "A short, human-readable summary of the problem type.",
+			},
+			"status": {
+				Type:        "integer",
+				Format:      "int32",
+				Description: "The HTTP status code.",
+			},
+			"detail": {
+				Type:        "string",
+				Description: "A human-readable explanation specific to this occurrence of the problem.",
+			},
+			"instance": {
+				Type:        "string",
+				Format:      "uri-reference",
+				Description: "A URI reference that identifies this occurrence of the problem.",
+			},
+		},
+		AdditionalProperties: &SchemaObject{},
+	}
+	return ref
+}
+
+// addProblemResponses documents a default problem details response on every
+// operation in spec that does not define a default response yet.
+func addProblemResponses(spec *OpenAPI, ctx *schemaGenCtx) {
+	for _, item := range spec.Paths {
+		for _, op := range []*Operation{item.Get, item.Post, item.Put, item.Delete, item.Patch} {
+			if op == nil {
+				continue
+			}
+			if _, exists := op.Responses["default"]; exists {
+				continue
+			}
+			op.Responses["default"] = &ResponseObject{
+				Description: "Error",
+				Content: map[string]*MediaTypeObject{
+					ProblemContentType: {Schema: problemSchemaRef(ctx)},
+				},
+			}
+		}
+	}
+}
+
 // GenerateOpenAPISpec constructs an OpenAPI 3.0 specification from the given
 // router and configuration, including paths, operations, and components.
+// Versions mounted with Router.Versions are all included; use
+// GenerateVersionedOpenAPISpecs for one specification per version.
 func GenerateOpenAPISpec(router *Router, config OpenAPIConfig) *OpenAPI {
+	return newOpenAPISpec(config, func(spec *OpenAPI, schemaCtx *schemaGenCtx) {
+		collectRoutes(router, spec, schemaCtx)
+	})
+}
+
+// newOpenAPISpec builds a specification from config, with the operations added
+// by collect.
+func newOpenAPISpec(config OpenAPIConfig, collect func(spec *OpenAPI, schemaCtx *schemaGenCtx)) *OpenAPI {
 	spec := &OpenAPI{
 		OpenAPI: "3.0.3",
 		Info: Info{
@@ -507,12 +817,17 @@ func GenerateOpenAPISpec(router *Router, config OpenAPIConfig) *OpenAPI {
 			Version:     config.Version,
 			Description: config.Description,
 		},
+		Servers:    config.Servers,
 		Paths:      make(map[string]*PathItem),
 		Components: &Components{Schemas: make(map[string]*SchemaObject)},
 	}
 
 	schemaCtx := newSchemaGenCtx()
-	collectRoutes(router, spec, schemaCtx, "")
+	collect(spec, schemaCtx)
+
+	if config.ProblemDetails {
+		addProblemResponses(spec, schemaCtx)
+	}
 
 	if len(schemaCtx.componentsSchemas) > 0 {
 		spec.Components.Schemas = schemaCtx.componentsSchemas
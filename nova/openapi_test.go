@@ -0,0 +1,123 @@
+package nova
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// TestOpenAPITaggedParams tests that path, query, header and cookie tags on the
+// request type are documented as parameters and left out of the request body.
+func TestOpenAPITaggedParams(t *testing.T) {
+	type listOrders struct {
+		ID     int    `path:"id"`
+		Page   int    `query:"page,omitempty" description:"Page number"`
+		Tenant string `header:"X-Tenant"`
+		Note   string `json:"note"`
+	}
+	type getOrder struct {
+		ID      int    `path:"id"`
+		Session string `cookie:"session,omitempty"`
+	}
+
+	r := NewRouter()
+	noop := func(rc *ResponseContext) error { return nil }
+	r.PutFunc("/orders/{id}", noop, &RouteOptions{
+		RequestBody: listOrders{},
+		Parameters:  []ParameterOption{{Name: "X-Tenant", In: "header", Description: "explicit"}},
+	})
+	r.GetFunc("/orders/{id}", noop, &RouteOptions{RequestBody: &getOrder{}})
+
+	spec := GenerateOpenAPISpec(r, OpenAPIConfig{Title: "t", Version: "1"})
+	item := spec.Paths["/orders/{id}"]
+
+	params := map[string]ParameterObject{}
+	for _, p := range item.Put.Parameters {
+		params[p.In+":"+p.Name] = p
+	}
+	if len(params) != 3 || len(item.Put.Parameters) != 3 {
+		t.Fatalf("PUT parameters = %+v; want id, page and X-Tenant once each", item.Put.Parameters)
+	}
+	if p := params["path:id"]; !p.Required || p.Schema.Type != "integer" {
+		t.Errorf("path id = %+v", p)
+	}
+	if p := params["query:page"]; p.Required || p.Description != "Page number" {
+		t.Errorf("query page = %+v", p)
+	}
+	if p := params["header:X-Tenant"]; p.Description != "explicit" {
+		t.Errorf("explicit ParameterOption was overridden: %+v", p)
+	}
+
+	body := spec.Components.Schemas["listOrders"]
+	if body == nil || len(body.Properties) != 1 || body.Properties["note"] == nil {
+		t.Errorf("request body schema = %+v; want only \"note\"", body)
+	}
+
+	if item.Get.RequestBody != nil {
+		t.Errorf("GET has a request body although all fields are parameters")
+	}
+	if len(item.Get.Parameters) != 2 {
+		t.Errorf("GET parameters = %+v; want id and session", item.Get.Parameters)
+	}
+}
+
+// TestOpenAPIPathParamKinds tests how catch-all, optional and embedded
+// parameters are documented.
+func TestOpenAPIPathParamKinds(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	r.Get("/files/{path...}", h)
+	r.Get("/posts/{page?:[0-9]+}", h, &RouteOptions{OperationID: "listPosts"})
+	r.Get("/download/{name}.{ext}", h)
+
+	spec := GenerateOpenAPISpec(r, OpenAPIConfig{Title: "t", Version: "1"})
+
+	for path, want := range map[string][]string{
+		"/files/{path}":          {"path"},
+		"/posts/{page}":          {"page"},
+		"/posts":                 nil,
+		"/download/{name}.{ext}": {"name", "ext"},
+	} {
+		item := spec.Paths[path]
+		if item == nil || item.Get == nil {
+			t.Errorf("missing GET %s in %v", path, slices.Collect(maps.Keys(spec.Paths)))
+			continue
+		}
+		var names []string
+		for _, p := range item.Get.Parameters {
+			if p.In == "path" && p.Required {
+				names = append(names, p.Name)
+			}
+		}
+		if !slices.Equal(names, want) {
+			t.Errorf("GET %s path parameters = %v; want %v", path, names, want)
+		}
+	}
+	if id := spec.Paths["/posts"].Get.OperationID; id != "" {
+		t.Errorf("GET /posts operationId = %q; want it only on the full path", id)
+	}
+	if id := spec.Paths["/posts/{page}"].Get.OperationID; id != "listPosts" {
+		t.Errorf("GET /posts/{page} operationId = %q; want listPosts", id)
+	}
+}
+
+// TestOpenAPISubrouterPaths tests that routes of nested subrouters and groups
+// are documented under their full path, with each prefix once.
+func TestOpenAPISubrouterPaths(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	api := r.Subrouter("/api")
+	api.Get("/status", h)
+	api.Subrouter("/v1").Group("/users").Get("/{id}", h)
+
+	spec := GenerateOpenAPISpec(r, OpenAPIConfig{Title: "t", Version: "1"})
+	for _, path := range []string{"/api/status", "/api/v1/users/{id}"} {
+		if item := spec.Paths[path]; item == nil || item.Get == nil {
+			t.Errorf("missing GET %s in %v", path, slices.Collect(maps.Keys(spec.Paths)))
+		}
+	}
+	if len(spec.Paths) != 2 {
+		t.Errorf("paths = %v; want 2", slices.Collect(maps.Keys(spec.Paths)))
+	}
+}
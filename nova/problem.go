@@ -0,0 +1,141 @@
+package nova
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemContentType is the media type of RFC 9457 problem details responses.
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails is an RFC 9457 problem details object. It describes an error
+// in a machine-readable way. Extension members are marshalled next to the
+// standard members.
+type ProblemDetails struct {
+	// Type is a URI reference that identifies the problem type.
+	// Defaults to "about:blank" when written.
+	Type string `json:"type,omitempty"`
+	// Title is a short, human-readable summary of the problem type.
+	// Defaults to the status text of Status when Type is "about:blank".
+	Title string `json:"title,omitempty"`
+	// Status is the HTTP status code. Defaults to 500 when written.
+	Status int `json:"status,omitempty"`
+	// Detail is a human-readable explanation specific to this occurrence.
+	Detail string `json:"detail,omitempty"`
+	// Instance is a URI reference that identifies this occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+	// Extensions contains additional members. Keys that collide with the
+	// standard members are ignored.
+	Extensions map[string]any `json:"-"`
+}
+
+// NewProblemDetails creates a ProblemDetails for the given status and detail.
+func NewProblemDetails(status int, detail string) ProblemDetails {
+	return ProblemDetails{Status: status, Detail: detail}
+}
+
+// MarshalJSON encodes the standard members together with the extension members
+// in a single JSON object.
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(m, k)
+	}
+	if p.Type != "" {
+		m["type"] = p.Type
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON decodes a problem details object, collecting unknown members
+// into Extensions.
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	type standard ProblemDetails
+	var s standard
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(raw, k)
+	}
+	*p = ProblemDetails(s)
+	if len(raw) > 0 {
+		p.Extensions = raw
+	}
+	return nil
+}
+
+// withDefaults returns a copy of p with the status, type and title filled in.
+func (p ProblemDetails) withDefaults() ProblemDetails {
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" && p.Type == "about:blank" {
+		p.Title = http.StatusText(p.Status)
+	}
+	return p
+}
+
+// writeProblem writes p as an application/problem+json response. The instance
+// defaults to the request path.
+func writeProblem(w http.ResponseWriter, r *http.Request, p ProblemDetails) error {
+	p = p.withDefaults()
+	if p.Instance == "" && r != nil {
+		p.Instance = r.URL.Path
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
+
+// Problem sends an RFC 9457 problem details response with the Content-Type
+// "application/problem+json". The status defaults to 500, the type to
+// "about:blank", the title to the status text and the instance to the request path.
+func (rc *ResponseContext) Problem(p ProblemDetails) error {
+	return writeProblem(rc.w, rc.r, p)
+}
+
+// ProblemErrorHandler is an ErrorHandlerFunc that maps errors like
+// DefaultErrorHandler but always responds with problem details. The public
+// message becomes the detail and the error details the "details" member;
+// validation errors also get their violations keyed by field path in the
+// "fields" member.
+//
+//	router.SetErrorHandler(nova.ProblemErrorHandler)
+func ProblemErrorHandler(rc *ResponseContext, err error) {
+	he := resolveError(rc, err)
+	p := ProblemDetails{Status: he.Status, Detail: he.Message}
+	if he.Details != nil || he.fields != nil {
+		p.Extensions = map[string]any{}
+	}
+	if he.Details != nil {
+		p.Extensions["details"] = he.Details
+	}
+	if he.fields != nil {
+		p.Extensions["fields"] = he.fields
+	}
+	_ = rc.Problem(p)
+}
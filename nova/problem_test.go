@@ -0,0 +1,245 @@
+package nova
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decodeProblem checks the problem details content type of rr and decodes its body.
+func decodeProblem(t *testing.T, rr *httptest.ResponseRecorder) ProblemDetails {
+	t.Helper()
+	if ct := rr.Header().Get("Content-Type"); ct != ProblemContentType {
+		t.Fatalf("Content-Type = %q; want %q", ct, ProblemContentType)
+	}
+	var p ProblemDetails
+	if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decoding problem %q: %v", rr.Body.String(), err)
+	}
+	return p
+}
+
+// TestProblemDetailsJSON tests that extension members are marshalled next to
+// the standard members and collected again when unmarshalling.
+func TestProblemDetailsJSON(t *testing.T) {
+	p := ProblemDetails{
+		Type:       "https://example.com/probs/out-of-credit",
+		Title:      "You do not have enough credit.",
+		Status:     http.StatusForbidden,
+		Extensions: map[string]any{"balance": 30.0, "status": "ignored"},
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"balance":30,"status":403,"title":"You do not have enough credit.","type":"https://example.com/probs/out-of-credit"}`
+	if string(data) != want {
+		t.Errorf("Marshal = %s; want %s", data, want)
+	}
+
+	var got ProblemDetails
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != http.StatusForbidden || got.Type != p.Type || got.Extensions["balance"] != 30.0 {
+		t.Errorf("Unmarshal = %+v", got)
+	}
+	if _, ok := got.Extensions["status"]; ok {
+		t.Error("standard member \"status\" ended up in Extensions")
+	}
+}
+
+// TestResponseContextProblem tests the defaults filled in by ResponseContext.Problem.
+func TestResponseContextProblem(t *testing.T) {
+	r := NewRouter()
+	r.GetFunc("/orders/{id}", func(rc *ResponseContext) error {
+		return rc.Problem(NewProblemDetails(http.StatusConflict, "order already shipped"))
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/orders/7", nil))
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("status = %d; want %d", rr.Code, http.StatusConflict)
+	}
+	p := decodeProblem(t, rr)
+	want := ProblemDetails{
+		Type:     "about:blank",
+		Title:    "Conflict",
+		Status:   http.StatusConflict,
+		Detail:   "order already shipped",
+		Instance: "/orders/7",
+	}
+	if p.Type != want.Type || p.Title != want.Title || p.Status != want.Status ||
+		p.Detail != want.Detail || p.Instance != want.Instance {
+		t.Errorf("problem = %+v; want %+v", p, want)
+	}
+}
+
+// TestRouterProblemDetails tests that the default 404 and 405 responses use
+// problem details once enabled, including in subrouters created afterwards.
+func TestRouterProblemDetails(t *testing.T) {
+	r := NewRouter()
+	r.SetProblemDetails(true)
+	r.Subrouter("/api").GetFunc("/users", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "users")
+	})
+
+	cases := []struct {
+		method, path string
+		wantStatus   int
+	}{
+		{"GET", "/missing", http.StatusNotFound},
+		{"GET", "/api/missing", http.StatusNotFound},
+		{"DELETE", "/api/users", http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(c.method, c.path, nil))
+		if rr.Code != c.wantStatus {
+			t.Errorf("%s %s status = %d; want %d", c.method, c.path, rr.Code, c.wantStatus)
+			continue
+		}
+		if p := decodeProblem(t, rr); p.Status != c.wantStatus || p.Instance != c.path {
+			t.Errorf("%s %s problem = %+v", c.method, c.path, p)
+		}
+	}
+}
+
+// TestProblemErrorHandler tests that handler errors are rendered as problem
+// details with the public message as detail.
+func TestProblemErrorHandler(t *testing.T) {
+	r := NewRouter()
+	r.SetErrorHandler(ProblemErrorHandler)
+	r.GetFunc("/validate", func(rc *ResponseContext) error {
+		return ValidationErrors{&FieldError{Field: "/name", Code: "required", Message: "Field 'name' is required"}}
+	})
+	r.GetFunc("/internal", func(rc *ResponseContext) error {
+		return errors.New("secret internals")
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/validate", nil))
+	p := decodeProblem(t, rr)
+	if p.Status != http.StatusUnprocessableEntity || p.Detail != "Validation failed" {
+		t.Errorf("problem = %+v", p)
+	}
+	if details, _ := p.Extensions["details"].([]any); len(details) != 1 {
+		t.Errorf("details = %v; want one entry", p.Extensions["details"])
+	}
+	fields, _ := p.Extensions["fields"].(map[string]any)
+	if name, _ := fields["/name"].([]any); len(name) != 1 {
+		t.Errorf("fields = %v; want one violation of /name", p.Extensions["fields"])
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/internal", nil))
+	if strings.Contains(rr.Body.String(), "secret") {
+		t.Errorf("body leaks internal error: %s", rr.Body.String())
+	}
+	if p := decodeProblem(t, rr); p.Status != http.StatusInternalServerError {
+		t.Errorf("problem = %+v", p)
+	}
+}
+
+// TestMiddlewareProblemDetails tests the problem details option of the
+// built-in middleware that reject requests.
+func TestMiddlewareProblemDetails(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	cases := []struct {
+		name       string
+		handler    http.Handler
+		req        func() *http.Request
+		wantStatus int
+	}{
+		{
+			name:    "rate limit",
+			handler: RateLimitMiddleware(RateLimiterConfig{Requests: 1, Duration: time.Minute, Burst: 1, ProblemDetails: true})(ok),
+			req: func() *http.Request {
+				return httptest.NewRequest("GET", "/", nil)
+			},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:    "csrf",
+			handler: CSRFMiddleware(&CSRFConfig{ProblemDetails: true})(ok),
+			req: func() *http.Request {
+				return httptest.NewRequest("POST", "/", nil)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:    "max body size",
+			handler: MaxRequestBodySizeMiddleware(MaxRequestBodySizeConfig{LimitBytes: 4, ProblemDetails: true})(ok),
+			req: func() *http.Request {
+				return httptest.NewRequest("POST", "/", strings.NewReader("too large"))
+			},
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:    "timeout",
+			handler: TimeoutMiddleware(TimeoutConfig{Duration: 10 * time.Millisecond, ProblemDetails: true})(slow),
+			req: func() *http.Request {
+				return httptest.NewRequest("GET", "/", nil)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			// The rate limiter lets the first request through.
+			c.handler.ServeHTTP(rr, c.req())
+			if c.wantStatus == http.StatusTooManyRequests {
+				rr = httptest.NewRecorder()
+				c.handler.ServeHTTP(rr, c.req())
+			}
+			if rr.Code != c.wantStatus {
+				t.Fatalf("status = %d; want %d", rr.Code, c.wantStatus)
+			}
+			if p := decodeProblem(t, rr); p.Status != c.wantStatus || p.Detail == "" {
+				t.Errorf("problem = %+v", p)
+			}
+		})
+	}
+}
+
+// TestOpenAPIProblemDetails tests that problem details are documented as a
+// reusable component schema.
+func TestOpenAPIProblemDetails(t *testing.T) {
+	r := NewRouter()
+	r.GetFunc("/items/{id}", func(rc *ResponseContext) error { return nil }, &RouteOptions{
+		Responses: map[int]ResponseOption{
+			http.StatusNotFound: {Description: "Not found", Body: &ProblemDetails{}},
+		},
+	})
+	r.PostFunc("/items", func(rc *ResponseContext) error { return nil })
+
+	spec := GenerateOpenAPISpec(r, OpenAPIConfig{Title: "t", Version: "1", ProblemDetails: true})
+
+	if spec.Components == nil || spec.Components.Schemas["ProblemDetails"] == nil {
+		t.Fatal("ProblemDetails component schema missing")
+	}
+	notFound := spec.Paths["/items/{id}"].Get.Responses["404"]
+	if mt := notFound.Content[ProblemContentType]; mt == nil || mt.Schema.Ref != "#/components/schemas/ProblemDetails" {
+		t.Errorf("404 response content = %+v", notFound.Content)
+	}
+	def := spec.Paths["/items"].Post.Responses["default"]
+	if def == nil || def.Content[ProblemContentType].Schema.Ref != "#/components/schemas/ProblemDetails" {
+		t.Errorf("default response = %+v", def)
+	}
+	if _, ok := spec.Paths["/items"].Post.Responses["200"]; !ok {
+		t.Error("default problem response replaced the 200 response")
+	}
+}
@@ -2,9 +2,12 @@ package nova
 
 import (
 	"context"
+	"encoding"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"net/http"
 	"net/mail"
 	"net/url"
@@ -14,7 +17,9 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 // ResponseContext provides helper methods for sending HTTP responses with reduced boilerplate.
@@ -27,6 +32,10 @@ type ResponseContext struct {
 	r *http.Request
 	// router is the parent router instance used for URL parameter extraction.
 	router *Router
+	// sse is the Server-Sent Events stream started by the handler, if any.
+	sse *SSEStream
+	// ws is the WebSocket connection upgraded by the handler, if any.
+	ws *WebSocketConn
 }
 
 // HandlerFunc is an enhanced handler function that receives a ResponseContext and returns an error.
@@ -43,21 +52,54 @@ type Middleware func(http.Handler) http.Handler
 // custom error handlers, and both traditional and enhanced handler functions.
 type Router struct {
 	// routes contains all registered routes with their patterns and handlers.
-	routes []route
+	routes []*route
+	// tree indexes the registered routes by path segment for fast matching.
+	tree *node
+	// names maps route names from RouteOptions.Name to their routes.
+	names map[string]*route
 	// subrouters contains mounted child routers with their own base paths.
 	subrouters []*Router
+	// mounts contains arbitrary handlers attached at a path prefix.
+	mounts []mount
 	// paramsKey is the context key used to store URL parameters in request context.
 	paramsKey any
 	// middlewares contains all registered middleware functions applied to routes.
 	middlewares []Middleware
 	// chain is the composed middleware chain function applied to all routes.
 	chain func(http.Handler) http.Handler
+	// pre contains the pre-routing middleware registered with Pre.
+	pre []Middleware
+	// preHandler is the pre-routing middleware wrapped around route matching, or nil.
+	preHandler http.Handler
+	// isSubrouter reports whether the router was created by Subrouter.
+	isSubrouter bool
 	// basePath is the prefix path for this router, used in subrouters and groups.
 	basePath string
 	// notFoundHandler is the custom handler for 404 Not Found responses.
 	notFoundHandler http.Handler
 	// methodNotAllowedHandler is the custom handler for 405 Method Not Allowed responses.
 	methodNotAllowedHandler http.Handler
+	// errorHandler handles errors returned by enhanced handlers. Nil means DefaultErrorHandler.
+	errorHandler ErrorHandlerFunc
+	// autoHead enables serving HEAD requests with the GET route of the same pattern.
+	autoHead bool
+	// autoOptions enables answering OPTIONS requests with the allowed methods.
+	autoOptions bool
+	// problemDetails makes the default 404 and 405 responses use problem details.
+	problemDetails bool
+	// multipartMemory is the memory threshold for parsing multipart forms. Zero means 32 MB.
+	multipartMemory int64
+	// codecs holds the response encoders and request decoders, shared with subrouters.
+	codecs *codecRegistry
+	// webSocket is the configuration used when upgrading WebSocket connections.
+	webSocket WebSocketConfig
+	// match holds the host and header conditions set with Host and Headers, or nil.
+	match *matcher
+	// versions is set on the subrouter created by Versions, which dispatches
+	// to the version routers.
+	versions *Versions
+	// version is the API version the router serves, inherited by its subrouters, or nil.
+	version *apiVersion
 }
 
 // Group is a lightweight helper that allows users to register a set of routes
@@ -79,14 +121,32 @@ type route struct {
 	method string
 	// handler is the HTTP handler function that processes requests to this route.
 	handler http.HandlerFunc
+	// pattern is the full URL pattern as registered, including the base path.
+	pattern string
 	// segments contains the compiled URL pattern segments for path matching.
 	segments []segment
 	// options contains optional metadata for OpenAPI documentation and validation.
 	options *RouteOptions
+	// match holds the host and header conditions from the route options, or nil.
+	match *matcher
+	// middlewares is the number of group and route middleware wrapped around handler.
+	middlewares int
+	// matched describes the route to handlers and middleware through the request context.
+	matched *MatchedRoute
+}
+
+// mount represents an http.Handler attached at a path prefix with Router.Mount.
+// It receives every request whose path is the prefix or lies below it.
+type mount struct {
+	// prefix is the full path prefix, including the router's basePath.
+	prefix string
+	// handler is the handler that serves requests below prefix.
+	handler http.Handler
 }
 
-// segment represents a part of the URL path. It may be a literal string or a dynamic
-// parameter with an optional regex pattern for validation.
+// segment represents a part of the URL path. It may be a literal string, a dynamic
+// parameter with an optional regex pattern for validation, or literal text with
+// embedded parameters such as "{name}.{ext}".
 type segment struct {
 	// isParam indicates whether this segment is a dynamic parameter ({name}) or literal text.
 	isParam bool
@@ -95,182 +155,290 @@ type segment struct {
 	// paramName is the parameter name when isParam is true (e.g., "id" from "{id}").
 	paramName string
 	// regex is the compiled regular expression for parameter validation, if specified.
+	// For embedded parameters it matches the whole segment and captures each parameter.
 	regex *regexp.Regexp
+	// catchAll marks a trailing {name...} parameter that matches the rest of the
+	// path, which may be empty.
+	catchAll bool
+	// optional marks a trailing {name?} parameter that may be absent.
+	optional bool
+	// pieces contains the literal and parameter parts of a segment with embedded
+	// parameters, e.g. "{name}", "." and "{ext}" for "{name}.{ext}". It is nil
+	// for plain literals and parameters.
+	pieces []segment
+}
+
+// isLiteral reports whether the segment is plain literal text.
+func (s segment) isLiteral() bool {
+	return !s.isParam && s.pieces == nil
+}
+
+// names returns the names of the parameters in the segment.
+func (s segment) names() []string {
+	if s.isParam {
+		return []string{s.paramName}
+	}
+	var names []string
+	for _, p := range s.pieces {
+		if p.isParam {
+			names = append(names, p.paramName)
+		}
+	}
+	return names
+}
+
+// matches reports whether a single path segment satisfies s.
+func (s segment) matches(part string) bool {
+	switch {
+	case s.isLiteral():
+		return s.literal == part
+	case s.regex != nil:
+		return s.regex.MatchString(part)
+	default:
+		return true
+	}
+}
+
+// capture stores the parameter values of a matching path segment in params.
+func (s segment) capture(part string, params map[string]string) {
+	if s.isParam {
+		params[s.paramName] = part
+		return
+	}
+	if s.pieces == nil {
+		return
+	}
+	m := s.regex.FindStringSubmatch(part)
+	for i, p := range s.pieces {
+		if p.isParam && m != nil {
+			params[p.paramName] = m[s.regex.SubexpIndex(pieceGroup(i))]
+		}
+	}
+}
+
+// pattern returns the segment as written in an OpenAPI path, with every
+// parameter reduced to {name}.
+func (s segment) pattern() string {
+	switch {
+	case s.isParam:
+		return "{" + s.paramName + "}"
+	case s.pieces != nil:
+		var b strings.Builder
+		for _, p := range s.pieces {
+			b.WriteString(p.pattern())
+		}
+		return b.String()
+	default:
+		return s.literal
+	}
 }
 
 // Translation messages for validation errors.
 var validationMessages = map[string]map[string]string{
 	"en": {
-		"required":     "Field '%s' is required",
-		"minlength":    "Field '%s' must be at least %d characters long",
-		"maxlength":    "Field '%s' must be at most %d characters long",
-		"min":          "Field '%s' must be at least %v",
-		"max":          "Field '%s' must be at most %v",
-		"pattern":      "Field '%s' does not match required pattern",
-		"enum":         "Field '%s' must be one of: %s",
-		"email":        "Field '%s' must be a valid email address",
-		"url":          "Field '%s' must be a valid URL",
-		"uuid":         "Field '%s' must be a valid UUID",
-		"date-time":    "Field '%s' must be a valid RFC3339 date-time",
-		"date":         "Field '%s' must be a valid date (YYYY-MM-DD)",
-		"time":         "Field '%s' must be a valid time (HH:MM:SS)",
-		"phone":        "Field '%s' must be a valid phone number",
-		"password":     "Field '%s' password must be at least 8 characters long",
-		"alphanumeric": "Field '%s' must contain only alphanumeric characters",
-		"alpha":        "Field '%s' must contain only alphabetic characters",
-		"numeric":      "Field '%s' must contain only numeric characters",
-		"minItems":     "Field '%s' must have at least %d items",
-		"maxItems":     "Field '%s' must have at most %d items",
-		"uniqueItems":  "Field '%s' must have unique items",
-		"multipleOf":   "Field '%s' must be a multiple of %v",
+		"required":             "Field '%s' is required",
+		"minlength":            "Field '%s' must be at least %d characters long",
+		"maxlength":            "Field '%s' must be at most %d characters long",
+		"min":                  "Field '%s' must be at least %v",
+		"max":                  "Field '%s' must be at most %v",
+		"pattern":              "Field '%s' does not match required pattern",
+		"enum":                 "Field '%s' must be one of: %s",
+		"email":                "Field '%s' must be a valid email address",
+		"url":                  "Field '%s' must be a valid URL",
+		"uuid":                 "Field '%s' must be a valid UUID",
+		"date-time":            "Field '%s' must be a valid RFC3339 date-time",
+		"date":                 "Field '%s' must be a valid date (YYYY-MM-DD)",
+		"time":                 "Field '%s' must be a valid time (HH:MM:SS)",
+		"phone":                "Field '%s' must be a valid phone number",
+		"password":             "Field '%s' password must be at least 8 characters long",
+		"alphanumeric":         "Field '%s' must contain only alphanumeric characters",
+		"alpha":                "Field '%s' must contain only alphabetic characters",
+		"numeric":              "Field '%s' must contain only numeric characters",
+		"unicode-alpha":        "Field '%s' must contain only letters",
+		"unicode-alphanumeric": "Field '%s' must contain only letters and digits",
+		"unicode-numeric":      "Field '%s' must contain only digits",
+		"minItems":             "Field '%s' must have at least %d items",
+		"maxItems":             "Field '%s' must have at most %d items",
+		"uniqueItems":          "Field '%s' must have unique items",
+		"multipleOf":           "Field '%s' must be a multiple of %v",
+		"maxFileSize":          "Field '%s' file '%s' must be at most %s",
+		"accept":               "Field '%s' file '%s' must be of type: %s",
+		"eqfield":              "Field '%s' must be equal to '%s'",
+		"nefield":              "Field '%s' must differ from '%s'",
+		"gtfield":              "Field '%s' must be greater than '%s'",
+		"gtefield":             "Field '%s' must be greater than or equal to '%s'",
+		"ltfield":              "Field '%s' must be less than '%s'",
+		"ltefield":             "Field '%s' must be less than or equal to '%s'",
+		"required_if":          "Field '%s' is required when '%s' is %s",
+		"required_with":        "Field '%s' is required when '%s' is present",
+		"excluded_unless":      "Field '%s' is only allowed when '%s' is %s",
 	},
 	"es": {
-		"required":     "El campo '%s' es obligatorio",
-		"minlength":    "El campo '%s' debe tener al menos %d caracteres",
-		"maxlength":    "El campo '%s' debe tener como máximo %d caracteres",
-		"min":          "El campo '%s' debe ser al menos %v",
-		"max":          "El campo '%s' debe ser como máximo %v",
-		"pattern":      "El campo '%s' no coincide con el patrón requerido",
-		"enum":         "El campo '%s' debe ser uno de: %s",
-		"email":        "El campo '%s' debe ser una dirección de correo válida",
-		"url":          "El campo '%s' debe ser una URL válida",
-		"uuid":         "El campo '%s' debe ser un UUID válido",
-		"date-time":    "El campo '%s' debe ser una fecha-hora RFC3339 válida",
-		"date":         "El campo '%s' debe ser una fecha válida (AAAA-MM-DD)",
-		"time":         "El campo '%s' debe ser una hora válida (HH:MM:SS)",
-		"phone":        "El campo '%s' debe ser un número de teléfono válido",
-		"password":     "El campo '%s' debe tener al menos 8 caracteres",
-		"alphanumeric": "El campo '%s' debe contener solo caracteres alfanuméricos",
-		"alpha":        "El campo '%s' debe contener solo caracteres alfabéticos",
-		"numeric":      "El campo '%s' debe contener solo caracteres numéricos",
-		"minItems":     "El campo '%s' debe tener al menos %d elementos",
-		"maxItems":     "El campo '%s' debe tener como máximo %d elementos",
-		"uniqueItems":  "El campo '%s' debe tener elementos únicos",
-		"multipleOf":   "El campo '%s' debe ser múltiplo de %v",
+		"required":             "El campo '%s' es obligatorio",
+		"minlength":            "El campo '%s' debe tener al menos %d caracteres",
+		"maxlength":            "El campo '%s' debe tener como máximo %d caracteres",
+		"min":                  "El campo '%s' debe ser al menos %v",
+		"max":                  "El campo '%s' debe ser como máximo %v",
+		"pattern":              "El campo '%s' no coincide con el patrón requerido",
+		"enum":                 "El campo '%s' debe ser uno de: %s",
+		"email":                "El campo '%s' debe ser una dirección de correo válida",
+		"url":                  "El campo '%s' debe ser una URL válida",
+		"uuid":                 "El campo '%s' debe ser un UUID válido",
+		"date-time":            "El campo '%s' debe ser una fecha-hora RFC3339 válida",
+		"date":                 "El campo '%s' debe ser una fecha válida (AAAA-MM-DD)",
+		"time":                 "El campo '%s' debe ser una hora válida (HH:MM:SS)",
+		"phone":                "El campo '%s' debe ser un número de teléfono válido",
+		"password":             "El campo '%s' debe tener al menos 8 caracteres",
+		"alphanumeric":         "El campo '%s' debe contener solo caracteres alfanuméricos",
+		"alpha":                "El campo '%s' debe contener solo caracteres alfabéticos",
+		"numeric":              "El campo '%s' debe contener solo caracteres numéricos",
+		"unicode-alpha":        "El campo '%s' debe contener solo letras",
+		"unicode-alphanumeric": "El campo '%s' debe contener solo letras y dígitos",
+		"unicode-numeric":      "El campo '%s' debe contener solo dígitos",
+		"minItems":             "El campo '%s' debe tener al menos %d elementos",
+		"maxItems":             "El campo '%s' debe tener como máximo %d elementos",
+		"uniqueItems":          "El campo '%s' debe tener elementos únicos",
+		"multipleOf":           "El campo '%s' debe ser múltiplo de %v",
+		"maxFileSize":          "El archivo '%[2]s' del campo '%[1]s' debe ocupar como máximo %[3]s",
+		"accept":               "El archivo '%[2]s' del campo '%[1]s' debe ser de tipo: %[3]s",
+		"eqfield":              "El campo '%s' debe ser igual a '%s'",
+		"nefield":              "El campo '%s' debe ser distinto de '%s'",
+		"gtfield":              "El campo '%s' debe ser mayor que '%s'",
+		"gtefield":             "El campo '%s' debe ser mayor o igual que '%s'",
+		"ltfield":              "El campo '%s' debe ser menor que '%s'",
+		"ltefield":             "El campo '%s' debe ser menor o igual que '%s'",
+		"required_if":          "El campo '%s' es obligatorio cuando '%s' es %s",
+		"required_with":        "El campo '%s' es obligatorio cuando '%s' está presente",
+		"excluded_unless":      "El campo '%s' solo se permite cuando '%s' es %s",
 	},
 	"fr": {
-		"required":     "Le champ '%s' est obligatoire",
-		"minlength":    "Le champ '%s' doit contenir au moins %d caractères",
-		"maxlength":    "Le champ '%s' doit contenir au plus %d caractères",
-		"min":          "Le champ '%s' doit être d'au moins %v",
-		"max":          "Le champ '%s' doit être d'au plus %v",
-		"pattern":      "Le champ '%s' ne correspond pas au motif requis",
-		"enum":         "Le champ '%s' doit être l'un de: %s",
-		"email":        "Le champ '%s' doit être une adresse email valide",
-		"url":          "Le champ '%s' doit être une URL valide",
-		"uuid":         "Le champ '%s' doit être un UUID valide",
-		"date-time":    "Le champ '%s' doit être une date-heure RFC3339 valide",
-		"date":         "Le champ '%s' doit être une date valide (AAAA-MM-JJ)",
-		"time":         "Le champ '%s' doit être une heure valide (HH:MM:SS)",
-		"phone":        "Le champ '%s' doit être un numéro de téléphone valide",
-		"password":     "Le champ '%s' doit contenir au moins 8 caractères",
-		"alphanumeric": "Le champ '%s' ne doit contenir que des caractères alphanumériques",
-		"alpha":        "Le champ '%s' ne doit contenir que des caractères alphabétiques",
-		"numeric":      "Le champ '%s' ne doit contenir que des caractères numériques",
-		"minItems":     "Le champ '%s' doit avoir au moins %d éléments",
-		"maxItems":     "Le champ '%s' doit avoir au plus %d éléments",
-		"uniqueItems":  "Le champ '%s' doit avoir des éléments uniques",
-		"multipleOf":   "Le champ '%s' doit être un multiple de %v",
+		"required":             "Le champ '%s' est obligatoire",
+		"minlength":            "Le champ '%s' doit contenir au moins %d caractères",
+		"maxlength":            "Le champ '%s' doit contenir au plus %d caractères",
+		"min":                  "Le champ '%s' doit être d'au moins %v",
+		"max":                  "Le champ '%s' doit être d'au plus %v",
+		"pattern":              "Le champ '%s' ne correspond pas au motif requis",
+		"enum":                 "Le champ '%s' doit être l'un de: %s",
+		"email":                "Le champ '%s' doit être une adresse email valide",
+		"url":                  "Le champ '%s' doit être une URL valide",
+		"uuid":                 "Le champ '%s' doit être un UUID valide",
+		"date-time":            "Le champ '%s' doit être une date-heure RFC3339 valide",
+		"date":                 "Le champ '%s' doit être une date valide (AAAA-MM-JJ)",
+		"time":                 "Le champ '%s' doit être une heure valide (HH:MM:SS)",
+		"phone":                "Le champ '%s' doit être un numéro de téléphone valide",
+		"password":             "Le champ '%s' doit contenir au moins 8 caractères",
+		"alphanumeric":         "Le champ '%s' ne doit contenir que des caractères alphanumériques",
+		"alpha":                "Le champ '%s' ne doit contenir que des caractères alphabétiques",
+		"numeric":              "Le champ '%s' ne doit contenir que des caractères numériques",
+		"unicode-alpha":        "Le champ '%s' ne doit contenir que des lettres",
+		"unicode-alphanumeric": "Le champ '%s' ne doit contenir que des lettres et des chiffres",
+		"unicode-numeric":      "Le champ '%s' ne doit contenir que des chiffres",
+		"minItems":             "Le champ '%s' doit avoir au moins %d éléments",
+		"maxItems":             "Le champ '%s' doit avoir au plus %d éléments",
+		"uniqueItems":          "Le champ '%s' doit avoir des éléments uniques",
+		"multipleOf":           "Le champ '%s' doit être un multiple de %v",
+		"maxFileSize":          "Le fichier '%[2]s' du champ '%[1]s' doit faire au plus %[3]s",
+		"accept":               "Le fichier '%[2]s' du champ '%[1]s' doit être de type: %[3]s",
+		"eqfield":              "Le champ '%s' doit être égal à '%s'",
+		"nefield":              "Le champ '%s' doit être différent de '%s'",
+		"gtfield":              "Le champ '%s' doit être supérieur à '%s'",
+		"gtefield":             "Le champ '%s' doit être supérieur ou égal à '%s'",
+		"ltfield":              "Le champ '%s' doit être inférieur à '%s'",
+		"ltefield":             "Le champ '%s' doit être inférieur ou égal à '%s'",
+		"required_if":          "Le champ '%s' est obligatoire lorsque '%s' vaut %s",
+		"required_with":        "Le champ '%s' est obligatoire lorsque '%s' est présent",
+		"excluded_unless":      "Le champ '%s' n'est autorisé que lorsque '%s' vaut %s",
 	},
 	"de": {
-		"required":     "Das Feld '%s' ist erforderlich",
-		"minlength":    "Das Feld '%s' muss mindestens %d Zeichen lang sein",
-		"maxlength":    "Das Feld '%s' darf höchstens %d Zeichen lang sein",
-		"min":          "Das Feld '%s' muss mindestens %v sein",
-		"max":          "Das Feld '%s' darf höchstens %v sein",
-		"pattern":      "Das Feld '%s' entspricht nicht dem erforderlichen Muster",
-		"enum":         "Das Feld '%s' muss eines von: %s sein",
-		"email":        "Das Feld '%s' muss eine gültige E-Mail-Adresse sein",
-		"url":          "Das Feld '%s' muss eine gültige URL sein",
-		"uuid":         "Das Feld '%s' muss eine gültige UUID sein",
-		"date-time":    "Das Feld '%s' muss eine gültige RFC3339 Datum-Zeit sein",
-		"date":         "Das Feld '%s' muss ein gültiges Datum sein (JJJJ-MM-TT)",
-		"time":         "Das Feld '%s' muss eine gültige Zeit sein (HH:MM:SS)",
-		"phone":        "Das Feld '%s' muss eine gültige Telefonnummer sein",
-		"password":     "Das Feld '%s' muss mindestens 8 Zeichen lang sein",
-		"alphanumeric": "Das Feld '%s' darf nur alphanumerische Zeichen enthalten",
-		"alpha":        "Das Feld '%s' darf nur alphabetische Zeichen enthalten",
-		"numeric":      "Das Feld '%s' darf nur numerische Zeichen enthalten",
-		"minItems":     "Das Feld '%s' muss mindestens %d Elemente haben",
-		"maxItems":     "Das Feld '%s' darf höchstens %d Elemente haben",
-		"uniqueItems":  "Das Feld '%s' muss eindeutige Elemente haben",
-		"multipleOf":   "Das Feld '%s' muss ein Vielfaches von %v sein",
+		"required":             "Das Feld '%s' ist erforderlich",
+		"minlength":            "Das Feld '%s' muss mindestens %d Zeichen lang sein",
+		"maxlength":            "Das Feld '%s' darf höchstens %d Zeichen lang sein",
+		"min":                  "Das Feld '%s' muss mindestens %v sein",
+		"max":                  "Das Feld '%s' darf höchstens %v sein",
+		"pattern":              "Das Feld '%s' entspricht nicht dem erforderlichen Muster",
+		"enum":                 "Das Feld '%s' muss eines von: %s sein",
+		"email":                "Das Feld '%s' muss eine gültige E-Mail-Adresse sein",
+		"url":                  "Das Feld '%s' muss eine gültige URL sein",
+		"uuid":                 "Das Feld '%s' muss eine gültige UUID sein",
+		"date-time":            "Das Feld '%s' muss eine gültige RFC3339 Datum-Zeit sein",
+		"date":                 "Das Feld '%s' muss ein gültiges Datum sein (JJJJ-MM-TT)",
+		"time":                 "Das Feld '%s' muss eine gültige Zeit sein (HH:MM:SS)",
+		"phone":                "Das Feld '%s' muss eine gültige Telefonnummer sein",
+		"password":             "Das Feld '%s' muss mindestens 8 Zeichen lang sein",
+		"alphanumeric":         "Das Feld '%s' darf nur alphanumerische Zeichen enthalten",
+		"alpha":                "Das Feld '%s' darf nur alphabetische Zeichen enthalten",
+		"numeric":              "Das Feld '%s' darf nur numerische Zeichen enthalten",
+		"unicode-alpha":        "Das Feld '%s' darf nur Buchstaben enthalten",
+		"unicode-alphanumeric": "Das Feld '%s' darf nur Buchstaben und Ziffern enthalten",
+		"unicode-numeric":      "Das Feld '%s' darf nur Ziffern enthalten",
+		"minItems":             "Das Feld '%s' muss mindestens %d Elemente haben",
+		"maxItems":             "Das Feld '%s' darf höchstens %d Elemente haben",
+		"uniqueItems":          "Das Feld '%s' muss eindeutige Elemente haben",
+		"multipleOf":           "Das Feld '%s' muss ein Vielfaches von %v sein",
+		"maxFileSize":          "Die Datei '%[2]s' im Feld '%[1]s' darf höchstens %[3]s groß sein",
+		"accept":               "Die Datei '%[2]s' im Feld '%[1]s' muss vom Typ %[3]s sein",
+		"eqfield":              "Das Feld '%s' muss gleich '%s' sein",
+		"nefield":              "Das Feld '%s' muss sich von '%s' unterscheiden",
+		"gtfield":              "Das Feld '%s' muss größer als '%s' sein",
+		"gtefield":             "Das Feld '%s' muss größer oder gleich '%s' sein",
+		"ltfield":              "Das Feld '%s' muss kleiner als '%s' sein",
+		"ltefield":             "Das Feld '%s' muss kleiner oder gleich '%s' sein",
+		"required_if":          "Das Feld '%s' ist erforderlich, wenn '%s' %s ist",
+		"required_with":        "Das Feld '%s' ist erforderlich, wenn '%s' angegeben ist",
+		"excluded_unless":      "Das Feld '%s' ist nur erlaubt, wenn '%s' %s ist",
 	},
 	"nl": {
-		"required":     "Veld '%s' is verplicht",
-		"minlength":    "Veld '%s' moet minimaal %d karakters lang zijn",
-		"maxlength":    "Veld '%s' mag maximaal %d karakters lang zijn",
-		"min":          "Veld '%s' moet minimaal %v zijn",
-		"max":          "Veld '%s' mag maximaal %v zijn",
-		"pattern":      "Veld '%s' voldoet niet aan het vereiste patroon",
-		"enum":         "Veld '%s' moet een van de volgende zijn: %s",
-		"email":        "Veld '%s' moet een geldig e-mailadres zijn",
-		"url":          "Veld '%s' moet een geldige URL zijn",
-		"uuid":         "Veld '%s' moet een geldige UUID zijn",
-		"date-time":    "Veld '%s' moet een geldige RFC3339 datum-tijd zijn",
-		"date":         "Veld '%s' moet een geldige datum zijn (JJJJ-MM-DD)",
-		"time":         "Veld '%s' moet een geldige tijd zijn (UU:MM:SS)",
-		"phone":        "Veld '%s' moet een geldig telefoonnummer zijn",
-		"password":     "Veld '%s' wachtwoord moet minimaal 8 karakters lang zijn",
-		"alphanumeric": "Veld '%s' mag alleen alfanumerieke karakters bevatten",
-		"alpha":        "Veld '%s' mag alleen alfabetische karakters bevatten",
-		"numeric":      "Veld '%s' mag alleen numerieke karakters bevatten",
-		"minItems":     "Veld '%s' moet minimaal %d items bevatten",
-		"maxItems":     "Veld '%s' mag maximaal %d items bevatten",
-		"uniqueItems":  "Veld '%s' moet unieke items bevatten",
-		"multipleOf":   "Veld '%s' moet een veelvoud zijn van %v",
+		"required":             "Veld '%s' is verplicht",
+		"minlength":            "Veld '%s' moet minimaal %d karakters lang zijn",
+		"maxlength":            "Veld '%s' mag maximaal %d karakters lang zijn",
+		"min":                  "Veld '%s' moet minimaal %v zijn",
+		"max":                  "Veld '%s' mag maximaal %v zijn",
+		"pattern":              "Veld '%s' voldoet niet aan het vereiste patroon",
+		"enum":                 "Veld '%s' moet een van de volgende zijn: %s",
+		"email":                "Veld '%s' moet een geldig e-mailadres zijn",
+		"url":                  "Veld '%s' moet een geldige URL zijn",
+		"uuid":                 "Veld '%s' moet een geldige UUID zijn",
+		"date-time":            "Veld '%s' moet een geldige RFC3339 datum-tijd zijn",
+		"date":                 "Veld '%s' moet een geldige datum zijn (JJJJ-MM-DD)",
+		"time":                 "Veld '%s' moet een geldige tijd zijn (UU:MM:SS)",
+		"phone":                "Veld '%s' moet een geldig telefoonnummer zijn",
+		"password":             "Veld '%s' wachtwoord moet minimaal 8 karakters lang zijn",
+		"alphanumeric":         "Veld '%s' mag alleen alfanumerieke karakters bevatten",
+		"alpha":                "Veld '%s' mag alleen alfabetische karakters bevatten",
+		"numeric":              "Veld '%s' mag alleen numerieke karakters bevatten",
+		"unicode-alpha":        "Veld '%s' mag alleen letters bevatten",
+		"unicode-alphanumeric": "Veld '%s' mag alleen letters en cijfers bevatten",
+		"unicode-numeric":      "Veld '%s' mag alleen cijfers bevatten",
+		"minItems":             "Veld '%s' moet minimaal %d items bevatten",
+		"maxItems":             "Veld '%s' mag maximaal %d items bevatten",
+		"uniqueItems":          "Veld '%s' moet unieke items bevatten",
+		"multipleOf":           "Veld '%s' moet een veelvoud zijn van %v",
+		"maxFileSize":          "Bestand '%[2]s' in veld '%[1]s' mag maximaal %[3]s groot zijn",
+		"accept":               "Bestand '%[2]s' in veld '%[1]s' moet van het type %[3]s zijn",
+		"eqfield":              "Veld '%s' moet gelijk zijn aan '%s'",
+		"nefield":              "Veld '%s' moet verschillen van '%s'",
+		"gtfield":              "Veld '%s' moet groter zijn dan '%s'",
+		"gtefield":             "Veld '%s' moet groter dan of gelijk aan '%s' zijn",
+		"ltfield":              "Veld '%s' moet kleiner zijn dan '%s'",
+		"ltefield":             "Veld '%s' moet kleiner dan of gelijk aan '%s' zijn",
+		"required_if":          "Veld '%s' is verplicht als '%s' %s is",
+		"required_with":        "Veld '%s' is verplicht als '%s' is ingevuld",
+		"excluded_unless":      "Veld '%s' is alleen toegestaan als '%s' %s is",
 	},
 }
 
-// detectLanguage extracts the preferred language from Accept-Language header
-func detectLanguage(acceptLanguage string) string {
-	if acceptLanguage == "" {
-		return "en"
-	}
-
-	// Parse Accept-Language header (simplified)
-	languages := strings.SplitSeq(acceptLanguage, ",")
-	for lang := range languages {
-		// Remove quality value if present (e.g., "en;q=0.9")
-		langCode := strings.TrimSpace(strings.Split(lang, ";")[0])
-		// Extract just the language part (e.g., "en" from "en-US")
-		langCode = strings.Split(langCode, "-")[0]
-
-		if _, exists := validationMessages[langCode]; exists {
-			return langCode
-		}
-	}
-
-	return "en"
-}
-
-// getMessage gets a localized validation message
-func getMessage(lang, key string, args ...any) string {
-	if messages, exists := validationMessages[lang]; exists {
-		if template, exists := messages[key]; exists {
-			return fmt.Sprintf(template, args...)
-		}
-	}
-
-	// Fallback to English
-	if messages, exists := validationMessages["en"]; exists {
-		if template, exists := messages[key]; exists {
-			return fmt.Sprintf(template, args...)
-		}
-	}
-
-	// Ultimate fallback
-	return fmt.Sprintf("Validation error for field '%v'", args[0])
-}
-
 // rebuildChain reconstructs the composed middleware chain based on the
 // currently registered middlewares. Middleware is applied in reverse order
 // so that the first registered middleware wraps the outermost layer.
+// Routers serving an API version set the version headers outside of it.
 func (r *Router) rebuildChain() {
 	r.chain = func(finalHandler http.Handler) http.Handler {
 		for i := len(r.middlewares) - 1; i >= 0; i-- {
 			finalHandler = r.middlewares[i](finalHandler)
 		}
+		if r.version != nil {
+			finalHandler = r.version.set.versionHeaders(finalHandler)
+		}
 		return finalHandler
 	}
 }
@@ -278,44 +446,164 @@ func (r *Router) rebuildChain() {
 // compilePattern converts a URL pattern string into a slice of segments.
 // Parameters are declared as {name} or {name:regex}. In the latter case,
 // the regex is precompiled and validated during route registration.
+// The last segment may be a catch-all {name...}, which also matches when no
+// segments are left, or an optional parameter {name?} or {name?:regex};
+// optional parameters may only be followed by other optional parameters.
 func compilePattern(pattern string) ([]segment, error) {
 	trimmed := strings.Trim(pattern, "/")
 	if trimmed == "" {
 		return []segment{}, nil
 	}
 
-	parts := strings.Split(trimmed, "/")
+	segs, err := compileSegments(strings.Split(trimmed, "/"))
+	if err != nil {
+		return nil, err
+	}
+	for i, seg := range segs {
+		if seg.catchAll && i != len(segs)-1 {
+			return nil, fmt.Errorf("catch-all parameter %q must be the last segment", seg.paramName)
+		}
+		if i > 0 && segs[i-1].optional && !seg.optional {
+			return nil, fmt.Errorf("optional parameter %q must be followed only by optional parameters", segs[i-1].paramName)
+		}
+	}
+	return segs, nil
+}
+
+// compileSegments compiles the parts of a path or host pattern into segments.
+func compileSegments(parts []string) ([]segment, error) {
 	segs := make([]segment, 0, len(parts))
+	seen := make(map[string]bool)
 	for _, part := range parts {
-		if len(part) >= 2 && part[0] == '{' && part[len(part)-1] == '}' {
-			inner := part[1 : len(part)-1]
-			subparts := strings.SplitN(inner, ":", 2)
-			paramName := subparts[0]
-			var re *regexp.Regexp
-			if len(subparts) == 2 {
-				// Compile the regex and add anchors.
-				pat := "^" + subparts[1] + "$"
-				rx, err := regexp.Compile(pat)
-				if err != nil {
-					return nil, err
-				}
-				re = rx
+		seg, err := compileSegment(part)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range seg.names() {
+			if seen[name] {
+				return nil, fmt.Errorf("duplicate parameter %q", name)
 			}
-			segs = append(segs, segment{
-				isParam:   true,
-				paramName: paramName,
-				regex:     re,
-			})
-		} else {
-			segs = append(segs, segment{
-				isParam: false,
-				literal: part,
-			})
+			seen[name] = true
 		}
+		segs = append(segs, seg)
 	}
 	return segs, nil
 }
 
+// compileSegment compiles a single segment. A segment that is one parameter
+// becomes a parameter segment, a segment mixing literal text and parameters is
+// compiled into one anchored regex with a capture group per parameter.
+func compileSegment(part string) (segment, error) {
+	var pieces []segment
+	for rest := part; rest != ""; {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			pieces = append(pieces, segment{literal: rest})
+			break
+		}
+		if start > 0 {
+			pieces = append(pieces, segment{literal: rest[:start]})
+		}
+		end := closingBrace(rest, start)
+		if end < 0 {
+			return segment{}, fmt.Errorf("unclosed parameter in segment %q", part)
+		}
+		p, err := compileParam(rest[start+1 : end])
+		if err != nil {
+			return segment{}, err
+		}
+		pieces = append(pieces, p)
+		rest = rest[end+1:]
+	}
+
+	if len(pieces) == 0 {
+		return segment{}, nil
+	}
+	if len(pieces) == 1 {
+		return pieces[0], nil
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, p := range pieces {
+		if !p.isParam {
+			expr.WriteString(regexp.QuoteMeta(p.literal))
+			continue
+		}
+		if p.catchAll || p.optional {
+			return segment{}, fmt.Errorf("parameter %q in segment %q cannot be a catch-all or optional", p.paramName, part)
+		}
+		if i > 0 && pieces[i-1].isParam {
+			return segment{}, fmt.Errorf("parameters in segment %q must be separated by literal text", part)
+		}
+		sub := ".+"
+		if p.regex != nil {
+			sub = strings.TrimSuffix(strings.TrimPrefix(p.regex.String(), "^"), "$")
+		}
+		fmt.Fprintf(&expr, "(?P<%s>%s)", pieceGroup(i), sub)
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return segment{}, err
+	}
+	return segment{literal: part, regex: re, pieces: pieces}, nil
+}
+
+// compileParam compiles the inside of a {...} parameter declaration.
+func compileParam(inner string) (segment, error) {
+	name, expr, hasRegex := strings.Cut(inner, ":")
+	seg := segment{isParam: true}
+	switch {
+	case strings.HasSuffix(name, "..."):
+		name = strings.TrimSuffix(name, "...")
+		seg.catchAll = true
+		if hasRegex {
+			return segment{}, fmt.Errorf("catch-all parameter %q cannot have a regex", name)
+		}
+	case strings.HasSuffix(name, "?"):
+		name = strings.TrimSuffix(name, "?")
+		seg.optional = true
+	}
+	if name == "" {
+		return segment{}, fmt.Errorf("parameter without a name in {%s}", inner)
+	}
+	seg.paramName = name
+	if hasRegex {
+		// Compile the regex and add anchors.
+		rx, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			return segment{}, err
+		}
+		seg.regex = rx
+	}
+	return seg, nil
+}
+
+// closingBrace returns the index of the brace closing the one at start, taking
+// nested braces in regexes such as [0-9]{4} into account, or -1.
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// pieceGroup returns the name of the capture group for piece i of a segment
+// with embedded parameters.
+func pieceGroup(i int) string {
+	return "p" + strconv.Itoa(i)
+}
+
 // joinPaths concatenates two URL path segments ensuring exactly one "/" between them.
 // It handles edge cases where either path is empty and normalizes trailing/leading slashes.
 func joinPaths(a, b string) string {
@@ -333,130 +621,517 @@ func joinPaths(a, b string) string {
 	return a + "/" + b
 }
 
+// hasSegmentPrefix reports whether the path segments start with every segment
+// of prefix. Unlike strings.HasPrefix, it respects segment boundaries, so
+// "/api" is a prefix of "/api/users" but not of "/apiv2" or "/api-docs".
+func hasSegmentPrefix(parts []string, prefix string) bool {
+	prefix = strings.Trim(prefix, "/")
+	for i := 0; prefix != ""; i++ {
+		if i >= len(parts) {
+			return false
+		}
+		seg, rest, _ := strings.Cut(prefix, "/")
+		if parts[i] != seg {
+			return false
+		}
+		prefix = rest
+	}
+	return true
+}
+
 // matchSegments checks if the given URL path matches the compiled segments.
 // If the path matches, it returns true and a map of parameter names to their values.
 // Parameter validation is performed using regex patterns if specified.
 func matchSegments(path string, segments []segment) (bool, map[string]string) {
-	trimmed := strings.Trim(path, "/")
-	var pathParts []string
-	if trimmed == "" {
-		pathParts = []string{}
-	} else {
-		pathParts = strings.Split(trimmed, "/")
-	}
-
-	if len(pathParts) != len(segments) {
-		return false, nil
-	}
+	pathParts := splitPath(path)
 
 	params := make(map[string]string)
 	for i, seg := range segments {
-		part := pathParts[i]
-		if seg.isParam {
-			if seg.regex != nil && !seg.regex.MatchString(part) {
+		if seg.catchAll {
+			params[seg.paramName] = strings.Join(pathParts[i:], "/")
+			return true, params
+		}
+		if i >= len(pathParts) {
+			if !seg.optional {
 				return false, nil
 			}
-			params[seg.paramName] = part
-		} else if seg.literal != part {
+			continue
+		}
+		if !seg.matches(pathParts[i]) {
 			return false, nil
 		}
+		seg.capture(pathParts[i], params)
+	}
+	if len(pathParts) > len(segments) {
+		return false, nil
 	}
 	return true, params
 }
 
+// BindError reports a form, query, header or cookie value that could not be
+// converted to the type of the struct field it is bound to.
+type BindError struct {
+	// Field is the path of the field in form notation, e.g. "items[0].qty".
+	Field string
+	// Value is the offending value.
+	Value string
+	// Err is the underlying conversion error.
+	Err error
+}
+
+// Error returns a message naming the field path and the offending value.
+func (e *BindError) Error() string {
+	return fmt.Sprintf("invalid value %q for field %q: %v", e.Value, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying conversion error.
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// timeLayouts are the layouts tried in order when binding a time.Time field.
+// They cover RFC 3339 and the values sent by date and datetime-local inputs.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
 // bindFormToStruct uses reflection to bind form values to struct fields.
 // Field names are matched using JSON tags (without omitempty) or struct field names.
-// Supports automatic type conversion for string, bool, int, and float types.
+// Nested structs use dot notation ("address.city") and slices of structs use
+// bracket notation ("items[0].qty"). Slices of other types are filled from
+// repeated keys or indexed keys ("tags[0]"). Supported field types are strings,
+// bools, all integer and float kinds, time.Time, time.Duration, types implementing
+// encoding.TextUnmarshaler, pointers to and slices of these, and nested structs.
+// Conversion failures are returned as *BindError.
 func bindFormToStruct(form url.Values, v any) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("v must be a pointer to struct")
 	}
+	return bindFormValues(form, rv.Elem(), "")
+}
 
-	rv = rv.Elem()
+// bindFormValues binds the fields of the struct value rv from the form keys
+// starting with prefix.
+func bindFormValues(form url.Values, rv reflect.Value, prefix string) error {
 	rt := rv.Type()
 
 	for i := range rv.NumField() {
 		field := rv.Field(i)
+		fieldType := rt.Field(i)
 		if !field.CanSet() {
 			continue
 		}
 
-		fieldType := rt.Field(i)
 		jsonTag := fieldType.Tag.Get("json")
 		if jsonTag == "-" {
 			continue
 		}
+		// Fields bound from path, query, header or cookie are handled by bindParamsToStruct,
+		// uploaded files by bindFilesToStruct.
+		if _, _, _, ok := paramTag(fieldType); ok || isFileField(fieldType.Type) {
+			continue
+		}
 
 		name := strings.Split(jsonTag, ",")[0]
+		if fieldType.Anonymous && name == "" && fieldType.Type.Kind() == reflect.Struct {
+			// Embedded structs share the prefix of their parent.
+			if err := bindFormValues(form, field, prefix); err != nil {
+				return err
+			}
+			continue
+		}
 		if name == "" {
 			name = fieldType.Name
 		}
 
-		value := form.Get(name)
-		if err := setFieldValue(field, value); err != nil {
-			return fmt.Errorf("field %s: %w", name, err)
+		if err := bindFormField(form, field, prefix+name); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// setFieldValue sets a reflect.Value based on its type and the string value from form data.
-// Handles automatic type conversion for common types including special checkbox handling.
-func setFieldValue(field reflect.Value, value string) error {
-	switch field.Kind() {
-	case reflect.String:
-		field.SetString(value)
-	case reflect.Bool:
-		// Handle checkbox values (HTML checkboxes send "on" when checked, nothing when unchecked)
-		if value == "on" || value == "true" || value == "1" {
-			field.SetBool(true)
+// bindFormField binds the form values for key into field.
+func bindFormField(form url.Values, field reflect.Value, key string) error {
+	t := field.Type()
+
+	switch {
+	case isScalarType(t) || (t.Kind() == reflect.Pointer && isScalarType(t.Elem())):
+		if values, ok := form[key]; ok {
+			return setFieldValues(field, key, values)
 		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if value != "" {
-			i, err := strconv.ParseInt(value, 10, 64)
-			if err != nil {
+	case t.Kind() == reflect.Pointer:
+		if !hasFormKey(form, key) {
+			return nil
+		}
+		if field.IsNil() {
+			field.Set(reflect.New(t.Elem()))
+		}
+		return bindFormField(form, field.Elem(), key)
+	case t.Kind() == reflect.Struct:
+		return bindFormValues(form, field, key+".")
+	case t.Kind() == reflect.Slice && isStructLike(t.Elem()):
+		indices := formIndices(form, key, true)
+		if len(indices) == 0 {
+			return nil
+		}
+		slice := reflect.MakeSlice(t, len(indices), len(indices))
+		for i, idx := range indices {
+			if err := bindFormField(form, slice.Index(i), fmt.Sprintf("%s[%d]", key, idx)); err != nil {
 				return err
 			}
-			field.SetInt(i)
 		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if value != "" {
-			i, err := strconv.ParseUint(value, 10, 64)
-			if err != nil {
+		field.Set(slice)
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		values, ok := form[key]
+		if !ok {
+			for _, idx := range formIndices(form, key, false) {
+				values = append(values, form.Get(fmt.Sprintf("%s[%d]", key, idx)))
+			}
+		}
+		if len(values) > 0 {
+			return setFieldValues(field, key, values)
+		}
+	}
+	return nil
+}
+
+// isScalarType reports whether t is bound from a single value rather than from
+// nested keys or repeated values.
+func isScalarType(t reflect.Type) bool {
+	if t == timeType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Pointer, reflect.Slice, reflect.Array,
+		reflect.Map, reflect.Chan, reflect.Func, reflect.Interface:
+		return false
+	}
+	return true
+}
+
+// isStructLike reports whether t is bound from nested keys: a struct, or a
+// pointer to a struct, that is not bound from a single value.
+func isStructLike(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !isScalarType(t)
+}
+
+// hasFormKey reports whether the form contains key itself or any key nested
+// below it.
+func hasFormKey(form url.Values, key string) bool {
+	if _, ok := form[key]; ok {
+		return true
+	}
+	for k := range form {
+		if strings.HasPrefix(k, key+".") || strings.HasPrefix(k, key+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// formIndices returns the sorted, distinct indices used in keys of the form
+// "key[i]". If nested is true, keys of the form "key[i].field" are counted too.
+func formIndices(form url.Values, key string, nested bool) []int {
+	var indices []int
+	for k := range form {
+		rest, ok := strings.CutPrefix(k, key+"[")
+		if !ok {
+			continue
+		}
+		idx, after, ok := strings.Cut(rest, "]")
+		if !ok || (after != "" && (!nested || !strings.HasPrefix(after, "."))) {
+			continue
+		}
+		n, err := strconv.Atoi(idx)
+		if err != nil || n < 0 || slices.Contains(indices, n) {
+			continue
+		}
+		indices = append(indices, n)
+	}
+	slices.Sort(indices)
+	return indices
+}
+
+// paramSources lists the struct tags that bind a field to a request parameter.
+// They are named after the OpenAPI parameter locations.
+var paramSources = []string{"path", "query", "header", "cookie"}
+
+// paramTag returns the parameter location and name of a field tagged with path,
+// query, header or cookie, and whether the tag has the omitempty option.
+// ok is false if the field has none of these tags.
+func paramTag(f reflect.StructField) (in, name string, optional, ok bool) {
+	for _, src := range paramSources {
+		tag, exists := f.Tag.Lookup(src)
+		if !exists {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		name = parts[0]
+		if name == "" {
+			name = f.Name
+		}
+		return src, name, slices.Contains(parts[1:], "omitempty"), true
+	}
+	return "", "", false, false
+}
+
+// bindParamsToStruct binds path parameters, query parameters, headers and cookies
+// into the fields tagged with path, query, header or cookie. Fields whose value is
+// absent from the request are left untouched. Repeated query parameters and
+// headers fill slice fields. A value that cannot be converted results in an
+// *HTTPError with status 400 that names the parameter.
+func bindParamsToStruct(req *http.Request, pathParams map[string]string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("v must be a pointer to struct")
+	}
+	rv = rv.Elem()
+	query := req.URL.Query()
+
+	for _, f := range reflect.VisibleFields(rv.Type()) {
+		if f.PkgPath != "" {
+			continue
+		}
+		in, name, _, ok := paramTag(f)
+		if !ok {
+			continue
+		}
+
+		var values []string
+		switch in {
+		case "path":
+			if value, ok := pathParams[name]; ok {
+				values = []string{value}
+			}
+		case "query":
+			values = query[name]
+		case "header":
+			values = req.Header.Values(name)
+		case "cookie":
+			if c, err := req.Cookie(name); err == nil {
+				values = []string{c.Value}
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		field, err := rv.FieldByIndexErr(f.Index)
+		if err != nil {
+			// Promoted through a nil embedded pointer.
+			continue
+		}
+		if err := setFieldValues(field, name, values); err != nil {
+			return NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("invalid %s parameter %q", in, name), err)
+		}
+	}
+
+	return nil
+}
+
+// setFieldValues converts values and stores them in field. Scalar fields use the
+// first value, slices get one element per value and arrays are filled up to their
+// length. path names the field in errors.
+func setFieldValues(field reflect.Value, path string, values []string) error {
+	t := field.Type()
+	switch {
+	case isScalarType(t) || (t.Kind() == reflect.Pointer && isScalarType(t.Elem())):
+		return setFieldValue(field, path, values[0])
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		field.SetBytes([]byte(values[0]))
+	case t.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(t, len(values), len(values))
+		for i, v := range values {
+			if err := setFieldValue(slice.Index(i), fmt.Sprintf("%s[%d]", path, i), v); err != nil {
 				return err
 			}
-			field.SetUint(i)
 		}
-	case reflect.Float32, reflect.Float64:
-		if value != "" {
-			f, err := strconv.ParseFloat(value, 64)
-			if err != nil {
+		field.Set(slice)
+	case t.Kind() == reflect.Array:
+		for i, v := range values {
+			if i >= field.Len() {
+				break
+			}
+			if err := setFieldValue(field.Index(i), fmt.Sprintf("%s[%d]", path, i), v); err != nil {
 				return err
 			}
-			field.SetFloat(f)
-		}
-	case reflect.Slice:
-		// Handle multiple form values (e.g., checkboxes with same name)
-		if field.Type().Elem().Kind() == reflect.String {
-			values := strings.Split(value, ",")
-			slice := reflect.MakeSlice(field.Type(), len(values), len(values))
-			for i, v := range values {
-				slice.Index(i).SetString(strings.TrimSpace(v))
+		}
+	}
+	return nil
+}
+
+// setFieldValue sets a reflect.Value based on its type and the string value from form data.
+// Handles automatic type conversion for common types including special checkbox handling.
+// Empty values leave numeric, time and pointer fields untouched. Conversion failures
+// are returned as *BindError naming path and value.
+func setFieldValue(field reflect.Value, path, value string) error {
+	t := field.Type()
+	if t.Kind() == reflect.Pointer {
+		if value == "" {
+			return nil
+		}
+		if field.IsNil() {
+			field.Set(reflect.New(t.Elem()))
+		}
+		return setFieldValue(field.Elem(), path, value)
+	}
+
+	var err error
+	switch {
+	case t == timeType:
+		if value == "" {
+			return nil
+		}
+		for _, layout := range timeLayouts {
+			var tm time.Time
+			if tm, err = time.Parse(layout, value); err == nil {
+				field.Set(reflect.ValueOf(tm))
+				return nil
+			}
+		}
+	case t == durationType:
+		if value == "" {
+			return nil
+		}
+		var d time.Duration
+		if d, err = time.ParseDuration(value); err == nil {
+			field.SetInt(int64(d))
+		}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		err = field.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	default:
+		switch t.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Bool:
+			// Handle checkbox values (HTML checkboxes send "on" when checked, nothing when unchecked)
+			switch value {
+			case "on", "true", "1":
+				field.SetBool(true)
+			case "", "off", "false", "0":
+				field.SetBool(false)
+			default:
+				err = fmt.Errorf("not a boolean")
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if value != "" {
+				var i int64
+				if i, err = strconv.ParseInt(value, 10, t.Bits()); err == nil {
+					field.SetInt(i)
+				}
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if value != "" {
+				var u uint64
+				if u, err = strconv.ParseUint(value, 10, t.Bits()); err == nil {
+					field.SetUint(u)
+				}
+			}
+		case reflect.Float32, reflect.Float64:
+			if value != "" {
+				var f float64
+				if f, err = strconv.ParseFloat(value, t.Bits()); err == nil {
+					field.SetFloat(f)
+				}
+			}
+		default:
+			err = fmt.Errorf("unsupported field type %s", t)
+		}
+	}
+
+	if err != nil {
+		return &BindError{Field: path, Value: value, Err: err}
+	}
+	return nil
+}
+
+// tagChecks caches the result of checkTags by struct type.
+var tagChecks sync.Map
+
+// tagCheck is the cached result of checkTags.
+type tagCheck struct {
+	err error
+}
+
+// checkTags reports malformed validation tags of the struct type t and the
+// structs nested in it, such as maxFileSize:"5M", a validate tag naming an
+// unknown validator or a normalize tag naming an unregistered form. The result is cached per
+// type, so a broken tag fails every validation of the type from its first use,
+// instead of only the requests whose values happen to reach the tag.
+func checkTags(t reflect.Type) error {
+	if c, ok := tagChecks.Load(t); ok {
+		return c.(tagCheck).err
+	}
+	err := checkStructTags(t, map[reflect.Type]bool{})
+	tagChecks.Store(t, tagCheck{err})
+	return err
+}
+
+// checkStructTags checks the tags of the fields of the struct type t and of
+// the structs nested in it. seen guards against recursive types.
+func checkStructTags(t reflect.Type, seen map[reflect.Type]bool) error {
+	seen[t] = true
+	for _, f := range reflect.VisibleFields(t) {
+		if f.PkgPath != "" {
+			continue
+		}
+		if err := checkFieldTags(f); err != nil {
+			return fmt.Errorf("%s.%s: %w", t, f.Name, err)
+		}
+		nested := f.Type
+		for nested.Kind() == reflect.Pointer || nested.Kind() == reflect.Slice || nested.Kind() == reflect.Array {
+			nested = nested.Elem()
+		}
+		if isStructLike(nested) && !seen[nested] {
+			if err := checkStructTags(nested, seen); err != nil {
+				return err
 			}
-			field.Set(slice)
 		}
 	}
 	return nil
 }
 
+// checkFieldTags checks the validation tags of a single field.
+func checkFieldTags(f reflect.StructField) error {
+	if sizeTag := f.Tag.Get("maxFileSize"); sizeTag != "" {
+		if _, err := parseByteSize(sizeTag); err != nil {
+			return fmt.Errorf("invalid maxFileSize tag: %w", err)
+		}
+	}
+	if err := checkNormalizeTag(f.Tag.Get("normalize")); err != nil {
+		return err
+	}
+	return checkValidateTag(f.Tag.Get("validate"))
+}
+
 // validateStruct walks a struct’s exported fields, applies all tag‐based
 // validations, and returns every violation found rather than failing fast.
 //
 // val may be a struct or a pointer to struct. lang selects the locale for
-// error messages.
-func validateStruct(val any, lang string) error {
+// error messages and ctx is passed to custom validators. Errors of custom
+// validators are returned as is instead of as ValidationErrors.
+//
+// Malformed tags of the type are reported as an error, see checkTags.
+//
+// String fields with a trim or normalize tag are rewritten in place with the
+// sanitized value before they are validated; other fields are left untouched.
+func validateStruct(ctx context.Context, val any, lang string) error {
 	v := reflect.ValueOf(val)
 	for v.Kind() == reflect.Pointer {
 		if v.IsNil() {
@@ -467,6 +1142,9 @@ func validateStruct(val any, lang string) error {
 	if v.Kind() != reflect.Struct {
 		return nil
 	}
+	if err := checkTags(v.Type()); err != nil {
+		return err
+	}
 
 	var allErrs ValidationErrors
 	t := v.Type()
@@ -479,24 +1157,43 @@ func validateStruct(val any, lang string) error {
 		if name == "" || name == "-" {
 			name = f.Name
 		}
-		fv := v.FieldByIndex(f.Index)
 		required := !slices.Contains(parts[1:], "omitempty")
+		if _, paramName, optional, ok := paramTag(f); ok {
+			name, required = paramName, !optional
+		}
+		if isConditional(f) {
+			required = false
+		}
+		fv := v.FieldByIndex(f.Index)
 		custom := f.Tag.Get("error")
+		if fv.Kind() == reflect.String && fv.CanSet() && sanitizes(f) {
+			fv.SetString(sanitizeString(fv.String(), f))
+		}
 
 		if required && fv.IsZero() {
-			if custom != "" {
-				allErrs = append(allErrs, fmt.Errorf("%s", custom))
-			} else {
-				allErrs = append(allErrs,
-					fmt.Errorf("%s", getMessage(lang, "required", name)))
-			}
+			allErrs = append(allErrs, newFieldError(name, "required", nil, lang, custom))
 			continue
 		}
 
-		if errs := validateField(fv, f, name, lang, custom); len(errs) > 0 {
-			allErrs = append(allErrs, errs...)
+		errs, err := validateField(ctx, fv, f, name, lang, custom)
+		if err != nil {
+			return err
+		}
+		allErrs = append(allErrs, errs...)
+
+		errs, err = validateCrossFields(fv, v, f, name, lang, custom)
+		if err != nil {
+			return err
+		}
+		allErrs = append(allErrs, errs...)
+
+		errs, err = runValidators(ctx, fv, v, f, name, lang, custom)
+		if err != nil {
+			return err
 		}
+		allErrs = append(allErrs, errs...)
 	}
+	allErrs = append(allErrs, runStructValidator(v)...)
 
 	if len(allErrs) > 0 {
 		return allErrs
@@ -507,72 +1204,81 @@ func validateStruct(val any, lang string) error {
 // validateField routes to the appropriate validator based on kind,
 // returning all errors for that field.
 func validateField(
+	ctx context.Context,
 	fv reflect.Value,
 	f reflect.StructField,
 	fieldName, lang, custom string,
-) ValidationErrors {
+) (ValidationErrors, error) {
+	if isFileField(fv.Type()) {
+		return validateFileField(fv, f, fieldName, lang, custom), nil
+	}
 	switch fv.Kind() {
 	case reflect.String:
-		return validateStringField(fv.String(), f, fieldName, lang, custom)
+		return validateStringField(fv.String(), f, fieldName, lang, custom), nil
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return validateNumericField(float64(fv.Int()), f, fieldName, lang, custom)
+		return validateNumericField(float64(fv.Int()), f, fieldName, lang, custom), nil
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return validateNumericField(float64(fv.Uint()), f, fieldName, lang, custom)
+		return validateNumericField(float64(fv.Uint()), f, fieldName, lang, custom), nil
 	case reflect.Float32, reflect.Float64:
-		return validateNumericField(fv.Float(), f, fieldName, lang, custom)
+		return validateNumericField(fv.Float(), f, fieldName, lang, custom), nil
 	case reflect.Slice, reflect.Array:
-		return validateSliceField(fv, f, fieldName, lang, custom)
+		return validateSliceField(ctx, fv, f, fieldName, lang, custom)
 	case reflect.Struct:
-		if err := validateStruct(fv.Addr().Interface(), lang); err != nil {
-			return ValidationErrors{err}
+		if !fv.CanAddr() {
+			return nestedErrors(validateStruct(ctx, fv.Interface(), lang), pointerSegment(fieldName))
 		}
+		return nestedErrors(validateStruct(ctx, fv.Addr().Interface(), lang), pointerSegment(fieldName))
 	case reflect.Pointer:
 		if !fv.IsNil() {
-			if err := validateStruct(fv.Interface(), lang); err != nil {
-				return ValidationErrors{err}
-			}
+			return nestedErrors(validateStruct(ctx, fv.Interface(), lang), pointerSegment(fieldName))
 		}
 	}
-	return nil
+	return nil, nil
+}
+
+// nestedErrors splits the result of validating a nested struct found at the
+// JSON pointer path into its violations, kept as a single entry, and an error
+// of a custom validator.
+func nestedErrors(err error, path string) (ValidationErrors, error) {
+	var ve ValidationErrors
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.As(err, &ve) {
+		return nil, err
+	}
+	return ValidationErrors{prefixFields(ve, path)}, nil
 }
 
 // validateStringField applies minlength, maxlength, pattern, enum and format
-// checks on s, returning every violation.
+// checks on s, returning every violation. Lengths are counted as the length
+// tag of f says, see stringLength.
 func validateStringField(
 	s string,
 	f reflect.StructField,
 	fieldName, lang, custom string,
 ) ValidationErrors {
 	var errs ValidationErrors
+	fail := func(code string, params map[string]any, args ...any) {
+		errs = append(errs, newFieldError(fieldName, code, params, lang, custom, args...))
+	}
 
 	// minlength / maxlength
 	if minTag := f.Tag.Get("minlength"); minTag != "" {
-		if min, _ := strconv.Atoi(minTag); len(s) < min {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "minlength", fieldName, min)
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+		if min, _ := strconv.Atoi(minTag); stringLength(s, f) < min {
+			fail("minlength", map[string]any{"limit": min}, min)
 		}
 	}
 	if maxTag := f.Tag.Get("maxlength"); maxTag != "" {
-		if max, _ := strconv.Atoi(maxTag); len(s) > max {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "maxlength", fieldName, max)
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+		if max, _ := strconv.Atoi(maxTag); stringLength(s, f) > max {
+			fail("maxlength", map[string]any{"limit": max}, max)
 		}
 	}
 
 	// pattern
 	if pattern := f.Tag.Get("pattern"); pattern != "" && s != "" {
 		if matched, _ := regexp.MatchString(pattern, s); !matched {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "pattern", fieldName)
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+			fail("pattern", map[string]any{"pattern": pattern})
 		}
 	}
 
@@ -580,12 +1286,7 @@ func validateStringField(
 	if enum := f.Tag.Get("enum"); enum != "" && s != "" {
 		allowed := strings.Split(enum, "|")
 		if !slices.Contains(allowed, s) {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "enum",
-					fieldName, strings.Join(allowed, ", "))
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+			fail("enum", map[string]any{"values": allowed}, strings.Join(allowed, ", "))
 		}
 	}
 
@@ -594,20 +1295,12 @@ func validateStringField(
 		switch f.Tag.Get("format") {
 		case "email":
 			if _, err := mail.ParseAddress(s); err != nil {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "email", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("email", nil)
 			}
 		case "url":
 			if u, err := url.ParseRequestURI(s); err != nil ||
 				u.Scheme == "" || u.Host == "" {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "url", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("url", nil)
 			}
 		case "uuid":
 			// RFC-4122 v4 UUID
@@ -615,78 +1308,54 @@ func validateStringField(
 				`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-4[0-9a-fA-F]{3}-[89ABab][0-9a-fA-F]{3}-[0-9a-fA-F]{12}$`,
 				s,
 			); !matched {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "uuid", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("uuid", nil)
 			}
 		case "date-time":
 			if _, err := time.Parse(time.RFC3339, s); err != nil {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "date-time", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("date-time", nil)
 			}
 		case "date":
 			if _, err := time.Parse("2006-01-02", s); err != nil {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "date", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("date", nil)
 			}
 		case "time":
 			if _, err := time.Parse("15:04:05", s); err != nil {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "time", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("time", nil)
 			}
 		case "password":
-			if len(s) < 8 {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "password", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+			if stringLength(s, f) < 8 {
+				fail("password", nil)
 			}
 		case "phone":
 			if matched, _ := regexp.MatchString(
 				`^[\+]?[1-9][\d\s\-\(\)]{7,15}$`,
 				s,
 			); !matched {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "phone", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("phone", nil)
 			}
 		case "alphanumeric":
 			if matched, _ := regexp.MatchString(`^[A-Za-z0-9]+$`, s); !matched {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "alphanumeric", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("alphanumeric", nil)
 			}
 		case "alpha":
 			if matched, _ := regexp.MatchString(`^[A-Za-z]+$`, s); !matched {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "alpha", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("alpha", nil)
 			}
 		case "numeric":
 			if matched, _ := regexp.MatchString(`^[0-9]+$`, s); !matched {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "numeric", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("numeric", nil)
+			}
+		case "unicode-alpha":
+			if !onlyRunes(s, unicode.Letter) {
+				fail("unicode-alpha", nil)
+			}
+		case "unicode-alphanumeric":
+			if !onlyRunes(s, unicode.Letter, unicode.Nd) {
+				fail("unicode-alphanumeric", nil)
+			}
+		case "unicode-numeric":
+			if !onlyRunes(s, unicode.Nd) {
+				fail("unicode-numeric", nil)
 			}
 		}
 	}
@@ -702,34 +1371,24 @@ func validateNumericField(
 	fieldName, lang, custom string,
 ) ValidationErrors {
 	var errs ValidationErrors
+	fail := func(code string, params map[string]any, args ...any) {
+		errs = append(errs, newFieldError(fieldName, code, params, lang, custom, args...))
+	}
 
 	if minTag := f.Tag.Get("min"); minTag != "" {
 		if min, _ := strconv.ParseFloat(minTag, 64); num < min {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "min", fieldName, min)
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+			fail("min", map[string]any{"limit": min}, min)
 		}
 	}
 	if maxTag := f.Tag.Get("max"); maxTag != "" {
 		if max, _ := strconv.ParseFloat(maxTag, 64); num > max {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "max", fieldName, max)
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+			fail("max", map[string]any{"limit": max}, max)
 		}
 	}
 	if multTag := f.Tag.Get("multipleOf"); multTag != "" {
 		if mult, _ := strconv.ParseFloat(multTag, 64); mult != 0 {
 			if rem := num - (mult * float64(int(num/mult))); rem != 0 {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "multipleOf",
-						fieldName, mult)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("multipleOf", map[string]any{"divisor": mult}, mult)
 			}
 		}
 	}
@@ -739,29 +1398,25 @@ func validateNumericField(
 // validateSliceField applies minItems, maxItems, uniqueItems and recurses
 // into elements, returning every violation.
 func validateSliceField(
+	ctx context.Context,
 	fv reflect.Value,
 	f reflect.StructField,
 	fieldName, lang, custom string,
-) ValidationErrors {
+) (ValidationErrors, error) {
 	var errs ValidationErrors
+	fail := func(code string, params map[string]any, args ...any) {
+		errs = append(errs, newFieldError(fieldName, code, params, lang, custom, args...))
+	}
 	length := fv.Len()
 
 	if minTag := f.Tag.Get("minItems"); minTag != "" {
 		if min, _ := strconv.Atoi(minTag); length < min {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "minItems", fieldName, min)
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+			fail("minItems", map[string]any{"limit": min}, min)
 		}
 	}
 	if maxTag := f.Tag.Get("maxItems"); maxTag != "" {
 		if max, _ := strconv.Atoi(maxTag); length > max {
-			msg := custom
-			if msg == "" {
-				msg = getMessage(lang, "maxItems", fieldName, max)
-			}
-			errs = append(errs, fmt.Errorf("%s", msg))
+			fail("maxItems", map[string]any{"limit": max}, max)
 		}
 	}
 	if f.Tag.Get("uniqueItems") == "true" {
@@ -769,11 +1424,7 @@ func validateSliceField(
 		for i := range length {
 			v := fv.Index(i).Interface()
 			if seen[v] {
-				msg := custom
-				if msg == "" {
-					msg = getMessage(lang, "uniqueItems", fieldName)
-				}
-				errs = append(errs, fmt.Errorf("%s", msg))
+				fail("uniqueItems", nil)
 			}
 			seen[v] = true
 		}
@@ -782,13 +1433,21 @@ func validateSliceField(
 		item := fv.Index(i)
 		if item.Kind() == reflect.Struct ||
 			(item.Kind() == reflect.Pointer && !item.IsNil()) {
-			if sub := validateStruct(item.Interface(), lang); sub != nil {
+			if item.Kind() == reflect.Struct && item.CanAddr() {
+				item = item.Addr()
+			}
+			path := pointerSegment(fieldName) + "/" + strconv.Itoa(i)
+			sub, err := nestedErrors(validateStruct(ctx, item.Interface(), lang), path)
+			if err != nil {
+				return nil, err
+			}
+			if sub != nil {
 				errs = append(errs,
-					fmt.Errorf("%s[%d]: %w", fieldName, i, sub))
+					fmt.Errorf("%s[%d]: %w", fieldName, i, sub[0]))
 			}
 		}
 	}
-	return errs
+	return errs, nil
 }
 
 // Static mounts an fs.FS under the given URL prefix.
@@ -797,7 +1456,7 @@ func (r *Router) Static(urlPathPrefix string, subFS fs.FS) {
 	if prefix == "/" {
 		prefix = ""
 	}
-	routePattern := path.Join(prefix, "{filepath:.*}")
+	routePattern := path.Join(prefix, "{filepath...}")
 
 	stripPrefix := prefix + "/"
 	if stripPrefix == "/" {
@@ -813,6 +1472,9 @@ func (r *Router) Static(urlPathPrefix string, subFS fs.FS) {
 }
 
 // ValidationErrors collects one or more validation violations and implements error.
+// The violations found by validation are *FieldError values; the violations
+// of a nested struct are kept together in a single entry. Use Fields to get
+// them all.
 type ValidationErrors []error
 
 // Error joins all contained error messages into a single string.
@@ -824,6 +1486,114 @@ func (ve ValidationErrors) Error() string {
 	return strings.Join(msgs, "; ")
 }
 
+// Fields returns every violation, including those of nested structs, as a
+// FieldError. Entries that are not a *FieldError, e.g. added by hand, become a
+// FieldError with only a message.
+func (ve ValidationErrors) Fields() []*FieldError {
+	var fields []*FieldError
+	for _, err := range ve {
+		var nested ValidationErrors
+		var fe *FieldError
+		switch {
+		case errors.As(err, &nested):
+			fields = append(fields, nested.Fields()...)
+		case errors.As(err, &fe):
+			fields = append(fields, fe)
+		default:
+			fields = append(fields, &FieldError{Message: err.Error()})
+		}
+	}
+	return fields
+}
+
+// MarshalJSON renders the violations as an object keyed by field path, each
+// holding the list of violations of that field:
+//
+//	{"/email": [{"code": "email", "message": "Field 'email' must be a valid email address"}]}
+func (ve ValidationErrors) MarshalJSON() ([]byte, error) {
+	type violation struct {
+		Code    string         `json:"code"`
+		Params  map[string]any `json:"params,omitempty"`
+		Message string         `json:"message"`
+	}
+	byField := make(map[string][]violation)
+	for _, fe := range ve.Fields() {
+		byField[fe.Field] = append(byField[fe.Field], violation{fe.Code, fe.Params, fe.Message})
+	}
+	return json.Marshal(byField)
+}
+
+// FieldError is a single validation violation.
+type FieldError struct {
+	// Field is the RFC 6901 JSON pointer of the field within the validated
+	// struct, built from json or parameter names, e.g. "/contacts/1/email".
+	// It is "" for violations of the struct as a whole.
+	Field string `json:"field"`
+	// Code is the rule that failed: the tag name such as "minlength" or
+	// "eqfield", the format such as "email", the name of a custom validator,
+	// or "required".
+	Code string `json:"code"`
+	// Params holds the parameters of the rule, e.g. {"limit": 8} for
+	// minlength:"8" or {"field": "password"} for eqfield:"password".
+	Params map[string]any `json:"params,omitempty"`
+	// Message is the localized message, or the message of the error tag.
+	Message string `json:"message"`
+
+	// err is the error returned by a Validate method, if any.
+	err error
+}
+
+// Error returns the message.
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error returned by a Validate method that the violation
+// was created from, or nil.
+func (e *FieldError) Unwrap() error {
+	return e.err
+}
+
+// newFieldError returns the violation of rule code by the field named
+// fieldName. The message is custom if set, or the message for code in lang
+// with fieldName and args as arguments.
+func newFieldError(fieldName, code string, params map[string]any, lang, custom string, args ...any) *FieldError {
+	msg := custom
+	if msg == "" {
+		msg = getMessage(lang, code, append([]any{fieldName}, args...)...)
+	}
+	return &FieldError{Field: pointerSegment(fieldName), Code: code, Params: params, Message: msg}
+}
+
+// pointerSegment returns name as a JSON pointer reference token, escaping "~"
+// and "/".
+func pointerSegment(name string) string {
+	return "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+// prefixFields returns a copy of ve with prefix prepended to the field path of
+// every violation, which belong to a struct found at prefix. Violations are
+// copied rather than changed, since a Validate method may return the same
+// *FieldError on every call.
+func prefixFields(ve ValidationErrors, prefix string) ValidationErrors {
+	prefixed := make(ValidationErrors, len(ve))
+	for i, err := range ve {
+		var nested ValidationErrors
+		var fe *FieldError
+		switch {
+		case errors.As(err, &nested):
+			prefixed[i] = prefixFields(nested, prefix)
+		case errors.As(err, &fe):
+			c := *fe
+			c.Field = prefix + fe.Field
+			prefixed[i] = &c
+		default:
+			prefixed[i] = err
+		}
+	}
+	return prefixed
+}
+
 // JSON sends a JSON response with the given status code and data.
 // It automatically sets the Content-Type header to "application/json" and
 // handles JSON encoding. Returns an error if encoding fails.
@@ -887,23 +1657,43 @@ func (rc *ResponseContext) Writer() http.ResponseWriter {
 
 // WantsJSON returns true if the request expects a JSON response based on
 // Content-Type or Accept headers. Useful for dual HTML/JSON endpoints.
+// The request body being JSON counts, as does an Accept header that asks for
+// JSON explicitly and ranks it at least as high as HTML by q-value. A bare
+// "*/*" does not.
 func (rc *ResponseContext) WantsJSON() bool {
-	contentType := rc.r.Header.Get("Content-Type")
-	accept := rc.r.Header.Get("Accept")
-	return strings.Contains(contentType, "application/json") ||
-		strings.Contains(accept, "application/json")
+	return isJSONMediaType(requestMediaType(rc.r)) ||
+		prefersJSON(rc.r.Header.Get("Accept"))
 }
 
 // Bind automatically unmarshals and validates request data into the provided struct.
-// It supports both JSON and form data, with automatic content-type detection.
-// The struct should be a pointer. Validation is performed if validation middleware is active.
+// Form data is bound for form content types and requests without a Content-Type;
+// other bodies are decoded with the decoder registered for the Content-Type (see
+// Router.RegisterDecoder). An unsupported Content-Type results in an *HTTPError
+// with status 415. Fields tagged with path, query, header or cookie are then filled
+// from the request (see BindParams). The struct should be a pointer. Validation is
+// performed if validation middleware is active.
 func (rc *ResponseContext) Bind(v any) error {
-	contentType := rc.r.Header.Get("Content-Type")
+	mediaType := requestMediaType(rc.r)
 
-	if strings.Contains(contentType, "application/json") {
-		return rc.BindJSON(v)
+	var err error
+	if isFormMediaType(mediaType) {
+		err = rc.BindForm(v)
+	} else {
+		err = rc.decodeBody(mediaType, v)
+	}
+	if err != nil {
+		return err
 	}
-	return rc.BindForm(v)
+	return rc.BindParams(v)
+}
+
+// BindParams binds path parameters, query parameters, headers and cookies into the
+// provided struct pointer. Fields are selected with the path, query, header and
+// cookie tags, e.g. `path:"id"`, `query:"page"`, `header:"X-Tenant"` or
+// `cookie:"session"`. Values missing from the request leave the field untouched.
+func (rc *ResponseContext) BindParams(v any) error {
+	params, _ := rc.r.Context().Value(rc.router.paramsKey).(map[string]string)
+	return bindParamsToStruct(rc.r, params, v)
 }
 
 // BindJSON unmarshals JSON request body into the provided struct.
@@ -917,53 +1707,106 @@ func (rc *ResponseContext) BindJSON(v any) error {
 
 // BindForm binds form data into the provided struct using reflection.
 // The struct should be a pointer. Field names are matched using JSON tags or struct field names.
-// Supports string, bool, int, and float fields with automatic type conversion.
+// Nested structs, slices, pointers, time.Time, time.Duration and encoding.TextUnmarshaler
+// fields are supported. Nested fields use dot notation ("address.city"), slices of
+// structs bracket notation ("items[0].qty") and other slices repeated keys.
+// For multipart/form-data requests, uploaded files are bound to fields of type
+// *multipart.FileHeader and []*multipart.FileHeader.
 func (rc *ResponseContext) BindForm(v any) error {
-	if err := rc.r.ParseForm(); err != nil {
+	if err := rc.parseForm(); err != nil {
 		return fmt.Errorf("failed to parse form: %w", err)
 	}
 
-	return bindFormToStruct(rc.r.Form, v)
+	if err := bindFormToStruct(rc.r.Form, v); err != nil {
+		return err
+	}
+	if rc.r.MultipartForm != nil {
+		return bindFilesToStruct(rc.r.MultipartForm.File, v)
+	}
+	return nil
 }
 
-// BindValidated binds and validates request data (JSON or form) with comprehensive validation.
+// BindValidated binds and validates request data (form data or any body with a registered
+// decoder, such as JSON) with comprehensive validation.
+// Fields tagged with path, query, header or cookie are filled from the request as well.
+// A parameter tag with the omitempty option, e.g. `query:"page,omitempty"`, marks the
+// field as optional.
+// Malformed input results in an *HTTPError with status 400 and an unsupported Content-Type
+// in one with status 415; failed validation results in an error wrapping ValidationErrors.
+// All can be returned from a HandlerFunc as is.
 func (rc *ResponseContext) BindValidated(v any) error {
-	// Detect language from Accept-Language header
-	lang := detectLanguage(rc.r.Header.Get("Accept-Language"))
-
-	contentType := rc.r.Header.Get("Content-Type")
-
-	// Bind the data
-	if strings.Contains(contentType, "application/json") {
-		if err := json.NewDecoder(rc.r.Body).Decode(v); err != nil {
-			return fmt.Errorf("invalid JSON: %w", err)
+	mediaType := requestMediaType(rc.r)
+
+	// Bind the data. Malformed input is the client's fault, so these errors
+	// carry a 400 status for the error handler.
+	if !isFormMediaType(mediaType) {
+		if err := rc.decodeBody(mediaType, v); err != nil {
+			var he *HTTPError
+			if errors.As(err, &he) {
+				return err
+			}
+			msg := "invalid request body"
+			if _, base, _ := rc.router.codecs.decoder(mediaType); base == "application/json" {
+				msg = "invalid JSON"
+			}
+			return NewHTTPError(http.StatusBadRequest, msg, err)
 		}
 	} else {
-		// Handle form data
-		if err := rc.r.ParseForm(); err != nil {
-			return fmt.Errorf("failed to parse form: %w", err)
+		// Handle form data, including multipart/form-data with files
+		if err := rc.parseForm(); err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				return NewHTTPError(http.StatusRequestEntityTooLarge, "", err)
+			}
+			return NewHTTPError(http.StatusBadRequest, "failed to parse form", err)
 		}
 		if err := bindFormToStruct(rc.r.Form, v); err != nil {
-			return fmt.Errorf("failed to bind form data: %w", err)
+			var be *BindError
+			if errors.As(err, &be) {
+				return NewHTTPError(http.StatusBadRequest,
+					fmt.Sprintf("invalid value %q for field %q", be.Value, be.Field), be.Err)
+			}
+			return NewHTTPError(http.StatusBadRequest, "failed to bind form data", err)
+		}
+		if rc.r.MultipartForm != nil {
+			if err := bindFilesToStruct(rc.r.MultipartForm.File, v); err != nil {
+				return NewHTTPError(http.StatusBadRequest, "failed to bind form data", err)
+			}
 		}
 	}
 
-	// Validate the bound data
-	if err := validateStruct(v, lang); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
+	// Path, query, header and cookie values take precedence over the body.
+	if err := rc.BindParams(v); err != nil {
+		return err
 	}
 
-	return nil
+	return rc.validate(v)
+}
+
+// validate validates v in the language of the request. Violations are returned
+// wrapping ValidationErrors, errors of custom validators as is.
+func (rc *ResponseContext) validate(v any) error {
+	lang := detectLanguage(rc.r.Header.Get("Accept-Language"))
+	err := validateStruct(rc.r.Context(), v, lang)
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return err
 }
 
 // NewRouter creates and returns a new Router instance with default configuration.
 func NewRouter() *Router {
 	r := &Router{
-		routes:      make([]route, 0),
+		routes:      make([]*route, 0),
+		tree:        &node{},
 		subrouters:  make([]*Router, 0),
 		paramsKey:   new(struct{}),
 		middlewares: make([]Middleware, 0),
 		basePath:    "",
+		autoHead:    true,
+		autoOptions: true,
+		codecs:      newCodecRegistry(),
 		chain: func(h http.Handler) http.Handler {
 			return h
 		},
@@ -973,7 +1816,7 @@ func NewRouter() *Router {
 
 // SetNotFoundHandler allows users to supply a custom http.Handler
 // for requests that do not match any route. If not set, the standard
-// http.NotFound handler is used.
+// http.NotFound handler is used. The handler runs inside the router's middleware.
 func (r *Router) SetNotFoundHandler(h http.Handler) {
 	r.notFoundHandler = h
 }
@@ -981,21 +1824,91 @@ func (r *Router) SetNotFoundHandler(h http.Handler) {
 // SetMethodNotAllowedHandler allows users to supply a custom http.Handler
 // for requests that match a route pattern but use an unsupported HTTP method.
 // If not set, a default 405 Method Not Allowed response is returned.
+// The handler runs inside the router's middleware.
 func (r *Router) SetMethodNotAllowedHandler(h http.Handler) {
 	r.methodNotAllowedHandler = h
 }
 
+// SetErrorHandler sets the handler for errors returned by enhanced handlers
+// (HandlerFunc). If not set, DefaultErrorHandler is used. Subrouters created
+// afterwards inherit the handler.
+func (r *Router) SetErrorHandler(h ErrorHandlerFunc) {
+	r.errorHandler = h
+}
+
+// handleError passes err to the router's error handler.
+func (r *Router) handleError(rc *ResponseContext, err error) {
+	if r.errorHandler != nil {
+		r.errorHandler(rc, err)
+		return
+	}
+	DefaultErrorHandler(rc, err)
+}
+
+// SetAutoHead enables or disables serving HEAD requests with the GET route registered
+// for the same pattern when no HEAD route exists. The body written by the GET handler
+// is discarded. Automatic HEAD handling is enabled by default.
+func (r *Router) SetAutoHead(enabled bool) {
+	r.autoHead = enabled
+}
+
+// SetAutoOptions enables or disables answering OPTIONS requests for registered patterns
+// that have no OPTIONS route. The response is a 204 No Content listing the allowed
+// methods in the Allow header. It passes through the router's middleware, so
+// CORSMiddleware can still answer preflight requests. Enabled by default.
+func (r *Router) SetAutoOptions(enabled bool) {
+	r.autoOptions = enabled
+}
+
+// SetProblemDetails enables or disables RFC 9457 problem details for the default
+// 404 Not Found and 405 Method Not Allowed responses. Custom handlers set with
+// SetNotFoundHandler and SetMethodNotAllowedHandler are not affected.
+// Subrouters created afterwards inherit the setting.
+func (r *Router) SetProblemDetails(enabled bool) {
+	r.problemDetails = enabled
+}
+
 // Use registers one or more middleware functions that will be applied globally
-// to every matched route handler. Middleware is applied in the order it is registered.
+// to every matched route handler, mounted handlers and the 404 and 405 responses.
+// It runs after the route is matched; see Pre for middleware that must run before.
+// Middleware is applied in the order it is registered.
 func (r *Router) Use(mws ...Middleware) {
 	r.middlewares = append(r.middlewares, mws...)
 	r.rebuildChain()
 }
 
+// Pre registers pre-routing middleware, which wraps the whole ServeHTTP instead
+// of the matched route. It runs for every request, including those answered with
+// 404 Not Found or 405 Method Not Allowed, before the route is matched, so it may
+// change the request's method or path to influence matching or answer without
+// reaching the router at all. Use it for middleware such as CORSMiddleware,
+// MethodOverrideMiddleware, TrailingSlashRedirectMiddleware and
+// ForceHTTPSMiddleware. Pre-routing middleware cannot see the matched route or
+// URL parameters. Pre panics on a subrouter, since subrouters are reached after
+// matching has started.
+func (r *Router) Pre(mws ...Middleware) {
+	if r.isSubrouter {
+		panic("Pre: pre-routing middleware can only be registered on the root router")
+	}
+	r.pre = append(r.pre, mws...)
+	var h http.Handler = http.HandlerFunc(r.serve)
+	for i := len(r.pre) - 1; i >= 0; i-- {
+		h = r.pre[i](h)
+	}
+	r.preHandler = h
+}
+
 // Handle registers a new route with the given HTTP method, URL pattern, and handler.
 // If the router has a non-empty basePath, it is automatically prepended to the pattern.
 // Optional RouteOptions can be provided for OpenAPI documentation.
 func (r *Router) Handle(method, pattern string, handler http.HandlerFunc, opts ...*RouteOptions) {
+	r.handle(method, pattern, handler, nil, opts)
+}
+
+// handle registers a route whose handler is wrapped in the middleware of its
+// RouteOptions and then in the group middleware, so that router, group and
+// route middleware run in that order.
+func (r *Router) handle(method, pattern string, handler http.Handler, group []Middleware, opts []*RouteOptions) {
 	fullPattern := pattern
 
 	if r.basePath != "" {
@@ -1008,32 +1921,206 @@ func (r *Router) Handle(method, pattern string, handler http.HandlerFunc, opts .
 	}
 
 	var routeOpts *RouteOptions
-	if len(opts) > 0 {
+	var mws []Middleware
+	if len(opts) > 0 && opts[0] != nil {
 		routeOpts = opts[0]
+		mws = routeOpts.Middleware
 	}
-	r.routes = append(r.routes, route{
-		method:   method,
-		handler:  handler,
-		segments: segs,
-		options:  routeOpts,
-	})
+	mws = append(slices.Clip(group), mws...)
+	for i := len(mws) - 1; i >= 0; i-- {
+		handler = mws[i](handler)
+	}
+
+	rt := &route{
+		method:      method,
+		handler:     handler.ServeHTTP,
+		pattern:     "/" + strings.Trim(fullPattern, "/"),
+		segments:    segs,
+		options:     routeOpts,
+		match:       routeMatcher(routeOpts),
+		middlewares: len(mws),
+	}
+	rt.matched = &MatchedRoute{
+		Method:  method,
+		Pattern: rt.pattern,
+		Options: routeOpts,
+	}
+	if routeOpts != nil {
+		rt.matched.Name = routeOpts.Name
+	}
+	if routeOpts != nil && routeOpts.Name != "" {
+		if _, exists := r.names[routeOpts.Name]; exists {
+			panic("duplicate route name: " + routeOpts.Name)
+		}
+		if r.names == nil {
+			r.names = make(map[string]*route)
+		}
+		r.names[routeOpts.Name] = rt
+	}
+	if err := r.tree.insert(rt); err != nil {
+		panic("route conflict: " + err.Error())
+	}
+	r.routes = append(r.routes, rt)
+}
+
+// URL builds the path of the route registered under name, either on this router
+// or one of its subrouters. Parameters are given as key/value pairs, e.g.
+// router.URL("user.show", "id", "42"). Values are checked against the regex of
+// their segment and escaped. Catch-all values may contain slashes, which are
+// kept, and trailing optional parameters may be omitted. Pairs that do not name
+// a path parameter are added to the query string.
+func (r *Router) URL(name string, pairs ...string) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("route %q: odd number of parameter arguments", name)
+	}
+	rt := r.namedRoute(name)
+	if rt == nil {
+		return "", fmt.Errorf("route %q: no route registered with this name", name)
+	}
+
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+
+	parts := make([]string, 0, len(rt.segments))
+	for i, seg := range rt.segments {
+		switch {
+		case seg.isLiteral():
+			parts = append(parts, seg.literal)
+		case seg.catchAll:
+			for p := range strings.SplitSeq(strings.Trim(values[seg.paramName], "/"), "/") {
+				if p != "" {
+					parts = append(parts, url.PathEscape(p))
+				}
+			}
+			delete(values, seg.paramName)
+		case seg.optional && values[seg.paramName] == "":
+			for _, later := range rt.segments[i+1:] {
+				if values[later.paramName] != "" {
+					return "", fmt.Errorf("route %q: parameter %q requires a value for optional parameter %q",
+						name, later.paramName, seg.paramName)
+				}
+			}
+			delete(values, seg.paramName)
+		case seg.isParam:
+			value, err := urlParam(name, seg, values)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, url.PathEscape(value))
+		default:
+			var b strings.Builder
+			for _, p := range seg.pieces {
+				if !p.isParam {
+					b.WriteString(url.PathEscape(p.literal))
+					continue
+				}
+				value, err := urlParam(name, p, values)
+				if err != nil {
+					return "", err
+				}
+				b.WriteString(url.PathEscape(value))
+			}
+			parts = append(parts, b.String())
+		}
+	}
+
+	u := "/" + strings.Join(parts, "/")
+	if len(values) > 0 {
+		query := url.Values{}
+		for k, v := range values {
+			query.Set(k, v)
+		}
+		u += "?" + query.Encode()
+	}
+	return u, nil
+}
+
+// urlParam takes the value for the parameter segment seg from values and checks
+// it against the segment's regex.
+func urlParam(name string, seg segment, values map[string]string) (string, error) {
+	value, ok := values[seg.paramName]
+	if !ok || value == "" {
+		return "", fmt.Errorf("route %q: missing value for parameter %q", name, seg.paramName)
+	}
+	if seg.regex != nil && !seg.regex.MatchString(value) {
+		return "", fmt.Errorf("route %q: value %q for parameter %q does not match %s",
+			name, value, seg.paramName, seg.regex)
+	}
+	delete(values, seg.paramName)
+	return value, nil
+}
+
+// MustURL is like URL but panics if the URL cannot be built. It is intended for
+// building links in HTML where the route names and parameters are static.
+func (r *Router) MustURL(name string, pairs ...string) string {
+	u, err := r.URL(name, pairs...)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// namedRoute looks up a route by name on the router and its subrouters.
+func (r *Router) namedRoute(name string) *route {
+	if rt, ok := r.names[name]; ok {
+		return rt
+	}
+	for _, sr := range r.subrouters {
+		if rt := sr.namedRoute(name); rt != nil {
+			return rt
+		}
+	}
+	return nil
 }
 
 // HandleFunc registers a new enhanced route that receives a ResponseContext instead
 // of separate ResponseWriter and Request parameters. This enables cleaner error handling
 // and response management with automatic data binding capabilities.
+// Errors returned by the handler are passed to the router's error handler.
 func (r *Router) HandleFunc(method, pattern string, handler HandlerFunc, opts ...*RouteOptions) {
 	r.Handle(method, pattern, func(w http.ResponseWriter, req *http.Request) {
-		rc := &ResponseContext{
-			w:      w,
-			r:      req,
-			router: r,
-		}
+		r.serveHandlerFunc(w, req, handler)
+	}, opts...)
+}
+
+// serveHandlerFunc runs an enhanced handler and passes its error to the error
+// handler. If the handler started an SSE stream or upgraded to a WebSocket, the
+// stream or connection is closed and the error is logged instead, since the
+// response has already begun.
+func (r *Router) serveHandlerFunc(w http.ResponseWriter, req *http.Request, handler HandlerFunc) {
+	rc := &ResponseContext{
+		w:      w,
+		r:      req,
+		router: r,
+	}
 
-		if err := handler(rc); err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	err := handler(rc)
+	if rc.sse != nil {
+		rc.sse.Close()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			slog.Error("SSE handler error",
+				"method", req.Method,
+				"path", req.URL.Path,
+				"error", err,
+			)
 		}
-	}, opts...)
+		return
+	}
+	if rc.ws != nil {
+		if rc.ws.finish(err) {
+			slog.Error("WebSocket handler error",
+				"method", req.Method,
+				"path", req.URL.Path,
+				"error", err,
+			)
+		}
+		return
+	}
+	if err != nil {
+		r.handleError(rc, err)
+	}
 }
 
 // Get registers a new route for HTTP GET requests using the standard handler signature.
@@ -1091,13 +2178,23 @@ func (r *Router) DeleteFunc(pattern string, handler HandlerFunc, options ...*Rou
 // and error handlers. Its routes will automatically receive the combined prefix.
 func (r *Router) Subrouter(prefix string) *Router {
 	newRouter := &Router{
-		routes:                  make([]route, 0),
+		routes:                  make([]*route, 0),
+		tree:                    &node{},
 		subrouters:              make([]*Router, 0),
 		paramsKey:               r.paramsKey,
 		middlewares:             slices.Clone(r.middlewares),
 		basePath:                joinPaths(r.basePath, prefix),
 		notFoundHandler:         r.notFoundHandler,
 		methodNotAllowedHandler: r.methodNotAllowedHandler,
+		errorHandler:            r.errorHandler,
+		autoHead:                r.autoHead,
+		autoOptions:             r.autoOptions,
+		problemDetails:          r.problemDetails,
+		multipartMemory:         r.multipartMemory,
+		codecs:                  r.codecs,
+		webSocket:               r.webSocket,
+		version:                 r.version,
+		isSubrouter:             true,
 	}
 	newRouter.rebuildChain()
 	r.subrouters = append(r.subrouters, newRouter)
@@ -1108,60 +2205,225 @@ func (r *Router) Subrouter(prefix string) *Router {
 // convenience wrapper that prefixes routes and can add its own middleware without
 // creating a separate router instance.
 func (r *Router) Group(prefix string, mws ...Middleware) *Group {
-	fullPrefix := joinPaths(r.basePath, prefix)
+	// The router's basePath is prepended when the route is registered, so the
+	// group only keeps its own prefix.
 	return &Group{
-		prefix:      fullPrefix,
+		prefix:      joinPaths("", prefix),
 		router:      r,
 		middlewares: mws,
 	}
 }
 
+// Mount attaches an arbitrary http.Handler, such as net/http/pprof or a router
+// from another framework, at the given prefix. The handler receives every request
+// whose path is the prefix or lies below it, with the path left unchanged; wrap the
+// handler in http.StripPrefix if it expects paths relative to the prefix.
+// Mounted handlers run through the router's middleware and are only consulted
+// when no registered route matches the request path.
+func (r *Router) Mount(prefix string, h http.Handler) {
+	r.mounts = append(r.mounts, mount{
+		prefix:  joinPaths(r.basePath, prefix),
+		handler: h,
+	})
+}
+
 // ServeHTTP implements the http.Handler interface.
-// It first checks subrouters based on their base path, then its own routes.
+// It first checks subrouters whose base path is a segment prefix of the request path,
+// then looks the path up in its routing tree and finally tries mounted handlers.
+// Static segments take precedence over regex parameters, which take precedence over
+// plain parameters and finally catch-alls. A subrouter without a matching route falls back to the parent.
 // If a URL pattern matches but the HTTP method does not, a 405 Method Not Allowed is returned.
 // If no routes match, a 404 Not Found is returned.
+//
+// GET routes also answer HEAD requests and OPTIONS requests are answered with the
+// allowed methods, unless disabled with SetAutoHead and SetAutoOptions. Both 405
+// and automatic OPTIONS responses carry an Allow header.
+//
+// Pre-routing middleware registered with Pre wraps all of this. The 404 and 405
+// responses, custom or default, pass through the router's middleware like routes do.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	requestPath := req.URL.Path
+	if r.preHandler != nil {
+		r.preHandler.ServeHTTP(w, req)
+		return
+	}
+	r.serve(w, req)
+}
 
-	// Check mounted subrouters first.
-	for _, sr := range r.subrouters {
-		if sr.basePath != "" && strings.HasPrefix(requestPath, "/"+strings.Trim(sr.basePath, "/")) {
-			sr.ServeHTTP(w, req)
+// serve matches req against the routes and serves it.
+func (r *Router) serve(w http.ResponseWriter, req *http.Request) {
+	parts := splitPath(req.URL.Path)
+	served, owner, methodMismatch := r.dispatch(w, req, parts)
+	if served {
+		return
+	}
+	if methodMismatch {
+		w.Header().Set("Allow", strings.Join(r.allowedMethods(req, parts), ", "))
+		if req.Method == http.MethodOptions && owner.autoOptions {
+			owner.chain(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			})).ServeHTTP(w, req)
 			return
 		}
+		owner.chain(http.HandlerFunc(owner.methodNotAllowed)).ServeHTTP(w, req)
+		return
 	}
+	owner.chain(http.HandlerFunc(owner.notFound)).ServeHTTP(w, req)
+}
 
-	var patternMatched bool
-	for _, rt := range r.routes {
-		if ok, params := matchSegments(req.URL.Path, rt.segments); ok {
-			patternMatched = true
-			if rt.method == req.Method {
-				if len(params) > 0 {
-					ctx := context.WithValue(req.Context(), r.paramsKey, params)
-					req = req.WithContext(ctx)
-				}
-				finalHandler := r.chain(rt.handler)
-				finalHandler.ServeHTTP(w, req)
-				return
+// allowedMethods returns the sorted methods that can be served for the path by the
+// router and its matching subrouters, including automatic HEAD and OPTIONS.
+func (r *Router) allowedMethods(req *http.Request, parts []string) []string {
+	var methods []string
+	add := func(m string) {
+		if !slices.Contains(methods, m) {
+			methods = append(methods, m)
+		}
+	}
+
+	var walk func(r *Router, parts []string)
+	walk = func(r *Router, parts []string) {
+		if r.versions != nil {
+			r.versions.walk(req, parts, walk)
+			return
+		}
+		for _, sr := range r.subrouters {
+			if _, ok := sr.match.match(req); ok && hasSegmentPrefix(parts, sr.basePath) {
+				walk(sr, parts)
 			}
 		}
+		own := r.tree.methods(parts, req, nil)
+		for _, m := range own {
+			add(m)
+		}
+		if r.autoHead && slices.Contains(own, http.MethodGet) {
+			add(http.MethodHead)
+		}
+		if r.autoOptions && len(own) > 0 {
+			add(http.MethodOptions)
+		}
 	}
+	walk(r, parts)
+
+	slices.Sort(methods)
+	return methods
+}
 
-	if patternMatched {
-		if r.methodNotAllowedHandler != nil {
-			r.methodNotAllowedHandler.ServeHTTP(w, req)
-		} else {
-			http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
-				http.StatusMethodNotAllowed)
+// dispatch tries to serve req from the router's subrouters, routes and mounts.
+// If no handler ran, it returns the router responsible for the error response
+// and whether a route pattern matched the path with a different method.
+// The responsible router is the one whose route matched for 405 responses, and
+// the router with the longest matching base path for 404 responses.
+func (r *Router) dispatch(w http.ResponseWriter, req *http.Request, parts []string) (bool, *Router, bool) {
+	if r.versions != nil {
+		return r.versions.dispatch(w, req, parts)
+	}
+	var notFound, mismatch *Router
+
+	for _, sr := range r.subrouters {
+		if !hasSegmentPrefix(parts, sr.basePath) {
+			continue
 		}
-		return
+		hostParams, ok := sr.match.match(req)
+		if !ok {
+			continue
+		}
+		served, owner, methodMismatch := sr.dispatch(w, sr.withParams(req, hostParams), parts)
+		if served {
+			return true, nil, false
+		}
+		if methodMismatch {
+			if mismatch == nil {
+				mismatch = owner
+			}
+		} else if notFound == nil || len(owner.basePath) > len(notFound.basePath) {
+			notFound = owner
+		}
+	}
+
+	rt, patternMatched := r.tree.lookup(req.Method, parts, req)
+	if rt == nil && req.Method == http.MethodHead && r.autoHead {
+		if rt, _ = r.tree.lookup(http.MethodGet, parts, req); rt != nil {
+			w = headResponseWriter{w}
+		}
+	}
+	if rt != nil {
+		hostParams, _ := rt.match.match(req)
+		req = r.withParams(req, hostParams)
+		req = r.withParams(req, rt.params(parts))
+		req = req.WithContext(context.WithValue(req.Context(), matchedRouteKey, rt.matched))
+		if observe, ok := req.Context().Value(routeObserverKey).(func(*MatchedRoute)); ok {
+			observe(rt.matched)
+		}
+		finalHandler := r.chain(rt.handler)
+		finalHandler.ServeHTTP(w, req)
+		return true, nil, false
 	}
 
+	if patternMatched && mismatch == nil {
+		mismatch = r
+	}
+	if mismatch != nil {
+		return false, mismatch, true
+	}
+
+	for _, m := range r.mounts {
+		if hasSegmentPrefix(parts, m.prefix) {
+			r.chain(m.handler).ServeHTTP(w, req)
+			return true, nil, false
+		}
+	}
+
+	if notFound == nil {
+		notFound = r
+	}
+	return false, notFound, false
+}
+
+// headResponseWriter discards the body written by a GET handler that serves a
+// HEAD request, while keeping the headers and status code intact.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+// Write discards b and reports it as fully written.
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// Unwrap returns the underlying http.ResponseWriter for http.ResponseController.
+func (w headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// notFound responds with the router's custom 404 handler, or http.NotFound if none is set.
+func (r *Router) notFound(w http.ResponseWriter, req *http.Request) {
 	if r.notFoundHandler != nil {
 		r.notFoundHandler.ServeHTTP(w, req)
-	} else {
-		http.NotFound(w, req)
+		return
+	}
+	if r.problemDetails {
+		_ = writeProblem(w, req, ProblemDetails{Status: http.StatusNotFound})
+		return
 	}
+	http.NotFound(w, req)
+}
+
+// methodNotAllowed responds with the router's custom 405 handler, or a plain
+// 405 Method Not Allowed response if none is set.
+func (r *Router) methodNotAllowed(w http.ResponseWriter, req *http.Request) {
+	if r.methodNotAllowedHandler != nil {
+		r.methodNotAllowedHandler.ServeHTTP(w, req)
+		return
+	}
+	if r.problemDetails {
+		_ = writeProblem(w, req, ProblemDetails{
+			Status: http.StatusMethodNotAllowed,
+			Detail: fmt.Sprintf("Method %s is not allowed for this resource", req.Method),
+		})
+		return
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed),
+		http.StatusMethodNotAllowed)
 }
 
 // URLParam retrieves the URL parameter for the given key from the request context.
@@ -1184,53 +2446,16 @@ func (g *Group) Use(mws ...Middleware) {
 // Handle registers a new route within the group, applying the group's prefix and middleware.
 // The route is ultimately registered with the parent router after transformations.
 func (g *Group) Handle(method, pattern string, handler http.HandlerFunc, opts ...*RouteOptions) {
-	fullPattern := joinPaths(g.prefix, pattern)
-
-	// wrap the handler with the group's middleware
-	h := http.Handler(handler)
-	for i := len(g.middlewares) - 1; i >= 0; i-- {
-		h = g.middlewares[i](h)
-	}
-
-	// forward to the underlying router.Handle, including opts
-	g.router.Handle(method, fullPattern,
-		func(w http.ResponseWriter, r *http.Request) {
-			h.ServeHTTP(w, r)
-		},
-		opts...,
-	)
+	g.router.handle(method, joinPaths(g.prefix, pattern), handler, g.middlewares, opts)
 }
 
 // HandleFunc registers a new enhanced route within the group, applying the group's
 // prefix and middleware. Uses the enhanced handler signature for better error handling.
 func (g *Group) HandleFunc(method, pattern string, handler HandlerFunc, opts ...*RouteOptions) {
-	fullPattern := joinPaths(g.prefix, pattern)
-
-	// Create enhanced handler
 	enhancedHandler := func(w http.ResponseWriter, req *http.Request) {
-		rc := &ResponseContext{
-			w:      w,
-			r:      req,
-			router: g.router,
-		}
-
-		if err := handler(rc); err != nil {
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		}
+		g.router.serveHandlerFunc(w, req, handler)
 	}
-
-	// wrap with group middleware
-	h := http.Handler(http.HandlerFunc(enhancedHandler))
-	for i := len(g.middlewares) - 1; i >= 0; i-- {
-		h = g.middlewares[i](h)
-	}
-
-	g.router.Handle(method, fullPattern,
-		func(w http.ResponseWriter, r *http.Request) {
-			h.ServeHTTP(w, r)
-		},
-		opts...,
-	)
+	g.router.handle(method, joinPaths(g.prefix, pattern), http.HandlerFunc(enhancedHandler), g.middlewares, opts)
 }
 
 // Get registers a new route for HTTP GET requests within the group.
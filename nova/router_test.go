@@ -1,10 +1,17 @@
 package nova
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
 	"regexp"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 // TestCompilePattern tests that compilePattern splits a pattern string into
@@ -229,3 +236,850 @@ func TestGroupPrefixAndMiddleware(t *testing.T) {
 			rr.Header().Get("X-G"), rr.Body.String())
 	}
 }
+
+// TestRoutePrecedence tests that static segments win over regex parameters,
+// which win over plain parameters, independent of registration order.
+func TestRoutePrecedence(t *testing.T) {
+	r := NewRouter()
+	r.Get("/users/{name}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("param:" + r.URLParam(req, "name")))
+	})
+	r.Get("/users/{id:[0-9]+}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("regex:" + r.URLParam(req, "id")))
+	})
+	r.Get("/users/me", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("static"))
+	})
+
+	cases := map[string]string{
+		"/users/me":    "static",
+		"/users/42":    "regex:42",
+		"/users/alice": "param:alice",
+	}
+	for path, want := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Body.String() != want {
+			t.Errorf("GET %s = %q; want %q", path, rr.Body.String(), want)
+		}
+	}
+}
+
+// TestRouteBacktracking tests that a static match without a handler for the
+// request method falls back to a parameter route that does handle it.
+func TestRouteBacktracking(t *testing.T) {
+	r := NewRouter()
+	r.Post("/items/new", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("create"))
+	})
+	r.Get("/items/{id}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("show:" + r.URLParam(req, "id")))
+	})
+	r.Get("/items/{id}/edit", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("edit:" + r.URLParam(req, "id")))
+	})
+
+	cases := []struct{ method, path, want string }{
+		{"POST", "/items/new", "create"},
+		{"GET", "/items/new", "show:new"},
+		{"GET", "/items/new/edit", "edit:new"},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(c.method, c.path, nil))
+		if rr.Body.String() != c.want {
+			t.Errorf("%s %s = %q; want %q", c.method, c.path, rr.Body.String(), c.want)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("DELETE", "/items/new", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /items/new = %d; want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+// TestCatchAllAndOptionalParams tests trailing catch-all and optional
+// parameters, and their precedence below the other parameter kinds. A
+// catch-all also matches zero segments unless a route ends at its prefix.
+func TestCatchAllAndOptionalParams(t *testing.T) {
+	r := NewRouter()
+	r.Get("/files/{path...}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("files:" + r.URLParam(req, "path")))
+	})
+	r.Get("/files/{name}/meta", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("meta:" + r.URLParam(req, "name")))
+	})
+	r.Get("/posts/{page?:[0-9]+}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("posts:" + r.URLParam(req, "page")))
+	})
+	r.Get("/archive/{year?}/{month?}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("archive:" + r.URLParam(req, "year") + "-" + r.URLParam(req, "month")))
+	})
+	r.Post("/files/upload", func(w http.ResponseWriter, req *http.Request) {})
+	r.Get("/docs/{page...}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("docs:" + r.URLParam(req, "page")))
+	})
+	r.Get("/docs", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("docs index"))
+	})
+
+	cases := []struct {
+		method, path string
+		wantStatus   int
+		wantBody     string
+	}{
+		{"GET", "/files/a/b/c.txt", http.StatusOK, "files:a/b/c.txt"},
+		{"GET", "/files/readme", http.StatusOK, "files:readme"},
+		{"GET", "/files", http.StatusOK, "files:"},
+		{"GET", "/files/", http.StatusOK, "files:"},
+		{"GET", "/docs", http.StatusOK, "docs index"},
+		{"GET", "/docs/intro", http.StatusOK, "docs:intro"},
+		{"GET", "/files/report/meta", http.StatusOK, "meta:report"},
+		{"GET", "/files/report/meta/x", http.StatusOK, "files:report/meta/x"},
+		{"GET", "/files/upload", http.StatusOK, "files:upload"},
+		{"GET", "/posts", http.StatusOK, "posts:"},
+		{"GET", "/posts/3", http.StatusOK, "posts:3"},
+		{"GET", "/posts/three", http.StatusNotFound, ""},
+		{"GET", "/archive", http.StatusOK, "archive:-"},
+		{"GET", "/archive/2024", http.StatusOK, "archive:2024-"},
+		{"GET", "/archive/2024/05", http.StatusOK, "archive:2024-05"},
+		{"GET", "/archive/2024/05/01", http.StatusNotFound, ""},
+		{"DELETE", "/files/a/b", http.StatusMethodNotAllowed, ""},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(c.method, c.path, nil))
+		if rr.Code != c.wantStatus {
+			t.Errorf("%s %s status = %d; want %d", c.method, c.path, rr.Code, c.wantStatus)
+		}
+		if c.wantBody != "" && rr.Body.String() != c.wantBody {
+			t.Errorf("%s %s = %q; want %q", c.method, c.path, rr.Body.String(), c.wantBody)
+		}
+	}
+}
+
+// TestEmbeddedParams tests parameters embedded in literal text within a segment.
+func TestEmbeddedParams(t *testing.T) {
+	r := NewRouter()
+	r.Get("/files/{name}.{ext}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(r.URLParam(req, "name") + "|" + r.URLParam(req, "ext")))
+	})
+	r.Get("/api/v{version:[0-9]{1,2}}/status", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("v" + r.URLParam(req, "version")))
+	})
+	r.Get("/files/{name}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("plain:" + r.URLParam(req, "name")))
+	})
+
+	cases := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/files/report.pdf", http.StatusOK, "report|pdf"},
+		{"/files/archive.tar.gz", http.StatusOK, "archive.tar|gz"},
+		{"/files/README", http.StatusOK, "plain:README"},
+		{"/files/.env", http.StatusOK, "plain:.env"},
+		{"/api/v2/status", http.StatusOK, "v2"},
+		{"/api/v123/status", http.StatusNotFound, ""},
+		{"/api/vx/status", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", c.path, nil))
+		if rr.Code != c.wantStatus {
+			t.Errorf("GET %s status = %d; want %d", c.path, rr.Code, c.wantStatus)
+		}
+		if c.wantBody != "" && rr.Body.String() != c.wantBody {
+			t.Errorf("GET %s = %q; want %q", c.path, rr.Body.String(), c.wantBody)
+		}
+	}
+}
+
+// TestRouteConflicts tests that patterns matching exactly the same requests
+// are rejected at registration, while distinguishable patterns are accepted.
+func TestRouteConflicts(t *testing.T) {
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	register := func(patterns ...string) (msg string) {
+		defer func() {
+			if v := recover(); v != nil {
+				msg = fmt.Sprint(v)
+			}
+		}()
+		r := NewRouter()
+		for _, p := range patterns {
+			r.Get(p, h)
+		}
+		return ""
+	}
+
+	conflicts := [][]string{
+		{"/users/{id}", "/users/{name}"},
+		{"/users/{id:[0-9]+}", "/users/{num:[0-9]+}"},
+		{"/files/{path...}", "/files/{rest...}"},
+		{"/posts", "/posts/{page?}"},
+		{"/a/{b?}/{c?}", "/a/{x}"},
+		{"/f/{name}.{ext}", "/f/{base}.{suffix}"},
+	}
+	for _, c := range conflicts {
+		msg := register(c...)
+		if !strings.Contains(msg, "route conflict: GET") {
+			t.Errorf("registering %v: panic = %q; want route conflict", c, msg)
+		}
+	}
+
+	r := NewRouter()
+	r.Get("/users/{id}", h)
+	r.Post("/users/{id}", h)
+	r.Get("/users/{id:[0-9]+}", h)
+	r.Get("/users/{id}", h, &RouteOptions{Host: "admin.example.com"})
+	r.Get("/files/{path...}", h)
+	r.Get("/files/{name}", h)
+
+	invalid := []string{
+		"/files/{path...}/meta",
+		"/files/{path...:.*}",
+		"/posts/{page?}/comments",
+		"/files/{name}{ext}",
+		"/users/{id}/{id}",
+		"/users/{}",
+		"/users/{id",
+	}
+	for _, p := range invalid {
+		if msg := register(p); !strings.Contains(msg, "invalid route pattern") {
+			t.Errorf("registering %q: panic = %q; want invalid route pattern", p, msg)
+		}
+	}
+}
+
+// TestStaticNested tests that Static serves files below nested directories.
+func TestStaticNested(t *testing.T) {
+	r := NewRouter()
+	r.Static("/assets", fstest.MapFS{
+		"app.js":         {Data: []byte("app")},
+		"css/site/a.css": {Data: []byte("css")},
+	})
+
+	for path, want := range map[string]string{
+		"/assets/app.js":         "app",
+		"/assets/css/site/a.css": "css",
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != want {
+			t.Errorf("GET %s = %d %q; want 200 %q", path, rr.Code, rr.Body.String(), want)
+		}
+	}
+}
+
+// TestPreRouting tests that pre-routing middleware runs before matching, also
+// for requests that match no route, and that 404 and 405 responses pass through
+// the router middleware.
+func TestPreRouting(t *testing.T) {
+	var pre, post []string
+	r := NewRouter()
+	r.Pre(MethodOverrideMiddleware(nil), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if GetMatchedRoute(req.Context()) != nil {
+				t.Error("pre-routing middleware saw a matched route")
+			}
+			pre = append(pre, req.Method+" "+req.URL.Path)
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Pre(CORSMiddleware(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}))
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			post = append(post, req.Method+" "+req.URL.Path)
+			w.Header().Set("X-Router-Middleware", "1")
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Delete("/items/{id}", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("deleted " + r.URLParam(req, "id")))
+	})
+	r.SetNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "custom 404", http.StatusNotFound)
+	}))
+
+	req := httptest.NewRequest("POST", "/items/7", nil)
+	req.Header.Set("X-HTTP-Method-Override", "DELETE")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Body.String() != "deleted 7" {
+		t.Errorf("overridden POST = %d %q; want the DELETE route", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("OPTIONS", "/unknown", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	if rr.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("preflight for unknown path: headers = %v", rr.Header())
+	}
+
+	pre, post = nil, nil
+	for _, c := range []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "/missing", http.StatusNotFound},
+		{"GET", "/items/7", http.StatusMethodNotAllowed},
+	} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(c.method, c.path, nil))
+		if rr.Code != c.status || rr.Header().Get("X-Router-Middleware") != "1" {
+			t.Errorf("%s %s = %d, headers %v; want %d through the router middleware",
+				c.method, c.path, rr.Code, rr.Header(), c.status)
+		}
+	}
+	if len(pre) != 2 || len(post) != 2 {
+		t.Errorf("pre = %v, post = %v; want both stages to see both requests", pre, post)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Pre on a subrouter did not panic")
+		}
+	}()
+	r.Subrouter("/api").Pre(MethodOverrideMiddleware(nil))
+}
+
+// benchmarkRouter registers a few hundred routes spread over several
+// resources, similar to a medium sized API.
+func benchmarkRouter() *Router {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	for i := range 60 {
+		base := fmt.Sprintf("/api/v1/resource%d", i)
+		r.Get(base, h)
+		r.Post(base, h)
+		r.Get(base+"/{id:[0-9]+}", h)
+		r.Put(base+"/{id:[0-9]+}", h)
+		r.Get(base+"/{id}/children/{child}", h)
+	}
+	return r
+}
+
+// BenchmarkRouterServeHTTP measures routing a request to the last registered
+// resource through the routing tree.
+func BenchmarkRouterServeHTTP(b *testing.B) {
+	r := benchmarkRouter()
+	req := httptest.NewRequest("GET", "/api/v1/resource59/42/children/7", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		r.ServeHTTP(w, req)
+	}
+}
+
+// BenchmarkLinearMatch measures the previous strategy of trying every route
+// with matchSegments, for comparison with BenchmarkRouterServeHTTP.
+func BenchmarkLinearMatch(b *testing.B) {
+	r := benchmarkRouter()
+	path := "/api/v1/resource59/42/children/7"
+
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, rt := range r.routes {
+			if ok, _ := matchSegments(path, rt.segments); ok && rt.method == "GET" {
+				break
+			}
+		}
+	}
+}
+
+// TestSubrouterSegmentBoundary tests that a subrouter only receives requests
+// whose path starts with its base path on a segment boundary, and that the
+// parent falls back to its own routes when the subrouter has no match.
+func TestSubrouterSegmentBoundary(t *testing.T) {
+	r := NewRouter()
+	sr := r.Subrouter("/api")
+	sr.Get("/ping", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("api"))
+	})
+	r.Get("/apiv2/ping", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("apiv2"))
+	})
+	r.Get("/api-docs", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("docs"))
+	})
+	r.Get("/api/status", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("status"))
+	})
+
+	cases := map[string]string{
+		"/api/ping":   "api",
+		"/apiv2/ping": "apiv2",
+		"/api-docs":   "docs",
+		"/api/status": "status",
+	}
+	for path, want := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != want {
+			t.Errorf("GET %s = %d,%q; want 200,%q", path, rr.Code, rr.Body.String(), want)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/api/missing", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET /api/missing = %d; want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+// TestSubrouterErrorHandlers tests that 404 and 405 responses below a
+// subrouter's base path use the subrouter's own handlers.
+func TestSubrouterErrorHandlers(t *testing.T) {
+	r := NewRouter()
+	sr := r.Subrouter("/admin")
+	sr.Get("/users", func(w http.ResponseWriter, req *http.Request) {})
+	sr.SetNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	sr.SetMethodNotAllowedHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(499)
+	}))
+
+	cases := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/admin/nope", http.StatusTeapot},
+		{"POST", "/admin/users", 499},
+		{"GET", "/nope", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(c.method, c.path, nil))
+		if rr.Code != c.want {
+			t.Errorf("%s %s = %d; want %d", c.method, c.path, rr.Code, c.want)
+		}
+	}
+}
+
+// TestMount tests that a mounted handler receives requests at and below its
+// prefix, runs through the router middleware and does not shadow routes.
+func TestMount(t *testing.T) {
+	r := NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-MW", "1")
+			next.ServeHTTP(w, req)
+		})
+	})
+	r.Mount("/debug", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("mounted:" + req.URL.Path))
+	}))
+	r.Get("/debug/version", func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("route"))
+	})
+
+	cases := map[string]string{
+		"/debug":            "mounted:/debug",
+		"/debug/pprof/heap": "mounted:/debug/pprof/heap",
+		"/debug/version":    "route",
+	}
+	for path, want := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Body.String() != want || rr.Header().Get("X-MW") != "1" {
+			t.Errorf("GET %s = %q (X-MW=%q); want %q", path,
+				rr.Body.String(), rr.Header().Get("X-MW"), want)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/debugger", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("GET /debugger = %d; want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+// TestAutoHead tests that GET routes answer HEAD requests without a body,
+// that explicit HEAD routes win, and that the behavior can be disabled.
+func TestAutoHead(t *testing.T) {
+	r := NewRouter()
+	r.Get("/page", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Page", "1")
+		w.Write([]byte("body"))
+	})
+	r.Get("/explicit", func(w http.ResponseWriter, req *http.Request) {})
+	r.Handle(http.MethodHead, "/explicit", func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("HEAD", "/page", nil))
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 || rr.Header().Get("X-Page") != "1" {
+		t.Errorf("HEAD /page = %d,%q,X-Page=%q; want 200 with empty body",
+			rr.Code, rr.Body.String(), rr.Header().Get("X-Page"))
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("HEAD", "/explicit", nil))
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("HEAD /explicit = %d; want %d", rr.Code, http.StatusTeapot)
+	}
+
+	r.SetAutoHead(false)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("HEAD", "/page", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("HEAD /page with auto HEAD disabled = %d; want %d",
+			rr.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+// TestAutoOptionsAndAllow tests that OPTIONS requests and 405 responses list
+// the allowed methods in the Allow header.
+func TestAutoOptionsAndAllow(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	r.Get("/items/{id}", h)
+	r.Delete("/items/{id}", h)
+	r.Post("/items/new", h)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("OPTIONS", "/items/new", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("OPTIONS /items/new = %d; want %d", rr.Code, http.StatusNoContent)
+	}
+	if got, want := rr.Header().Get("Allow"), "DELETE, GET, HEAD, OPTIONS, POST"; got != want {
+		t.Errorf("OPTIONS Allow = %q; want %q", got, want)
+	}
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("PUT", "/items/1", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT /items/1 = %d; want %d", rr.Code, http.StatusMethodNotAllowed)
+	}
+	if got, want := rr.Header().Get("Allow"), "DELETE, GET, HEAD, OPTIONS"; got != want {
+		t.Errorf("405 Allow = %q; want %q", got, want)
+	}
+
+	r.SetAutoOptions(false)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("OPTIONS", "/items/1", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("OPTIONS with auto OPTIONS disabled = %d; want %d",
+			rr.Code, http.StatusMethodNotAllowed)
+	}
+	if got, want := rr.Header().Get("Allow"), "DELETE, GET, HEAD"; got != want {
+		t.Errorf("405 Allow = %q; want %q", got, want)
+	}
+}
+
+// TestAutoOptionsCORSPreflight tests that automatic OPTIONS responses run
+// through the router middleware so CORSMiddleware can answer preflights.
+func TestAutoOptionsCORSPreflight(t *testing.T) {
+	r := NewRouter()
+	r.Use(CORSMiddleware(CORSConfig{AllowedOrigins: []string{"https://example.com"}}))
+	r.Post("/api/data", func(w http.ResponseWriter, req *http.Request) {})
+
+	req := httptest.NewRequest("OPTIONS", "/api/data", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("preflight = %d; want %d", rr.Code, http.StatusNoContent)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q; want https://example.com", got)
+	}
+	if got := rr.Header().Get("Allow"); got != "OPTIONS, POST" {
+		t.Errorf("Allow = %q; want %q", got, "OPTIONS, POST")
+	}
+}
+
+// TestURL tests reverse URL building for named routes, including subrouter and
+// group prefixes, regex checks, escaping and query strings.
+func TestURL(t *testing.T) {
+	r := NewRouter()
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	r.Get("/", h, &RouteOptions{Name: "home"})
+	r.Get("/users/{id:[0-9]+}", h, &RouteOptions{Name: "user.show"})
+	api := r.Subrouter("/api")
+	api.Get("/files/{name}", h, &RouteOptions{Name: "api.file"})
+	g := api.Group("/v2")
+	g.Get("/status", h, &RouteOptions{Name: "api.v2.status"})
+	r.Get("/docs/{path...}", h, &RouteOptions{Name: "docs"})
+	r.Get("/archive/{year?}/{month?}", h, &RouteOptions{Name: "archive"})
+	r.Get("/download/{name}.{ext:[a-z]+}", h, &RouteOptions{Name: "download"})
+
+	cases := []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{"home", nil, "/"},
+		{"user.show", []string{"id", "42"}, "/users/42"},
+		{"user.show", []string{"id", "42", "tab", "posts", "page", "2"}, "/users/42?page=2&tab=posts"},
+		{"api.file", []string{"name", "a b"}, "/api/files/a%20b"},
+		{"api.v2.status", nil, "/api/v2/status"},
+		{"docs", []string{"path", "guide/intro to go"}, "/docs/guide/intro%20to%20go"},
+		{"docs", nil, "/docs"},
+		{"archive", []string{"year", "2024"}, "/archive/2024"},
+		{"archive", []string{"year", "2024", "month", "05"}, "/archive/2024/05"},
+		{"download", []string{"name", "report", "ext", "pdf"}, "/download/report.pdf"},
+	}
+	for _, c := range cases {
+		got, err := r.URL(c.name, c.pairs...)
+		if err != nil {
+			t.Errorf("URL(%q, %v) error: %v", c.name, c.pairs, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("URL(%q, %v) = %q; want %q", c.name, c.pairs, got, c.want)
+		}
+	}
+
+	errCases := []struct {
+		name     string
+		pairs    []string
+		contains string
+	}{
+		{"missing", nil, "no route registered"},
+		{"user.show", nil, `missing value for parameter "id"`},
+		{"user.show", []string{"id", "abc"}, `does not match`},
+		{"user.show", []string{"id"}, "odd number"},
+		{"archive", []string{"month", "05"}, `requires a value for optional parameter "year"`},
+		{"download", []string{"name", "report", "ext", "PDF"}, `does not match`},
+	}
+	for _, c := range errCases {
+		_, err := r.URL(c.name, c.pairs...)
+		if err == nil || !strings.Contains(err.Error(), c.contains) {
+			t.Errorf("URL(%q, %v) error = %v; want error containing %q",
+				c.name, c.pairs, err, c.contains)
+		}
+	}
+}
+
+// TestBindValidatedParams tests that BindValidated fills path, query, header
+// and cookie fields alongside the JSON body and validates them.
+func TestBindValidatedParams(t *testing.T) {
+	type updateOrder struct {
+		ID      int      `path:"id"`
+		Page    int      `query:"page,omitempty" min:"1"`
+		Tags    []string `query:"tag,omitempty"`
+		Tenant  string   `header:"X-Tenant"`
+		Session string   `cookie:"session,omitempty"`
+		Note    string   `json:"note"`
+	}
+
+	var got updateOrder
+	r := NewRouter()
+	r.PutFunc("/orders/{id:[0-9]+}", func(rc *ResponseContext) error {
+		got = updateOrder{}
+		if err := rc.BindValidated(&got); err != nil {
+			return err
+		}
+		rc.Writer().WriteHeader(http.StatusNoContent)
+		return nil
+	})
+
+	req := httptest.NewRequest("PUT", "/orders/42?page=3&tag=a&tag=b", strings.NewReader(`{"note":"leave at door"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant", "acme")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("status = %d; want %d, body %q", rr.Code, http.StatusNoContent, rr.Body.String())
+	}
+	want := updateOrder{ID: 42, Page: 3, Tags: []string{"a", "b"}, Tenant: "acme", Session: "s3cr3t", Note: "leave at door"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("bound %+v; want %+v", got, want)
+	}
+
+	cases := []struct {
+		name       string
+		target     string
+		tenant     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"missing required header", "/orders/1", "", http.StatusUnprocessableEntity, "X-Tenant"},
+		{"query fails validation", "/orders/1?page=0", "acme", http.StatusUnprocessableEntity, "page"},
+		{"unparsable query", "/orders/1?page=x", "acme", http.StatusBadRequest, `invalid query parameter \"page\"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", c.target, strings.NewReader(`{"note":"n"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if c.tenant != "" {
+				req.Header.Set("X-Tenant", c.tenant)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != c.wantStatus || !strings.Contains(rr.Body.String(), c.wantBody) {
+				t.Errorf("got %d %q; want %d containing %q", rr.Code, rr.Body.String(), c.wantStatus, c.wantBody)
+			}
+		})
+	}
+}
+
+// TestBindParamsForm tests that tagged fields are skipped by form binding and
+// filled from their own source by Bind.
+func TestBindParamsForm(t *testing.T) {
+	type search struct {
+		Query string `json:"q"`
+		Sort  string `query:"sort"`
+	}
+
+	var got search
+	r := NewRouter()
+	r.PostFunc("/search", func(rc *ResponseContext) error {
+		return rc.Bind(&got)
+	})
+
+	req := httptest.NewRequest("POST", "/search?sort=name", strings.NewReader("q=nova&sort=ignored"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got.Query != "nova" || got.Sort != "name" {
+		t.Errorf("bound %+v; want {Query:nova Sort:name}", got)
+	}
+}
+
+// level implements encoding.TextUnmarshaler for TestBindFormTypes.
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level")
+	}
+	return nil
+}
+
+// TestBindFormTypes tests form binding of nested structs, slices of structs,
+// pointers, times, durations, text unmarshalers and numeric slices.
+func TestBindFormTypes(t *testing.T) {
+	type address struct {
+		City string `json:"city"`
+		Zip  *int   `json:"zip"`
+	}
+	type item struct {
+		SKU string  `json:"sku"`
+		Qty int     `json:"qty"`
+		Tax float64 `json:"tax"`
+	}
+	type order struct {
+		Name     string        `json:"name"`
+		Address  address       `json:"address"`
+		Billing  *address      `json:"billing"`
+		Items    []item        `json:"items"`
+		Scores   []int         `json:"scores"`
+		Ratios   []float64     `json:"ratios"`
+		Tags     []string      `json:"tags"`
+		Due      time.Time     `json:"due"`
+		Timeout  time.Duration `json:"timeout"`
+		Priority level         `json:"priority"`
+		Express  *bool         `json:"express"`
+		Note     *string       `json:"note"`
+	}
+
+	form := url.Values{
+		"name":         {"order-1"},
+		"address.city": {"Utrecht"},
+		"address.zip":  {"3511"},
+		"billing.city": {"Delft"},
+		"items[1].sku": {"B"},
+		"items[1].qty": {"2"},
+		"items[0].sku": {"A"},
+		"items[0].qty": {"1"},
+		"items[0].tax": {"0.21"},
+		"scores":       {"3", "5"},
+		"ratios[1]":    {"0.5"},
+		"ratios[0]":    {"1.5"},
+		"tags":         {"a,b", "c"},
+		"due":          {"2025-03-01"},
+		"timeout":      {"1m30s"},
+		"priority":     {"high"},
+		"express":      {"on"},
+	}
+
+	var got order
+	if err := bindFormToStruct(form, &got); err != nil {
+		t.Fatalf("bindFormToStruct: %v", err)
+	}
+
+	zip := 3511
+	express := true
+	want := order{
+		Name:     "order-1",
+		Address:  address{City: "Utrecht", Zip: &zip},
+		Billing:  &address{City: "Delft"},
+		Items:    []item{{SKU: "A", Qty: 1, Tax: 0.21}, {SKU: "B", Qty: 2}},
+		Scores:   []int{3, 5},
+		Ratios:   []float64{1.5, 0.5},
+		Tags:     []string{"a,b", "c"},
+		Due:      time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+		Timeout:  90 * time.Second,
+		Priority: 2,
+		Express:  &express,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bound\n%+v\nwant\n%+v", got, want)
+	}
+
+	errCases := []struct {
+		form      url.Values
+		wantField string
+		wantValue string
+	}{
+		{url.Values{"items[3].qty": {"many"}}, "items[3].qty", "many"},
+		{url.Values{"scores": {"1", "x"}}, "scores[1]", "x"},
+		{url.Values{"address.zip": {"99999999999999999999"}}, "address.zip", "99999999999999999999"},
+		{url.Values{"due": {"yesterday"}}, "due", "yesterday"},
+		{url.Values{"timeout": {"soon"}}, "timeout", "soon"},
+		{url.Values{"priority": {"urgent"}}, "priority", "urgent"},
+		{url.Values{"express": {"maybe"}}, "express", "maybe"},
+	}
+	for _, c := range errCases {
+		var o order
+		err := bindFormToStruct(c.form, &o)
+		var be *BindError
+		if !errors.As(err, &be) || be.Field != c.wantField || be.Value != c.wantValue {
+			t.Errorf("bind %v: error = %v; want BindError for %q with value %q",
+				c.form, err, c.wantField, c.wantValue)
+		}
+	}
+}
+
+// TestBindValidatedFormError tests that a form conversion error is answered
+// with 400 Bad Request naming the field and the value.
+func TestBindValidatedFormError(t *testing.T) {
+	type input struct {
+		Items []struct {
+			Qty int `json:"qty"`
+		} `json:"items"`
+	}
+	r := NewRouter()
+	r.PostFunc("/", func(rc *ResponseContext) error {
+		var in input
+		return rc.BindValidated(&in)
+	})
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("items[0].qty=abc"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	want := `invalid value "abc" for field "items[0].qty"`
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("got %d %q; want 400 containing %q", rr.Code, rr.Body.String(), want)
+	}
+}
@@ -0,0 +1,275 @@
+package nova
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// RouteInfo describes a registered route, as returned by Router.Routes.
+type RouteInfo struct {
+	// Method is the HTTP method of the route.
+	Method string `json:"method"`
+	// Pattern is the full URL pattern, including subrouter and group prefixes.
+	Pattern string `json:"pattern"`
+	// Name is the name given through RouteOptions.Name, if any.
+	Name string `json:"name,omitempty"`
+	// Middleware is the number of router, group and route middleware wrapped
+	// around the handler.
+	Middleware int `json:"middleware"`
+	// Host is the host pattern the request must match, if any.
+	Host string `json:"host,omitempty"`
+	// Headers are the request headers the request must carry, if any.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Version is the API version the route belongs to, if it was registered
+	// through Router.Versions.
+	Version string `json:"version,omitempty"`
+	// Summary, OperationID, Tags and Deprecated are copied from the RouteOptions.
+	Summary     string   `json:"summary,omitempty"`
+	OperationID string   `json:"operationId,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Deprecated  bool     `json:"deprecated,omitempty"`
+	// Shadowed reports that another route, consulted first because it belongs
+	// to a subrouter, matches exactly the same requests, so this route is never
+	// reached.
+	Shadowed bool `json:"shadowed,omitempty"`
+}
+
+// MatchedRoute describes the route that matched a request. The router stores it
+// in the request context before running the middleware, so middleware can use
+// the pattern as a label with bounded cardinality, e.g. "/users/{id}".
+type MatchedRoute struct {
+	// Method is the HTTP method the route was registered for.
+	Method string
+	// Pattern is the full URL pattern, including subrouter and group prefixes.
+	Pattern string
+	// Name is the name given through RouteOptions.Name, if any.
+	Name string
+	// Options are the RouteOptions the route was registered with, or nil.
+	Options *RouteOptions
+}
+
+// GetMatchedRoute returns the route that matched the request, or nil if the
+// request did not match a route, e.g. in a 404 handler or a mounted handler.
+func GetMatchedRoute(ctx context.Context) *MatchedRoute {
+	rt, _ := ctx.Value(matchedRouteKey).(*MatchedRoute)
+	return rt
+}
+
+// Route returns the route that matched the request. See GetMatchedRoute.
+func (rc *ResponseContext) Route() *MatchedRoute {
+	return GetMatchedRoute(rc.r.Context())
+}
+
+// WithRouteObserver returns a copy of ctx that makes the router call fn with
+// the matched route of a request carrying it, before the middleware runs. Test
+// clients such as novatest use it to record which routes a test suite exercises.
+func WithRouteObserver(ctx context.Context, fn func(*MatchedRoute)) context.Context {
+	return context.WithValue(ctx, routeObserverKey, fn)
+}
+
+// walkRoutes calls fn for every route registered on r and its subrouters, the
+// router's own routes first. conds holds the host and header conditions of the
+// enclosing routers and is passed to fn together with those of the route.
+func walkRoutes(r *Router, conds []*matcher, fn func(owner *Router, rt *route, conds []*matcher)) {
+	if r.match != nil {
+		conds = append(slices.Clip(conds), r.match)
+	}
+	for _, rt := range r.routes {
+		routeConds := conds
+		if rt.match != nil {
+			routeConds = append(slices.Clip(conds), rt.match)
+		}
+		fn(r, rt, routeConds)
+	}
+	for _, sr := range r.subrouters {
+		walkRoutes(sr, conds, fn)
+	}
+}
+
+// Routes returns every route registered on the router and its subrouters,
+// including routes added through groups. Routes of a router are listed in
+// registration order, followed by those of its subrouters.
+func (r *Router) Routes() []RouteInfo {
+	rank := make(map[*route]int)
+	dispatchOrder(r, rank)
+
+	var routes []RouteInfo
+	var matched []*route
+	first := make(map[string]int)
+	walkRoutes(r, nil, func(owner *Router, rt *route, conds []*matcher) {
+		info := RouteInfo{
+			Method:     rt.method,
+			Pattern:    rt.pattern,
+			Middleware: len(owner.middlewares) + rt.middlewares,
+		}
+		if owner.version != nil {
+			info.Version = owner.version.name
+		}
+		if opts := rt.options; opts != nil {
+			info.Name = opts.Name
+			info.Summary = opts.Summary
+			info.OperationID = opts.OperationID
+			info.Tags = opts.Tags
+			info.Deprecated = opts.Deprecated
+		}
+		for _, m := range conds {
+			if m.hostPattern != "" {
+				info.Host = m.hostPattern
+			}
+			for _, h := range m.headers {
+				if info.Headers == nil {
+					info.Headers = make(map[string]string)
+				}
+				info.Headers[h.name] = h.value
+			}
+		}
+
+		// Routes matching the same requests are only reachable through the
+		// one dispatched first. Routes of different versions never compete.
+		key := routeKey(rt, conds) + " version=" + info.Version
+		if i, ok := first[key]; !ok {
+			first[key] = len(routes)
+		} else if rank[rt] > rank[matched[i]] {
+			info.Shadowed = true
+		} else {
+			routes[i].Shadowed = true
+			first[key] = len(routes)
+		}
+		routes = append(routes, info)
+		matched = append(matched, rt)
+	})
+	return routes
+}
+
+// dispatchOrder numbers the routes of r and its subrouters in the order they are
+// consulted for a request: subrouters before the router's own routes.
+func dispatchOrder(r *Router, rank map[*route]int) {
+	for _, sr := range r.subrouters {
+		dispatchOrder(sr, rank)
+	}
+	for _, rt := range r.routes {
+		rank[rt] = len(rank)
+	}
+}
+
+// routeKey returns a string that is equal for two routes if they match exactly
+// the same requests, regardless of their parameter names.
+func routeKey(rt *route, conds []*matcher) string {
+	var b strings.Builder
+	b.WriteString(rt.method)
+	for _, seg := range rt.segments {
+		b.WriteByte('/')
+		switch {
+		case seg.isLiteral():
+			b.WriteString(seg.literal)
+		case seg.catchAll:
+			b.WriteString("{...}")
+		case seg.optional:
+			b.WriteString("{?")
+		default:
+			b.WriteString("{")
+		}
+		if !seg.isLiteral() && !seg.catchAll {
+			if seg.regex != nil {
+				b.WriteString(seg.regex.String())
+			}
+			b.WriteString("}")
+		}
+	}
+	var conditions []string
+	for _, m := range conds {
+		if m.hostPattern != "" {
+			conditions = append(conditions, "host="+strings.ToLower(strings.Trim(m.hostPattern, ".")))
+		}
+		for _, h := range m.headers {
+			conditions = append(conditions, h.name+"="+strings.ToLower(h.value))
+		}
+	}
+	slices.Sort(conditions)
+	b.WriteString(" " + strings.Join(slices.Compact(conditions), " "))
+	return b.String()
+}
+
+// WriteRoutes writes routes to w as an aligned table or, if format is "json",
+// as an indented JSON array. An empty format selects the table.
+func WriteRoutes(w io.Writer, routes []RouteInfo, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if routes == nil {
+			routes = []RouteInfo{}
+		}
+		return enc.Encode(routes)
+	case "", "table":
+	default:
+		return fmt.Errorf("unknown routes format %q, expected table or json", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATTERN\tNAME\tMIDDLEWARE\tCONDITIONS\tSUMMARY\tNOTES")
+	for _, rt := range routes {
+		var conditions []string
+		if rt.Host != "" {
+			conditions = append(conditions, "host="+rt.Host)
+		}
+		if rt.Version != "" {
+			conditions = append(conditions, "version="+rt.Version)
+		}
+		for _, name := range slices.Sorted(maps.Keys(rt.Headers)) {
+			conditions = append(conditions, name+"="+rt.Headers[name])
+		}
+		var notes []string
+		if rt.Shadowed {
+			notes = append(notes, "shadowed")
+		}
+		if rt.Deprecated {
+			notes = append(notes, "deprecated")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			rt.Method, rt.Pattern, orDash(rt.Name), rt.Middleware,
+			orDash(strings.Join(conditions, " ")), orDash(rt.Summary), orDash(strings.Join(notes, ", ")))
+	}
+	return tw.Flush()
+}
+
+// orDash returns s, or "-" if s is empty, to keep table columns aligned.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// RoutesCommand returns a "routes" command that prints the routes of the router
+// built by newRouter, as a table or with --format json. Add it to the commands
+// of your application to audit its endpoints; "nova routes" runs it.
+//
+//	Commands: []*nova.Command{
+//		nova.RoutesCommand(api.NewRouter),
+//	}
+func RoutesCommand(newRouter func() *Router) *Command {
+	return &Command{
+		Name:        "routes",
+		Usage:       "List the registered routes",
+		Description: "Prints every route with its method, pattern, name, middleware count, host, header and version conditions and summary. Routes that can never be reached are marked as shadowed.",
+		Flags: []Flag{
+			&StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Usage:   "Output format: table or json",
+				Default: "table",
+			},
+		},
+		Action: func(ctx *Context) error {
+			return WriteRoutes(os.Stdout, newRouter().Routes(), ctx.String("format"))
+		},
+	}
+}
@@ -0,0 +1,243 @@
+package nova
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRoutes tests that Routes lists the routes of subrouters and groups with
+// their full pattern, middleware count, conditions and options.
+func TestRoutes(t *testing.T) {
+	mw := func(next http.Handler) http.Handler { return next }
+	h := func(w http.ResponseWriter, req *http.Request) {}
+
+	r := NewRouter()
+	r.Use(mw)
+	r.Get("/", h, &RouteOptions{Name: "home", Summary: "Home page"})
+	api := r.Subrouter("/api")
+	api.Use(mw)
+	v1 := api.Group("/v1", mw, mw)
+	v1.Post("/users", h, &RouteOptions{OperationID: "createUser", Tags: []string{"users"}, Deprecated: true})
+	r.Host("{tenant}.example.com").Get("/dashboard", h,
+		&RouteOptions{Headers: map[string]string{"X-Beta": "1"}})
+
+	got := r.Routes()
+	want := []RouteInfo{
+		{Method: "GET", Pattern: "/", Name: "home", Middleware: 1, Summary: "Home page"},
+		{Method: "POST", Pattern: "/api/v1/users", Middleware: 4, OperationID: "createUser",
+			Tags: []string{"users"}, Deprecated: true},
+		{Method: "GET", Pattern: "/dashboard", Middleware: 1, Host: "{tenant}.example.com",
+			Headers: map[string]string{"X-Beta": "1"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Routes() = %+v; want %d routes", got, len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Method != w.Method || g.Pattern != w.Pattern || g.Name != w.Name ||
+			g.Middleware != w.Middleware || g.Summary != w.Summary || g.OperationID != w.OperationID ||
+			g.Deprecated != w.Deprecated || g.Host != w.Host || len(g.Tags) != len(w.Tags) ||
+			len(g.Headers) != len(w.Headers) || g.Headers["X-Beta"] != w.Headers["X-Beta"] || g.Shadowed {
+			t.Errorf("route %d = %+v; want %+v", i, g, w)
+		}
+	}
+}
+
+// TestRoutesShadowed tests that a route hidden by an identical subrouter route
+// is marked as shadowed, while routes the parent still serves are not.
+func TestRoutesShadowed(t *testing.T) {
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	r := NewRouter()
+	r.Get("/api/users/{id}", h)
+	r.Get("/api/status", h)
+	r.Post("/api/users/{id}", h)
+	api := r.Subrouter("/api")
+	api.Get("/users/{name}", h)
+
+	shadowed := map[string]bool{}
+	for _, rt := range r.Routes() {
+		shadowed[rt.Method+" "+rt.Pattern] = rt.Shadowed
+	}
+	want := map[string]bool{
+		"GET /api/users/{id}":   true,
+		"GET /api/status":       false,
+		"POST /api/users/{id}":  false,
+		"GET /api/users/{name}": false,
+	}
+	for route, w := range want {
+		if shadowed[route] != w {
+			t.Errorf("%s shadowed = %v; want %v", route, shadowed[route], w)
+		}
+	}
+}
+
+// TestWriteRoutes tests the table and JSON output of WriteRoutes.
+func TestWriteRoutes(t *testing.T) {
+	routes := []RouteInfo{
+		{Method: "GET", Pattern: "/users/{id}", Name: "user.show", Middleware: 2, Summary: "Show user"},
+		{Method: "DELETE", Pattern: "/users/{id}", Host: "admin.example.com", Shadowed: true, Deprecated: true},
+	}
+
+	var table strings.Builder
+	if err := WriteRoutes(&table, routes, "table"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(table.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("table = %q; want a header and two rows", table.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "GET /users/{id} user.show 2 - Show user -" {
+		t.Errorf("row = %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "DELETE /users/{id} - 0 host=admin.example.com - shadowed, deprecated" {
+		t.Errorf("row = %q", lines[2])
+	}
+
+	var out strings.Builder
+	if err := WriteRoutes(&out, routes, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []RouteInfo
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	if len(decoded) != 2 || decoded[0].Name != "user.show" || !decoded[1].Shadowed {
+		t.Errorf("decoded = %+v", decoded)
+	}
+
+	if err := WriteRoutes(&out, routes, "yaml"); err == nil {
+		t.Error("WriteRoutes accepted an unknown format")
+	}
+}
+
+// TestRouteMiddleware tests that route middleware only wraps its route and runs
+// inside the router and group middleware.
+func TestRouteMiddleware(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	h := func(w http.ResponseWriter, req *http.Request) { order = append(order, "handler") }
+
+	r := NewRouter()
+	r.Use(trace("router"))
+	g := r.Group("/admin", trace("group"))
+	g.Get("/users", h, &RouteOptions{Middleware: []Middleware{trace("route1"), trace("route2")}})
+	g.GetFunc("/stats", func(rc *ResponseContext) error {
+		order = append(order, "handler")
+		return nil
+	}, &RouteOptions{Middleware: []Middleware{trace("route")}})
+	r.Get("/plain", h)
+
+	cases := map[string]string{
+		"/admin/users": "router group route1 route2 handler",
+		"/admin/stats": "router group route handler",
+		"/plain":       "router handler",
+	}
+	for path, want := range cases {
+		order = nil
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		if got := strings.Join(order, " "); got != want {
+			t.Errorf("GET %s ran %q; want %q", path, got, want)
+		}
+	}
+
+	for _, rt := range r.Routes() {
+		if rt.Pattern == "/admin/users" && rt.Middleware != 4 {
+			t.Errorf("/admin/users middleware = %d; want 4", rt.Middleware)
+		}
+	}
+}
+
+// TestMatchedRoute tests that middleware, handlers and route observers can read
+// the matched route and that LoggingMiddleware logs its pattern.
+func TestMatchedRoute(t *testing.T) {
+	var seen *MatchedRoute
+	capture := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			seen = GetMatchedRoute(req.Context())
+			next.ServeHTTP(w, req)
+		})
+	}
+	var logs strings.Builder
+	opts := &RouteOptions{Name: "user.show", Tags: []string{"users"}}
+
+	r := NewRouter()
+	r.Use(LoggingMiddleware(&LoggingConfig{Logger: log.New(&logs, "", 0)}), capture)
+	api := r.Subrouter("/api")
+	api.GetFunc("/users/{id}", func(rc *ResponseContext) error {
+		if rt := rc.Route(); rt == nil || rt.Name != "user.show" {
+			t.Errorf("rc.Route() = %+v", rt)
+		}
+		return rc.Text(http.StatusOK, rc.URLParam("id"))
+	}, opts)
+
+	var observed *MatchedRoute
+	req := httptest.NewRequest("GET", "/api/users/42", nil)
+	req = req.WithContext(WithRouteObserver(req.Context(), func(rt *MatchedRoute) { observed = rt }))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if observed == nil || observed != seen {
+		t.Errorf("observed route = %+v; want %+v", observed, seen)
+	}
+	if seen == nil || seen.Method != "GET" || seen.Pattern != "/api/users/{id}" || seen.Options != opts {
+		t.Errorf("matched route = %+v", seen)
+	}
+	if !strings.Contains(logs.String(), "Completed GET /api/users/{id} 200") {
+		t.Errorf("log = %q; want the route pattern", logs.String())
+	}
+
+	r.SetNotFoundHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if rt := GetMatchedRoute(req.Context()); rt != nil {
+			t.Errorf("matched route for 404 = %+v", rt)
+		}
+	}))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+	if GetMatchedRoute(httptest.NewRequest("GET", "/", nil).Context()) != nil {
+		t.Error("GetMatchedRoute returned a route for a plain request")
+	}
+}
+
+// TestRouteRateLimitKey tests that RouteRateLimitKey gives every client a
+// budget per route pattern.
+func TestRouteRateLimitKey(t *testing.T) {
+	r := NewRouter()
+	r.Use(RateLimitMiddleware(RateLimiterConfig{Requests: 1, Duration: time.Minute, KeyFunc: RouteRateLimitKey}))
+	ok := func(rc *ResponseContext) error { return rc.Text(http.StatusOK, "ok") }
+	r.GetFunc("/users/{id}", ok)
+	r.GetFunc("/login", ok)
+
+	cases := []struct {
+		remoteAddr, path string
+		want             int
+	}{
+		{"203.0.113.7:1234", "/users/1", http.StatusOK},
+		{"203.0.113.7:1235", "/users/2", http.StatusTooManyRequests},
+		{"203.0.113.7:1236", "/login", http.StatusOK},
+		{"198.51.100.1:1234", "/users/3", http.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", c.path, nil)
+		req.RemoteAddr = c.remoteAddr
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		if rr.Code != c.want {
+			t.Errorf("GET %s from %s = %d; want %d", c.path, c.remoteAddr, rr.Code, c.want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	if key := RouteRateLimitKey(req); key != "203.0.113.7" {
+		t.Errorf("key without a matched route = %q; want the IP", key)
+	}
+}
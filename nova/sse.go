@@ -0,0 +1,262 @@
+package nova
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultSSEKeepAlive is the default interval of keep-alive comments.
+const defaultSSEKeepAlive = 15 * time.Second
+
+// SSEConfig holds configuration for a Server-Sent Events stream.
+type SSEConfig struct {
+	// KeepAlive is the interval at which a comment is sent while no events are
+	// written, so proxies do not close an idle connection. Defaults to 15 seconds.
+	// A negative value disables keep-alive comments.
+	KeepAlive time.Duration
+	// Retry is sent to the client as the initial reconnection delay if positive.
+	Retry time.Duration
+}
+
+// SSEEvent is a single Server-Sent Event. Empty fields are omitted.
+type SSEEvent struct {
+	// ID sets the event ID, which the client sends back in the Last-Event-ID
+	// header when it reconnects.
+	ID string
+	// Event is the event type. Clients dispatch events without a type as "message".
+	Event string
+	// Data is the event payload. Strings and byte slices are sent as they are,
+	// other values are encoded as JSON. Multi-line data is split into several
+	// data lines.
+	Data any
+	// Retry sets the client's reconnection delay if positive.
+	Retry time.Duration
+}
+
+// SSEStream writes Server-Sent Events to a client. It is created with
+// ResponseContext.SSE and is safe for concurrent use.
+type SSEStream struct {
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	ctx         context.Context
+	lastEventID string
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+}
+
+// SSE starts a Server-Sent Events stream. It sends the text/event-stream
+// headers with status 200 and flushes them, so the client sees the stream open
+// immediately. Every event is flushed as soon as it is written, also through
+// the logging, gzip and timeout middleware; TimeoutMiddleware does not apply
+// its deadline to event streams. If the response cannot be flushed, SSE
+// returns an error wrapping http.ErrNotSupported before writing anything, so
+// the error handler can still respond.
+//
+// The stream ends when the handler returns or the client disconnects, which is
+// reported by Done and by the errors returned from Send. Errors returned by the
+// handler after the stream has started are logged instead of being passed to the
+// error handler, since the response has already begun.
+//
+//	stream, err := ctx.SSE(nova.SSEConfig{})
+//	if err != nil {
+//		return err
+//	}
+//	for {
+//		select {
+//		case <-stream.Done():
+//			return nil
+//		case update := <-updates:
+//			if err := stream.Send(nova.SSEEvent{Event: "update", Data: update}); err != nil {
+//				return err
+//			}
+//		}
+//	}
+func (rc *ResponseContext) SSE(config SSEConfig) (*SSEStream, error) {
+	if rc.sse != nil {
+		return nil, errors.New("nova: SSE stream already started")
+	}
+	if config.KeepAlive == 0 {
+		config.KeepAlive = defaultSSEKeepAlive
+	}
+	// Check before committing the status, so the error can still be reported.
+	if !canFlush(rc.w) {
+		return nil, fmt.Errorf("nova: response does not support streaming: %w", http.ErrNotSupported)
+	}
+
+	h := rc.w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+
+	s := &SSEStream{
+		w:           rc.w,
+		rc:          http.NewResponseController(rc.w),
+		ctx:         rc.r.Context(),
+		lastEventID: rc.r.Header.Get("Last-Event-ID"),
+		stop:        make(chan struct{}),
+	}
+	rc.w.WriteHeader(http.StatusOK)
+	if config.Retry > 0 {
+		fmt.Fprintf(rc.w, "retry: %d\n\n", config.Retry.Milliseconds())
+	}
+	if err := s.rc.Flush(); err != nil {
+		return nil, fmt.Errorf("nova: response does not support streaming: %w", err)
+	}
+	rc.sse = s
+
+	if config.KeepAlive > 0 {
+		go s.keepAlive(config.KeepAlive)
+	}
+	return s, nil
+}
+
+// canFlush reports whether w can flush a streamed response. The writers that
+// wrap a response forward Flush to the writer they wrap, so the innermost
+// writer decides.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	switch w.(type) {
+	case http.Flusher, interface{ FlushError() error }:
+		return true
+	}
+	return false
+}
+
+// LastEventID returns the Last-Event-ID header sent by a reconnecting client, so
+// the handler can resume after the last event it received. It is empty on the
+// first connection.
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Done returns a channel that is closed when the client disconnects or the
+// request is canceled.
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// Send writes ev to the client and flushes it. It returns the request context's
+// error once the client has disconnected.
+func (s *SSEStream) Send(ev SSEEvent) error {
+	var buf bytes.Buffer
+	if ev.ID != "" {
+		if strings.ContainsAny(ev.ID, "\r\n\x00") {
+			return fmt.Errorf("nova: invalid SSE event ID %q", ev.ID)
+		}
+		buf.WriteString("id: " + ev.ID + "\n")
+	}
+	if ev.Event != "" {
+		if strings.ContainsAny(ev.Event, "\r\n") {
+			return fmt.Errorf("nova: invalid SSE event type %q", ev.Event)
+		}
+		buf.WriteString("event: " + ev.Event + "\n")
+	}
+	if ev.Retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+	if ev.Data != nil {
+		data, err := sseData(ev.Data)
+		if err != nil {
+			return err
+		}
+		for _, line := range splitLines(data) {
+			buf.WriteString("data: " + line + "\n")
+		}
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// Comment writes a comment line, which clients ignore. Newlines in text start
+// additional comment lines.
+func (s *SSEStream) Comment(text string) error {
+	var buf bytes.Buffer
+	for _, line := range splitLines(text) {
+		buf.WriteString(": " + line + "\n")
+	}
+	buf.WriteByte('\n')
+	return s.write(buf.Bytes())
+}
+
+// Close stops the keep-alive comments. Later writes return an error. The stream is
+// closed automatically when the handler returns.
+func (s *SSEStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+}
+
+// write writes and flushes p unless the stream is closed or the client is gone.
+func (s *SSEStream) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("nova: SSE stream is closed")
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(p); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// keepAlive sends a comment every interval until the stream ends.
+func (s *SSEStream) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if s.write([]byte(": keep-alive\n\n")) != nil {
+				return
+			}
+		case <-s.stop:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// sseData returns the text of an event's data field.
+func sseData(v any) (string, error) {
+	switch d := v.(type) {
+	case string:
+		return d, nil
+	case []byte:
+		return string(d), nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("nova: encoding SSE data: %w", err)
+	}
+	return string(data), nil
+}
+
+// splitLines splits s at CRLF, LF and CR line endings.
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.ReplaceAll(s, "\r", "\n"), "\n")
+}
@@ -0,0 +1,227 @@
+package nova
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSSEFraming tests the wire format of events, comments and the stream headers.
+func TestSSEFraming(t *testing.T) {
+	r := NewRouter()
+	r.GetFunc("/events", func(rc *ResponseContext) error {
+		stream, err := rc.SSE(SSEConfig{KeepAlive: -1, Retry: 3 * time.Second})
+		if err != nil {
+			return err
+		}
+		stream.Comment("resuming after " + stream.LastEventID())
+		stream.Send(SSEEvent{ID: "8", Event: "update", Data: map[string]int{"count": 8}})
+		stream.Send(SSEEvent{Data: "line one\nline two", Retry: time.Second})
+		if err := stream.Send(SSEEvent{ID: "bad\nid"}); err == nil {
+			t.Error("Send accepted an ID containing a newline")
+		}
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "7")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q; want text/event-stream", ct)
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Cache-Control = %q; want no-cache", cc)
+	}
+	want := "retry: 3000\n\n" +
+		": resuming after 7\n\n" +
+		"id: 8\nevent: update\ndata: {\"count\":8}\n\n" +
+		"retry: 1000\ndata: line one\ndata: line two\n\n"
+	if rr.Body.String() != want {
+		t.Errorf("body = %q; want %q", rr.Body.String(), want)
+	}
+}
+
+// TestSSEMiddleware tests that events reach the client while the handler is still
+// running through the middleware that wrap the response writer, and that the
+// handler sees the client disconnect.
+func TestSSEMiddleware(t *testing.T) {
+	discard := log.New(io.Discard, "", 0)
+	cases := []struct {
+		name string
+		mw   Middleware
+	}{
+		{"none", func(next http.Handler) http.Handler { return next }},
+		{"logging", LoggingMiddleware(&LoggingConfig{Logger: discard})},
+		{"gzip", GzipMiddleware(&GzipConfig{Logger: discard})},
+		{"etag", ETagMiddleware(nil)},
+		{"timeout", TimeoutMiddleware(TimeoutConfig{Duration: 50 * time.Millisecond})},
+		{"timeout handler", TimeoutMiddleware(TimeoutConfig{Duration: 50 * time.Millisecond, ProblemDetails: true})},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			done := make(chan struct{})
+			r := NewRouter()
+			r.Use(c.mw)
+			r.GetFunc("/events", func(rc *ResponseContext) error {
+				defer close(done)
+				stream, err := rc.SSE(SSEConfig{KeepAlive: 10 * time.Millisecond})
+				if err != nil {
+					return err
+				}
+				if err := stream.Send(SSEEvent{Event: "ready", Data: "hello"}); err != nil {
+					return err
+				}
+				<-stream.Done()
+				return nil
+			})
+			srv := httptest.NewServer(r)
+			defer srv.Close()
+
+			req, _ := http.NewRequest("GET", srv.URL+"/events", nil)
+			req.Header.Set("Accept", "text/event-stream")
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lines := make(chan string)
+			go func() {
+				defer close(lines)
+				scanner := bufio.NewScanner(resp.Body)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+
+			want := []string{"event: ready", "data: hello", "", ": keep-alive"}
+			for _, w := range want {
+				select {
+				case line := <-lines:
+					if line != w {
+						t.Fatalf("line = %q; want %q", line, w)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("timed out waiting for %q; the stream is buffered", w)
+				}
+			}
+
+			resp.Body.Close()
+			select {
+			case <-done:
+			case <-time.After(2 * time.Second):
+				t.Fatal("handler did not notice the client disconnect")
+			}
+		})
+	}
+}
+
+// TestTimeoutStreamingHeaders tests that request headers asking for a stream
+// or an upgrade do not lift the timeout of a handler that does not stream.
+func TestTimeoutStreamingHeaders(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(300 * time.Millisecond):
+			w.Write([]byte("late"))
+		case <-r.Context().Done():
+		}
+	})
+	handler := TimeoutMiddleware(TimeoutConfig{Duration: 20 * time.Millisecond})(slow)
+	for _, header := range []http.Header{
+		{},
+		{"Accept": {"text/event-stream"}},
+		{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header = header
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusServiceUnavailable || rr.Body.String() != "Service timed out" {
+			t.Errorf("headers %v: status %d, body %q; want the timeout response", header, rr.Code, rr.Body.String())
+		}
+	}
+
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	})
+	rr := httptest.NewRecorder()
+	TimeoutMiddleware(TimeoutConfig{Duration: time.Second})(fast).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusCreated || rr.Body.String() != "done" || rr.Header().Get("X-Test") != "1" {
+		t.Errorf("buffered response: status %d, body %q, headers %v", rr.Code, rr.Body.String(), rr.Header())
+	}
+}
+
+// TestSSEAfterHandlerReturns tests that handler errors after the stream started
+// do not reach the error handler and that the stream is closed.
+func TestSSEAfterHandlerReturns(t *testing.T) {
+	var stream *SSEStream
+	r := NewRouter()
+	r.SetErrorHandler(func(rc *ResponseContext, err error) {
+		t.Errorf("error handler called with %v", err)
+	})
+	r.GetFunc("/events", func(rc *ResponseContext) error {
+		var err error
+		if stream, err = rc.SSE(SSEConfig{}); err != nil {
+			return err
+		}
+		return io.ErrUnexpectedEOF
+	})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/events", nil))
+	if err := stream.Send(SSEEvent{Data: "late"}); err == nil {
+		t.Error("Send succeeded after the handler returned")
+	}
+	if strings.Contains(rr.Body.String(), "late") {
+		t.Errorf("body = %q; want no events after the handler returned", rr.Body.String())
+	}
+}
+
+// plainWriter hides every optional interface of the writer it wraps.
+type plainWriter struct {
+	w http.ResponseWriter
+}
+
+func (p plainWriter) Header() http.Header         { return p.w.Header() }
+func (p plainWriter) Write(b []byte) (int, error) { return p.w.Write(b) }
+func (p plainWriter) WriteHeader(code int)        { p.w.WriteHeader(code) }
+
+// TestSSEWithoutFlush tests that SSE fails before committing the response
+// when the writer cannot stream, even behind nova's own wrappers.
+func TestSSEWithoutFlush(t *testing.T) {
+	r := NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(plainWriter{w}, req)
+		})
+	})
+	r.Use(LoggingMiddleware(&LoggingConfig{Logger: log.New(io.Discard, "", 0)}))
+	r.GetFunc("/events", func(rc *ResponseContext) error {
+		_, err := rc.SSE(SSEConfig{KeepAlive: -1, Retry: time.Second})
+		if !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("SSE error = %v; want http.ErrNotSupported", err)
+		}
+		return err
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d; want 500", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct == "text/event-stream" {
+		t.Error("event stream headers set on the error response")
+	}
+	if strings.Contains(rec.Body.String(), "retry:") {
+		t.Errorf("body = %q; want no stream data", rec.Body)
+	}
+}
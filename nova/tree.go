@@ -0,0 +1,248 @@
+package nova
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// node is a single node in the routing tree. Each level of the tree corresponds
+// to one path segment, so lookup cost depends on the depth of the request path
+// rather than on the number of registered routes. Children are tried in order
+// of precedence: static literals first, then regex-constrained and embedded
+// parameters, then plain parameters and finally catch-alls.
+type node struct {
+	// static maps the literal text of a segment to its child node.
+	static map[string]*node
+	// regexChildren contains the regex-constrained parameter children in registration order.
+	regexChildren []*node
+	// param is the child for an unconstrained parameter segment ({name}).
+	param *node
+	// catchAll is the child for a trailing {name...} segment. Its routes match
+	// any number of remaining path segments, including none, so "/files" is
+	// served by "/files/{path...}" unless a route ends at this node.
+	catchAll *node
+	// regex is the constraint a path segment must satisfy to enter this node,
+	// set only for children stored in regexChildren.
+	regex *regexp.Regexp
+	// routes contains the routes whose pattern ends at this node, in registration order.
+	routes []*route
+}
+
+// splitPath trims leading and trailing slashes from a request path and splits
+// it into its segments. The root path yields an empty slice.
+func splitPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return []string{}
+	}
+	return strings.Split(trimmed, "/")
+}
+
+// insert adds the route to the tree, creating intermediate nodes for its
+// segments as needed. A route with optional segments is added at the node
+// before each of them as well. insert returns an error if a route with the same
+// method and conditions already ends at one of these nodes, since the two
+// patterns would match exactly the same requests.
+func (n *node) insert(rt *route) error {
+	cur := n
+	for _, seg := range rt.segments {
+		if seg.optional {
+			if err := cur.add(rt); err != nil {
+				return err
+			}
+		}
+		cur = cur.child(seg)
+	}
+	return cur.add(rt)
+}
+
+// add appends rt to the routes ending at n unless it conflicts with one of them.
+func (n *node) add(rt *route) error {
+	for _, existing := range n.routes {
+		if existing.method == rt.method && existing.match.equal(rt.match) {
+			return fmt.Errorf("%s %s conflicts with %s %s", rt.method, rt.pattern, existing.method, existing.pattern)
+		}
+	}
+	n.routes = append(n.routes, rt)
+	return nil
+}
+
+// child returns the child node for the given segment, creating it if it does
+// not exist yet. Parameters with the same regex share a node regardless of
+// their names, since names are resolved from the route once it matched.
+func (n *node) child(seg segment) *node {
+	switch {
+	case seg.isLiteral():
+		if n.static == nil {
+			n.static = make(map[string]*node)
+		}
+		c, ok := n.static[seg.literal]
+		if !ok {
+			c = &node{}
+			n.static[seg.literal] = c
+		}
+		return c
+	case seg.catchAll:
+		if n.catchAll == nil {
+			n.catchAll = &node{}
+		}
+		return n.catchAll
+	case seg.regex != nil:
+		for _, c := range n.regexChildren {
+			if c.regex.String() == seg.regex.String() {
+				return c
+			}
+		}
+		c := &node{regex: seg.regex}
+		n.regexChildren = append(n.regexChildren, c)
+		return c
+	default:
+		if n.param == nil {
+			n.param = &node{}
+		}
+		return n.param
+	}
+}
+
+// lookup walks the tree for the given path segments and returns the route
+// registered for method whose host and header conditions req meets. Routes with
+// conditions take precedence over routes without. If no route handles method but
+// at least one pattern matches the path, lookup returns a nil route and true so
+// the caller can respond with 405 Method Not Allowed.
+func (n *node) lookup(method string, parts []string, req *http.Request) (*route, bool) {
+	var pathMatched bool
+	if len(parts) == 0 {
+		rt, matched := n.route(method, req)
+		if rt != nil {
+			return rt, true
+		}
+		pathMatched = matched
+	} else {
+		part, rest := parts[0], parts[1:]
+
+		if c, ok := n.static[part]; ok {
+			rt, matched := c.lookup(method, rest, req)
+			if rt != nil {
+				return rt, true
+			}
+			pathMatched = pathMatched || matched
+		}
+		for _, c := range n.regexChildren {
+			if !c.regex.MatchString(part) {
+				continue
+			}
+			rt, matched := c.lookup(method, rest, req)
+			if rt != nil {
+				return rt, true
+			}
+			pathMatched = pathMatched || matched
+		}
+		if n.param != nil {
+			rt, matched := n.param.lookup(method, rest, req)
+			if rt != nil {
+				return rt, true
+			}
+			pathMatched = pathMatched || matched
+		}
+	}
+
+	if n.catchAll != nil {
+		rt, matched := n.catchAll.route(method, req)
+		if rt != nil {
+			return rt, true
+		}
+		pathMatched = pathMatched || matched
+	}
+	return nil, pathMatched
+}
+
+// route returns the route ending at n that is registered for method and whose
+// conditions req meets, preferring routes with conditions. The boolean reports
+// whether any route ending at n accepts req, regardless of its method.
+func (n *node) route(method string, req *http.Request) (*route, bool) {
+	var fallback *route
+	var matched bool
+	for _, rt := range n.routes {
+		if _, ok := rt.match.match(req); !ok {
+			continue
+		}
+		matched = true
+		if rt.method != method {
+			continue
+		}
+		if rt.match != nil {
+			return rt, true
+		}
+		if fallback == nil {
+			fallback = rt
+		}
+	}
+	return fallback, matched
+}
+
+// methods appends the methods of every route whose pattern matches parts and
+// whose conditions req meets to methods and returns the result. Since lookup
+// falls back to lower precedence candidates, each of these methods can actually
+// be served for the path.
+func (n *node) methods(parts []string, req *http.Request, methods []string) []string {
+	if len(parts) == 0 {
+		methods = n.routeMethods(req, methods)
+	} else {
+		part, rest := parts[0], parts[1:]
+		if c, ok := n.static[part]; ok {
+			methods = c.methods(rest, req, methods)
+		}
+		for _, c := range n.regexChildren {
+			if c.regex.MatchString(part) {
+				methods = c.methods(rest, req, methods)
+			}
+		}
+		if n.param != nil {
+			methods = n.param.methods(rest, req, methods)
+		}
+	}
+	if n.catchAll != nil {
+		methods = n.catchAll.routeMethods(req, methods)
+	}
+	return methods
+}
+
+// routeMethods appends the methods of the routes ending at n whose conditions
+// req meets to methods and returns the result.
+func (n *node) routeMethods(req *http.Request, methods []string) []string {
+	for _, rt := range n.routes {
+		if _, ok := rt.match.match(req); !ok {
+			continue
+		}
+		if !slices.Contains(methods, rt.method) {
+			methods = append(methods, rt.method)
+		}
+	}
+	return methods
+}
+
+// params extracts the parameter values for a matched route from the request
+// path segments. A catch-all receives the remaining segments joined by "/", or
+// "" if there are none, and optional parameters missing from the path are left
+// out. It returns nil if the
+// route has no parameters.
+func (rt *route) params(parts []string) map[string]string {
+	var params map[string]string
+	for i, seg := range rt.segments {
+		if seg.isLiteral() || (i >= len(parts) && !seg.catchAll) {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		if seg.catchAll {
+			params[seg.paramName] = strings.Join(parts[i:], "/")
+			continue
+		}
+		seg.capture(parts[i], params)
+	}
+	return params
+}
@@ -0,0 +1,126 @@
+package nova
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// TypedHandlerFunc is a handler that receives its request already bound and
+// validated, and returns the value to send as the response body.
+type TypedHandlerFunc[Req, Resp any] func(rc *ResponseContext, req *Req) (Resp, error)
+
+// RouteRegistrar registers enhanced handlers. It is implemented by *Router and *Group.
+type RouteRegistrar interface {
+	HandleFunc(method, pattern string, handler HandlerFunc, opts ...*RouteOptions)
+}
+
+// Handle registers a typed handler on a router or group. The request is bound into
+// a new Req with BindValidated, so body fields as well as path, query, header and
+// cookie fields are filled and validated before h runs. Binding and validation
+// errors are passed to the router's error handler, just like errors returned by h.
+//
+// The returned Resp is encoded with ResponseContext.Negotiate, so as JSON unless
+// the client asks for another registered media type. The status is the lowest 2xx
+// status listed in the route options' Responses, or 200 OK if there is none. With
+// 204 No Content the response has no body.
+//
+// The OpenAPI metadata is derived from the types: RequestBody defaults to Req and
+// the success response body to Resp, unless they are set in opts.
+//
+// Handle panics if the tags of Req are malformed, e.g. if a validate tag names
+// a validator that is not registered.
+//
+//	nova.Handle(router, http.MethodPost, "/users",
+//		func(rc *nova.ResponseContext, in *CreateUser) (User, error) {
+//			return store.Create(in)
+//		},
+//		&nova.RouteOptions{Responses: map[int]nova.ResponseOption{201: {Description: "Created"}}},
+//	)
+func Handle[Req, Resp any](r RouteRegistrar, method, pattern string, h TypedHandlerFunc[Req, Resp], opts ...*RouteOptions) {
+	if t := reflect.TypeFor[Req](); t.Kind() == reflect.Struct {
+		if err := checkTags(t); err != nil {
+			panic("Handle: " + err.Error())
+		}
+	}
+	options := typedRouteOptions[Req, Resp](opts)
+	status := successStatus(options)
+
+	r.HandleFunc(method, pattern, func(rc *ResponseContext) error {
+		req := new(Req)
+		if err := rc.bindTyped(req); err != nil {
+			return err
+		}
+		resp, err := h(rc, req)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusNoContent {
+			rc.w.WriteHeader(status)
+			return nil
+		}
+		return rc.Negotiate(status, resp)
+	}, options)
+}
+
+// bindTyped binds and validates v for a typed handler. The body is only read
+// when v has fields that come from the body.
+func (rc *ResponseContext) bindTyped(v any) error {
+	if hasBodyFields(v) {
+		return rc.BindValidated(v)
+	}
+	if err := rc.BindParams(v); err != nil {
+		return err
+	}
+	return rc.validate(v)
+}
+
+// typedRouteOptions returns a copy of the first route options, or new options, with
+// RequestBody and the success response body filled in from Req and Resp.
+func typedRouteOptions[Req, Resp any](opts []*RouteOptions) *RouteOptions {
+	options := &RouteOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		copied := *opts[0]
+		options = &copied
+	}
+
+	if options.RequestBody == nil && !isEmptyStruct(reflect.TypeFor[Req]()) {
+		options.RequestBody = new(Req)
+	}
+
+	status := successStatus(options)
+	responses := make(map[int]ResponseOption, len(options.Responses)+1)
+	for code, resp := range options.Responses {
+		responses[code] = resp
+	}
+	resp := responses[status]
+	if resp.Description == "" {
+		resp.Description = http.StatusText(status)
+	}
+	if resp.Body == nil && status != http.StatusNoContent && !isEmptyStruct(reflect.TypeFor[Resp]()) {
+		resp.Body = new(Resp)
+	}
+	responses[status] = resp
+	options.Responses = responses
+
+	return options
+}
+
+// successStatus returns the lowest 2xx status in opts.Responses, or 200 OK.
+func successStatus(opts *RouteOptions) int {
+	status := 0
+	for code := range opts.Responses {
+		if code >= 200 && code < 300 && (status == 0 || code < status) {
+			status = code
+		}
+	}
+	if status == 0 {
+		return http.StatusOK
+	}
+	return status
+}
+
+// isEmptyStruct reports whether t is a struct without fields, such as struct{},
+// which is used for handlers without input or output.
+func isEmptyStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
@@ -0,0 +1,124 @@
+package nova
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type createUser struct {
+	Org  string `path:"org"`
+	Name string `json:"name" minlength:"2"`
+}
+
+type user struct {
+	ID   int    `json:"id"`
+	Org  string `json:"org"`
+	Name string `json:"name"`
+}
+
+type getUser struct {
+	ID int `path:"id"`
+}
+
+// TestHandleTyped tests binding, validation and encoding of typed handlers on
+// routers and groups.
+func TestHandleTyped(t *testing.T) {
+	r := NewRouter()
+	Handle(r, http.MethodPost, "/orgs/{org}/users",
+		func(rc *ResponseContext, in *createUser) (user, error) {
+			return user{ID: 1, Org: in.Org, Name: in.Name}, nil
+		},
+		&RouteOptions{Responses: map[int]ResponseOption{
+			http.StatusCreated:  {Description: "Created"},
+			http.StatusConflict: {Description: "Already exists"},
+		}},
+	)
+	Handle(r.Group("/users"), http.MethodGet, "/{id:[0-9]+}",
+		func(rc *ResponseContext, in *getUser) (*user, error) {
+			if in.ID != 7 {
+				return nil, NewHTTPError(http.StatusNotFound, "user not found")
+			}
+			return &user{ID: in.ID, Name: "Ada"}, nil
+		},
+	)
+	Handle(r, http.MethodDelete, "/users/{id}",
+		func(rc *ResponseContext, in *getUser) (struct{}, error) {
+			return struct{}{}, nil
+		},
+		&RouteOptions{Responses: map[int]ResponseOption{http.StatusNoContent: {}}},
+	)
+
+	cases := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"created", "POST", "/orgs/acme/users", `{"name":"Ada"}`, http.StatusCreated, `{"id":1,"org":"acme","name":"Ada"}`},
+		{"validation", "POST", "/orgs/acme/users", `{"name":"A"}`, http.StatusUnprocessableEntity, "at least 2 characters"},
+		{"invalid json", "POST", "/orgs/acme/users", `{`, http.StatusBadRequest, "invalid JSON"},
+		{"params only", "GET", "/users/7", "", http.StatusOK, `{"id":7,"org":"","name":"Ada"}`},
+		{"handler error", "GET", "/users/8", "", http.StatusNotFound, "user not found"},
+		{"no content", "DELETE", "/users/7", "", http.StatusNoContent, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != c.wantStatus || !strings.Contains(rr.Body.String(), c.wantBody) {
+				t.Errorf("got %d %q; want %d containing %q", rr.Code, rr.Body.String(), c.wantStatus, c.wantBody)
+			}
+		})
+	}
+}
+
+// TestHandleTypedOpenAPI tests that typed handlers document their request and
+// response types without hand-written options.
+func TestHandleTypedOpenAPI(t *testing.T) {
+	r := NewRouter()
+	opts := &RouteOptions{Summary: "Create user", Responses: map[int]ResponseOption{
+		http.StatusCreated: {Description: "Created"},
+	}}
+	Handle(r, http.MethodPost, "/orgs/{org}/users",
+		func(rc *ResponseContext, in *createUser) (user, error) { return user{}, nil }, opts)
+	Handle(r, http.MethodGet, "/users/{id}",
+		func(rc *ResponseContext, in *getUser) ([]user, error) { return nil, nil })
+
+	if opts.Responses[http.StatusCreated].Body != nil {
+		t.Error("Handle modified the caller's RouteOptions")
+	}
+
+	spec := GenerateOpenAPISpec(r, OpenAPIConfig{Title: "t", Version: "1"})
+	data, _ := json.Marshal(spec)
+
+	post := spec.Paths["/orgs/{org}/users"].Post
+	if post.Summary != "Create user" || post.RequestBody == nil {
+		t.Fatalf("POST operation = %s", data)
+	}
+	if ref := post.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/createUser" {
+		t.Errorf("request body ref = %q", ref)
+	}
+	created := post.Responses["201"]
+	if created == nil || created.Description != "Created" ||
+		created.Content["application/json"].Schema.Ref != "#/components/schemas/user" {
+		t.Errorf("201 response = %+v", created)
+	}
+	if len(post.Parameters) != 1 || post.Parameters[0].Name != "org" {
+		t.Errorf("POST parameters = %+v; want org", post.Parameters)
+	}
+
+	get := spec.Paths["/users/{id}"].Get
+	if get.RequestBody != nil {
+		t.Error("GET with only path parameters has a request body")
+	}
+	if ok := get.Responses["200"]; ok == nil || ok.Content["application/json"].Schema.Type != "array" {
+		t.Errorf("200 response = %+v; want array body", ok)
+	}
+}
@@ -0,0 +1,478 @@
+package nova
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationField describes the field a custom validator checks.
+type ValidationField struct {
+	// Name is the field name used in messages: the json or parameter tag name,
+	// or the Go field name.
+	Name string
+	// Value is the field value.
+	Value any
+	// Parent is the struct containing the field.
+	Parent any
+	// Param is the text after "=" in the validate tag, e.g. "users.email" for
+	// `validate:"unique=users.email"`, or "".
+	Param string
+	// Lang is the language selected for validation messages, e.g. "en".
+	Lang string
+}
+
+// ValidatorFunc checks a field tagged with the validator's name in its validate
+// tag. It reports whether the value is valid. A non-nil error means the check
+// itself failed, e.g. because the database is unreachable; it aborts validation
+// and is returned from BindValidated as is, resulting in a 500 response.
+type ValidatorFunc func(ctx context.Context, field ValidationField) (bool, error)
+
+var (
+	// validationMu guards validators and the message catalogs in
+	// validationMessages.
+	validationMu sync.RWMutex
+	// validators contains the custom validators by name.
+	validators = map[string]ValidatorFunc{}
+	// normalizers contains the Unicode normalization functions by upper case
+	// form name.
+	normalizers = map[string]func(string) string{}
+)
+
+// RegisterValidator registers a custom validator used by fields whose validate
+// tag lists name. Tags list validators separated by commas, each optionally
+// followed by "=" and a parameter:
+//
+//	type Account struct {
+//		IBAN  string `json:"iban" validate:"iban"`
+//		Email string `json:"email" format:"email" validate:"notdisposable,unique=users.email"`
+//	}
+//
+// Validators run after the built-in checks, only for non-zero values, and get
+// the request context, so they can query a database. The message for a failed
+// check is looked up like the built-in ones, using name as key with the field
+// name and parameter as arguments; see RegisterValidationMessage. The error tag
+// overrides it as usual. RegisterValidator panics if name is empty, contains a
+// comma or "=", or is already registered, or if fn is nil.
+//
+// Register validators before serving requests: validate tags naming an unknown
+// validator are reported when a type is first validated, failing every
+// validation of the type, and make Handle panic for its request type.
+func RegisterValidator(name string, fn ValidatorFunc) {
+	if name == "" || strings.ContainsAny(name, ",=") || fn == nil {
+		panic(fmt.Sprintf("RegisterValidator: invalid validator %q", name))
+	}
+	validationMu.Lock()
+	defer validationMu.Unlock()
+	if _, exists := validators[name]; exists {
+		panic(fmt.Sprintf("RegisterValidator: validator %q already registered", name))
+	}
+	validators[name] = fn
+}
+
+// RegisterNormalizer registers the function the normalize tag uses for form,
+// e.g. "NFC". Forms are matched case-insensitively. Nova ships no Unicode
+// normalization tables, so it stays free of dependencies; import the novanorm
+// package to register NFC, NFD, NFKC and NFKD from golang.org/x/text:
+//
+//	import _ "github.com/xlc-dev/nova/nova/novanorm"
+//
+// RegisterNormalizer panics if form is empty or already registered, or if fn
+// is nil.
+func RegisterNormalizer(form string, fn func(string) string) {
+	if form == "" || fn == nil {
+		panic(fmt.Sprintf("RegisterNormalizer: invalid form %q", form))
+	}
+	validationMu.Lock()
+	defer validationMu.Unlock()
+	form = strings.ToUpper(form)
+	if _, exists := normalizers[form]; exists {
+		panic(fmt.Sprintf("RegisterNormalizer: form %q already registered", form))
+	}
+	normalizers[form] = fn
+}
+
+// RegisterValidationMessage sets the message template for a validation key in
+// the given language, e.g. "en" or "pt-BR". The key is the name of a custom
+// validator or of a built-in rule such as "minlength", whose template it
+// replaces. Templates are fmt format strings receiving the field name and, for
+// custom validators with a tag parameter, the parameter:
+//
+//	nova.RegisterValidationMessage("en", "iban", "Field '%s' must be a valid IBAN")
+//	nova.RegisterValidationMessage("nl", "iban", "Veld '%s' moet een geldige IBAN zijn")
+//
+// To register many messages at once, see RegisterMessages and LoadMessages.
+func RegisterValidationMessage(lang, key, template string) {
+	RegisterMessages(lang, map[string]string{key: template})
+}
+
+// checkValidateTag reports an error if the validate tag lists a validator that
+// is not registered.
+func checkValidateTag(tag string) error {
+	for entry := range strings.SplitSeq(tag, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if name == "" {
+			continue
+		}
+		validationMu.RLock()
+		_, ok := validators[name]
+		validationMu.RUnlock()
+		if !ok {
+			return fmt.Errorf("unknown validator %q", name)
+		}
+	}
+	return nil
+}
+
+// runValidators runs the custom validators listed in the validate tag of field
+// f against fv, a field of the struct parent, and returns the violations.
+func runValidators(
+	ctx context.Context,
+	fv, parent reflect.Value,
+	f reflect.StructField,
+	fieldName, lang, custom string,
+) (ValidationErrors, error) {
+	tag := f.Tag.Get("validate")
+	if tag == "" || fv.IsZero() {
+		return nil, nil
+	}
+
+	var errs ValidationErrors
+	for entry := range strings.SplitSeq(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(entry), "=")
+		if name == "" {
+			continue
+		}
+		validationMu.RLock()
+		fn, ok := validators[name]
+		validationMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("field %q: unknown validator %q", fieldName, name)
+		}
+
+		valid, err := fn(ctx, ValidationField{
+			Name:   fieldName,
+			Value:  fv.Interface(),
+			Parent: parent.Interface(),
+			Param:  param,
+			Lang:   lang,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("validator %q for field %q: %w", name, fieldName, err)
+		}
+		if !valid {
+			var params map[string]any
+			var args []any
+			if param != "" {
+				params = map[string]any{"param": param}
+				args = []any{param}
+			}
+			errs = append(errs, newFieldError(fieldName, name, params, lang, custom, args...))
+		}
+	}
+	return errs, nil
+}
+
+// StructValidator is implemented by structs that check rules the struct tags
+// cannot express. Validate runs after the tag checks of the struct, including
+// for nested structs. The errors it returns are added to the ValidationErrors
+// of the tag checks; a returned ValidationErrors or an error built with
+// errors.Join contributes each of its errors. Return a *FieldError to attribute
+// a violation to a field, with Field relative to the struct, e.g. "/end_date".
+type StructValidator interface {
+	Validate() error
+}
+
+// crossFieldRules lists the tags that compare a field with a sibling field, in
+// the order they are checked, with the comparison results that satisfy them.
+var crossFieldRules = []struct {
+	tag  string
+	pass func(order int) bool
+}{
+	{"eqfield", func(order int) bool { return order == 0 }},
+	{"nefield", func(order int) bool { return order != 0 }},
+	{"gtfield", func(order int) bool { return order > 0 }},
+	{"gtefield", func(order int) bool { return order >= 0 }},
+	{"ltfield", func(order int) bool { return order < 0 }},
+	{"ltefield", func(order int) bool { return order <= 0 }},
+}
+
+// isConditional reports whether f has a tag that makes it required or forbidden
+// depending on other fields, which disables the implicit required check.
+func isConditional(f reflect.StructField) bool {
+	for _, tag := range []string{"required_if", "required_with", "excluded_unless"} {
+		if _, ok := f.Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCrossFields applies the tags of field f that refer to other fields
+// of the struct parent, returning every violation. Other fields are referenced
+// by their json or parameter name, or their Go field name. A reference to a
+// field that does not exist is returned as an error.
+func validateCrossFields(
+	fv, parent reflect.Value,
+	f reflect.StructField,
+	fieldName, lang, custom string,
+) (ValidationErrors, error) {
+	var errs ValidationErrors
+	fail := func(code string, params map[string]any, args ...any) {
+		errs = append(errs, newFieldError(fieldName, code, params, lang, custom, args...))
+	}
+
+	if tag, ok := f.Tag.Lookup("required_if"); ok && fv.IsZero() {
+		ref, values, _ := strings.Cut(tag, "=")
+		other, err := siblingField(parent, ref, fieldName)
+		if err != nil {
+			return nil, err
+		}
+		if matchesAny(other, values) {
+			fail("required_if", map[string]any{"field": ref, "values": strings.Split(values, "|")},
+				ref, strings.ReplaceAll(values, "|", ", "))
+		}
+	}
+
+	if tag, ok := f.Tag.Lookup("required_with"); ok && fv.IsZero() {
+		for ref := range strings.SplitSeq(tag, ",") {
+			ref = strings.TrimSpace(ref)
+			other, err := siblingField(parent, ref, fieldName)
+			if err != nil {
+				return nil, err
+			}
+			if !other.IsZero() {
+				fail("required_with", map[string]any{"field": ref}, ref)
+				break
+			}
+		}
+	}
+
+	if fv.IsZero() {
+		return errs, nil
+	}
+
+	if tag, ok := f.Tag.Lookup("excluded_unless"); ok {
+		ref, values, _ := strings.Cut(tag, "=")
+		other, err := siblingField(parent, ref, fieldName)
+		if err != nil {
+			return nil, err
+		}
+		if !matchesAny(other, values) {
+			fail("excluded_unless", map[string]any{"field": ref, "values": strings.Split(values, "|")},
+				ref, strings.ReplaceAll(values, "|", ", "))
+		}
+	}
+
+	for _, rule := range crossFieldRules {
+		ref, ok := f.Tag.Lookup(rule.tag)
+		if !ok {
+			continue
+		}
+		other, err := siblingField(parent, ref, fieldName)
+		if err != nil {
+			return nil, err
+		}
+		order, ok := compareValues(fv, other)
+		if !ok {
+			if rule.tag != "eqfield" && rule.tag != "nefield" {
+				return nil, fmt.Errorf("field %q: %s cannot compare %s with %s", fieldName, rule.tag, fv.Type(), other.Type())
+			}
+			// Values without an order, such as slices, can still be equal.
+			order = 1
+			if reflect.DeepEqual(fv.Interface(), other.Interface()) {
+				order = 0
+			}
+		}
+		if !rule.pass(order) {
+			fail(rule.tag, map[string]any{"field": ref}, ref)
+		}
+	}
+	return errs, nil
+}
+
+// siblingField returns the field of the struct parent named ref, matching its
+// json or parameter name or its Go field name.
+func siblingField(parent reflect.Value, ref, fieldName string) (reflect.Value, error) {
+	for _, f := range reflect.VisibleFields(parent.Type()) {
+		if f.PkgPath != "" {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if _, paramName, _, ok := paramTag(f); ok {
+			name = paramName
+		}
+		if f.Name == ref || name == ref {
+			return parent.FieldByIndex(f.Index), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("field %q: unknown field %q in validation tag", fieldName, ref)
+}
+
+// matchesAny reports whether v, formatted with fmt, equals one of the
+// pipe-separated values. A nil pointer matches nothing.
+func matchesAny(v reflect.Value, values string) bool {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	s := fmt.Sprint(v.Interface())
+	return slices.Contains(strings.Split(values, "|"), s)
+}
+
+// compareValues compares a with b, returning -1, 0 or +1. It reports false if
+// the values are not both numbers, both strings or both times. Pointers are
+// dereferenced; a nil pointer cannot be compared.
+func compareValues(a, b reflect.Value) (int, bool) {
+	for _, v := range []*reflect.Value{&a, &b} {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return 0, false
+			}
+			*v = v.Elem()
+		}
+	}
+
+	if ta, ok := a.Interface().(time.Time); ok {
+		if tb, ok := b.Interface().(time.Time); ok {
+			return ta.Compare(tb), true
+		}
+		return 0, false
+	}
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return strings.Compare(a.String(), b.String()), true
+	}
+	fa, okA := numericValue(a)
+	fb, okB := numericValue(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return cmp.Compare(fa, fb), true
+}
+
+// numericValue returns v as a float64 if it is a number.
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// runStructValidator calls the Validate method of the struct v, if it has one,
+// and returns its errors as violations. Errors other than *FieldError become a
+// FieldError of the struct as a whole with code "validate", wrapping the error.
+func runStructValidator(v reflect.Value) ValidationErrors {
+	target := v.Interface()
+	if v.CanAddr() {
+		target = v.Addr().Interface()
+	}
+	sv, ok := target.(StructValidator)
+	if !ok {
+		return nil
+	}
+	err := sv.Validate()
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		errs = ve
+	} else if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+
+	violations := make(ValidationErrors, len(errs))
+	for i, err := range errs {
+		var fe *FieldError
+		var nested ValidationErrors
+		if errors.As(err, &fe) || errors.As(err, &nested) {
+			violations[i] = err
+		} else {
+			violations[i] = &FieldError{Code: "validate", Message: err.Error(), err: err}
+		}
+	}
+	return violations
+}
+
+// stringLength returns the length of s, counted as the length tag of f says:
+// "runes" (the default) counts Unicode code points, "graphemes" user-perceived
+// characters approximating the grapheme clusters of UAX #29, so "🇳🇱" or "e"
+// followed by a combining accent count as one (see graphemeCount for what is
+// not covered), and "bytes" the bytes of the UTF-8 encoding.
+func stringLength(s string, f reflect.StructField) int {
+	switch f.Tag.Get("length") {
+	case "bytes":
+		return len(s)
+	case "graphemes":
+		return graphemeCount(s)
+	default:
+		return utf8.RuneCountInString(s)
+	}
+}
+
+// sanitizes reports whether f has a trim or normalize tag, so validateStruct
+// rewrites its value with sanitizeString.
+func sanitizes(f reflect.StructField) bool {
+	return f.Tag.Get("trim") == "true" || f.Tag.Get("normalize") != ""
+}
+
+// checkNormalizeTag reports an error if the normalize tag names a form for
+// which no normalizer is registered.
+func checkNormalizeTag(form string) error {
+	if form == "" {
+		return nil
+	}
+	validationMu.RLock()
+	_, ok := normalizers[strings.ToUpper(form)]
+	validationMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no normalizer registered for form %q", form)
+	}
+	return nil
+}
+
+// sanitizeString applies the trim and normalize tags of f to s. trim:"true"
+// removes leading and trailing Unicode white space; normalize applies the
+// normalizer registered for the form, e.g. "NFC". The form was checked by
+// checkTags, so it is registered.
+func sanitizeString(s string, f reflect.StructField) string {
+	if f.Tag.Get("trim") == "true" {
+		s = strings.TrimSpace(s)
+	}
+	form := f.Tag.Get("normalize")
+	if form == "" {
+		return s
+	}
+	validationMu.RLock()
+	fn := normalizers[strings.ToUpper(form)]
+	validationMu.RUnlock()
+	return fn(s)
+}
+
+// onlyRunes reports whether s is non-empty, starts with a rune in one of the
+// tables and contains only such runes and combining marks, so decomposed
+// letters such as "e" followed by U+0301 are accepted.
+func onlyRunes(s string, tables ...*unicode.RangeTable) bool {
+	for i, r := range s {
+		if !unicode.In(r, tables...) && (i == 0 || !unicode.Is(unicode.M, r)) {
+			return false
+		}
+	}
+	return s != ""
+}
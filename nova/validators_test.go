@@ -0,0 +1,515 @@
+package nova
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type takenEmailsKey struct{}
+
+func init() {
+	RegisterValidator("test_even_length", func(ctx context.Context, f ValidationField) (bool, error) {
+		return len(f.Value.(string))%2 == 0, nil
+	})
+	RegisterValidator("test_not_owner", func(ctx context.Context, f ValidationField) (bool, error) {
+		return f.Parent.(validatorAccount).Owner != f.Value.(string), nil
+	})
+	RegisterValidator("test_unique", func(ctx context.Context, f ValidationField) (bool, error) {
+		taken, ok := ctx.Value(takenEmailsKey{}).([]string)
+		if !ok {
+			return false, errors.New("database unavailable")
+		}
+		if f.Param != "users.email" {
+			return false, errors.New("unexpected table " + f.Param)
+		}
+		return !slices.Contains(taken, f.Value.(string)), nil
+	})
+	RegisterValidationMessage("en", "test_even_length", "Field '%s' must have an even length")
+	RegisterValidationMessage("nl", "test_even_length", "Veld '%s' moet een even lengte hebben")
+	RegisterValidationMessage("en", "test_unique", "Field '%s' is already used in %s")
+	// Exercises the normalize hook; real Unicode forms are tested in novanorm.
+	RegisterNormalizer("test-fold", strings.ToLower)
+}
+
+type validatorAccount struct {
+	Code     string             `json:"code" validate:"test_even_length"`
+	Owner    string             `json:"owner,omitempty"`
+	Deputy   string             `json:"deputy,omitempty" validate:"test_not_owner" error:"Deputy must differ from owner"`
+	Email    string             `json:"email,omitempty" validate:"test_unique=users.email"`
+	Contacts []validatorContact `json:"contacts,omitempty"`
+	Primary  *validatorContact  `json:"primary,omitempty"`
+	Skipped  string             `json:"skipped,omitempty" validate:"test_even_length"`
+}
+
+type validatorContact struct {
+	Email string `json:"email" validate:"test_unique=users.email"`
+}
+
+// TestCustomValidators tests custom validators with the parent struct, the
+// request context, tag parameters, translated messages and nested structs.
+func TestCustomValidators(t *testing.T) {
+	r := NewRouter()
+	r.PostFunc("/accounts", func(rc *ResponseContext) error {
+		var a validatorAccount
+		if err := rc.BindValidated(&a); err != nil {
+			return err
+		}
+		return rc.Text(http.StatusOK, "ok")
+	})
+	withTaken := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("X-No-Database") == "" {
+				ctx := context.WithValue(req.Context(), takenEmailsKey{}, []string{"taken@example.com"})
+				req = req.WithContext(ctx)
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+	r.Use(withTaken)
+
+	cases := []struct {
+		name       string
+		body       string
+		header     map[string]string
+		wantStatus int
+		wantErrors []string
+	}{
+		{"valid", `{"code":"ab","owner":"x","deputy":"y","email":"new@example.com"}`, nil, http.StatusOK, nil},
+		{"odd length", `{"code":"abc"}`, nil, http.StatusUnprocessableEntity,
+			[]string{"Field 'code' must have an even length"}},
+		{"translated", `{"code":"abc"}`, map[string]string{"Accept-Language": "nl"}, http.StatusUnprocessableEntity,
+			[]string{"Veld 'code' moet een even lengte hebben"}},
+		{"parent struct and error tag", `{"code":"ab","owner":"x","deputy":"x"}`, nil, http.StatusUnprocessableEntity,
+			[]string{"Deputy must differ from owner"}},
+		{"context and param", `{"code":"ab","email":"taken@example.com"}`, nil, http.StatusUnprocessableEntity,
+			[]string{"Field 'email' is already used in users.email"}},
+		{"nested", `{"code":"ab","contacts":[{"email":"a@example.com"},{"email":"taken@example.com"}],"primary":{"email":"taken@example.com"}}`,
+			nil, http.StatusUnprocessableEntity,
+			[]string{"contacts[1]: Field 'email' is already used in users.email", "Field 'email' is already used in users.email"}},
+		{"validator error", `{"code":"ab","email":"new@example.com"}`, map[string]string{"X-No-Database": "1"},
+			http.StatusInternalServerError, nil},
+		{"nested validator error", `{"code":"ab","primary":{"email":"a@example.com"}}`, map[string]string{"X-No-Database": "1"},
+			http.StatusInternalServerError, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/accounts", strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json")
+			for k, v := range c.header {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != c.wantStatus {
+				t.Fatalf("status = %d; want %d, body %q", rr.Code, c.wantStatus, rr.Body.String())
+			}
+			for _, want := range c.wantErrors {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("body %q does not contain %q", rr.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+// TestUnknownValidator tests that a validate tag naming an unknown validator is
+// reported for every validation of the type, even when the field is empty, and
+// when the type is registered with Handle.
+func TestUnknownValidator(t *testing.T) {
+	type unknownAccount struct {
+		Code  string `json:"code"`
+		Alias string `json:"alias,omitempty" validate:"test_even_length, test_missing=x"`
+	}
+	err := validateStruct(context.Background(), &unknownAccount{Code: "ab"}, "en")
+	if err == nil || errors.As(err, new(ValidationErrors)) || !strings.Contains(err.Error(), `unknown validator "test_missing"`) {
+		t.Errorf("validateStruct() = %v; want the unknown validator", err)
+	}
+
+	type wrapper struct {
+		Links []unknownAccountLink `json:"links"`
+	}
+	if err := validateStruct(context.Background(), &wrapper{}, "en"); err == nil {
+		t.Error("unknown validator of a nested type not reported")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Handle did not panic")
+		}
+	}()
+	Handle(NewRouter(), http.MethodPost, "/accounts", func(rc *ResponseContext, in *unknownAccount) (string, error) {
+		return "", nil
+	})
+}
+
+type unknownAccountLink struct {
+	Target string `json:"target,omitempty" validate:"test_nope"`
+}
+
+// TestRegisterValidatorPanics tests the registration errors of RegisterValidator
+// and RegisterNormalizer.
+func TestRegisterValidatorPanics(t *testing.T) {
+	valid := func(ctx context.Context, f ValidationField) (bool, error) { return true, nil }
+	cases := map[string]func(){
+		"empty name": func() { RegisterValidator("", valid) },
+		"comma":      func() { RegisterValidator("a,b", valid) },
+		"nil func":   func() { RegisterValidator("test_nil", nil) },
+		"duplicate":  func() { RegisterValidator("test_even_length", valid) },
+		"normalizer": func() { RegisterNormalizer("TEST-FOLD", strings.ToUpper) },
+	}
+	for name, fn := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+type crossFieldOrder struct {
+	Password        string    `json:"password"`
+	PasswordConfirm string    `json:"password_confirm" eqfield:"password"`
+	Start           time.Time `json:"start_date"`
+	End             time.Time `json:"end_date,omitempty" gtfield:"start_date"`
+	Quantity        int       `json:"quantity,omitempty"`
+	Reserved        *int      `json:"reserved,omitempty" ltefield:"Quantity" error:"Cannot reserve more than ordered"`
+	Country         string    `json:"country"`
+	VATNumber       string    `json:"vat_number" required_if:"country=AT|BE|DE|NL"`
+	Street          string    `json:"street,omitempty"`
+	City            string    `json:"city,omitempty" required_with:"street"`
+	KVKNumber       string    `json:"kvk_number,omitempty" excluded_unless:"country=NL"`
+	Coupon          string    `json:"coupon,omitempty"`
+}
+
+func (o crossFieldOrder) Validate() error {
+	if o.Coupon == "" || o.Quantity >= 10 {
+		return nil
+	}
+	return errors.Join(errors.New("coupons need at least 10 items"), errors.New("coupon "+o.Coupon+" expired"))
+}
+
+type crossFieldCart struct {
+	Orders []crossFieldOrder `json:"orders"`
+}
+
+func (c *crossFieldCart) Validate() error {
+	if len(c.Orders) > 2 {
+		return errors.New("at most 2 orders per cart")
+	}
+	return nil
+}
+
+// TestCrossFieldValidation tests the tags that compare fields or make them
+// conditionally required, and the Validate hook on structs.
+func TestCrossFieldValidation(t *testing.T) {
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	valid := func() crossFieldOrder {
+		return crossFieldOrder{
+			Password: "secret", PasswordConfirm: "secret",
+			Start: start, End: start.AddDate(0, 0, 7),
+			Country: "US",
+		}
+	}
+	ptr := func(n int) *int { return &n }
+
+	cases := []struct {
+		name   string
+		modify func(o *crossFieldOrder)
+		want   []string
+	}{
+		{"valid", func(o *crossFieldOrder) {}, nil},
+		{"eqfield", func(o *crossFieldOrder) { o.PasswordConfirm = "secrets" },
+			[]string{"Field 'password_confirm' must be equal to 'password'"}},
+		{"gtfield time", func(o *crossFieldOrder) { o.End = start },
+			[]string{"Field 'end_date' must be greater than 'start_date'"}},
+		{"gtfield skips zero", func(o *crossFieldOrder) { o.End = time.Time{} }, nil},
+		{"ltefield pointer and error tag", func(o *crossFieldOrder) { o.Quantity, o.Reserved = 2, ptr(3) },
+			[]string{"Cannot reserve more than ordered"}},
+		{"ltefield equal", func(o *crossFieldOrder) { o.Quantity, o.Reserved = 3, ptr(3) }, nil},
+		{"required_if", func(o *crossFieldOrder) { o.Country = "BE" },
+			[]string{"Field 'vat_number' is required when 'country' is AT, BE, DE, NL"}},
+		{"required_if met", func(o *crossFieldOrder) { o.Country, o.VATNumber = "BE", "BE0123456789" }, nil},
+		{"required_with", func(o *crossFieldOrder) { o.Street = "Main St 1" },
+			[]string{"Field 'city' is required when 'street' is present"}},
+		{"excluded_unless", func(o *crossFieldOrder) { o.KVKNumber = "12345678" },
+			[]string{"Field 'kvk_number' is only allowed when 'country' is NL"}},
+		{"excluded_unless met", func(o *crossFieldOrder) { o.Country, o.VATNumber, o.KVKNumber = "NL", "NL1", "12345678" }, nil},
+		{"validate hook", func(o *crossFieldOrder) { o.Coupon = "SPRING"; o.PasswordConfirm = "" },
+			[]string{"Field 'password_confirm' is required", "coupons need at least 10 items", "coupon SPRING expired"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			o := valid()
+			c.modify(&o)
+			err := validateStruct(context.Background(), &o, "en")
+			var ve ValidationErrors
+			if c.want == nil {
+				if err != nil {
+					t.Fatalf("validateStruct() = %v; want nil", err)
+				}
+				return
+			}
+			if !errors.As(err, &ve) {
+				t.Fatalf("validateStruct() = %v; want ValidationErrors", err)
+			}
+			var got []string
+			for _, e := range ve {
+				got = append(got, e.Error())
+			}
+			if !slices.Equal(got, c.want) {
+				t.Errorf("errors = %q; want %q", got, c.want)
+			}
+		})
+	}
+
+	cart := crossFieldCart{Orders: []crossFieldOrder{valid(), valid(), valid()}}
+	cart.Orders[1].PasswordConfirm = "other"
+	err := validateStruct(context.Background(), &cart, "nl")
+	if err == nil || !strings.Contains(err.Error(), "orders[1]: Veld 'password_confirm' moet gelijk zijn aan 'password'") ||
+		!strings.HasSuffix(err.Error(), "at most 2 orders per cart") {
+		t.Errorf("cart errors = %v", err)
+	}
+
+	var bad struct {
+		A string `json:"a" eqfield:"missing"`
+	}
+	bad.A = "x"
+	if err := validateStruct(context.Background(), &bad, "en"); err == nil || errors.As(err, new(ValidationErrors)) {
+		t.Errorf("unknown field reference: err = %v; want a plain error", err)
+	}
+}
+
+type fieldErrorsProfile struct {
+	Name    string            `json:"name" minlength:"3"`
+	Website string            `json:"home/page,omitempty" format:"url"`
+	Primary *validatorContact `json:"primary,omitempty"`
+	Orders  []crossFieldOrder `json:"orders,omitempty"`
+}
+
+// TestValidationErrorsFields tests the field paths, codes and parameters of
+// violations, their JSON form and that Error is unchanged.
+func TestValidationErrorsFields(t *testing.T) {
+	order := crossFieldOrder{Password: "a", PasswordConfirm: "b", Start: time.Now(), Country: "US", Coupon: "X"}
+	p := fieldErrorsProfile{
+		Name:    "Al",
+		Website: "nope",
+		Primary: &validatorContact{Email: "taken@example.com"},
+		Orders:  []crossFieldOrder{order},
+	}
+	ctx := context.WithValue(context.Background(), takenEmailsKey{}, []string{"taken@example.com"})
+	err := validateStruct(ctx, &p, "en")
+	var ve ValidationErrors
+	if !errors.As(err, &ve) {
+		t.Fatalf("validateStruct() = %v; want ValidationErrors", err)
+	}
+
+	wantError := "Field 'name' must be at least 3 characters long; Field 'home/page' must be a valid URL; " +
+		"Field 'email' is already used in users.email; orders[0]: Field 'password_confirm' must be equal to 'password'; " +
+		"coupons need at least 10 items; coupon X expired"
+	if ve.Error() != wantError {
+		t.Errorf("Error() = %q; want %q", ve.Error(), wantError)
+	}
+
+	var got []string
+	for _, fe := range ve.Fields() {
+		got = append(got, fe.Field+" "+fe.Code)
+	}
+	want := []string{
+		"/name minlength",
+		"/home~1page url",
+		"/primary/email test_unique",
+		"/orders/0/password_confirm eqfield",
+		"/orders/0 validate",
+		"/orders/0 validate",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Fields() = %q; want %q", got, want)
+	}
+	if fields := ve.Fields(); fields[0].Params["limit"] != 3 || fields[2].Params["param"] != "users.email" ||
+		fields[3].Params["field"] != "password" || fields[4].Unwrap() == nil {
+		t.Errorf("params of %+v, %+v, %+v", fields[0], fields[2], fields[3])
+	}
+
+	data, err := json.Marshal(ve)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var byField map[string][]map[string]any
+	if err := json.Unmarshal(data, &byField); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	if len(byField) != 5 || len(byField["/orders/0"]) != 2 ||
+		byField["/name"][0]["code"] != "minlength" ||
+		byField["/name"][0]["message"] != "Field 'name' must be at least 3 characters long" {
+		t.Errorf("MarshalJSON() = %s", data)
+	}
+}
+
+// errSharedLine is returned by every sharedLine, as a package level error
+// would be.
+var errSharedLine = &FieldError{Field: "/sku", Code: "discontinued", Message: "discontinued"}
+
+type sharedLine struct {
+	SKU string `json:"sku"`
+}
+
+func (sharedLine) Validate() error { return ValidationErrors{errSharedLine} }
+
+// TestPrefixFieldsCopies tests that nested field paths are prefixed on copies,
+// leaving a shared *FieldError returned by a Validate method untouched.
+func TestPrefixFieldsCopies(t *testing.T) {
+	type basket struct {
+		Lines []sharedLine `json:"lines"`
+	}
+	for range 2 {
+		err := validateStruct(context.Background(), &basket{Lines: []sharedLine{{"a"}, {"b"}}}, "en")
+		var ve ValidationErrors
+		if !errors.As(err, &ve) {
+			t.Fatalf("validateStruct() = %v; want ValidationErrors", err)
+		}
+		var got []string
+		for _, fe := range ve.Fields() {
+			got = append(got, fe.Field)
+		}
+		if want := []string{"/lines/0/sku", "/lines/1/sku"}; !slices.Equal(got, want) {
+			t.Errorf("Fields() = %q; want %q", got, want)
+		}
+	}
+	if errSharedLine.Field != "/sku" {
+		t.Errorf("shared FieldError changed to %q", errSharedLine.Field)
+	}
+}
+
+type unicodeProfile struct {
+	Name     string `json:"name" maxlength:"10" trim:"true"`
+	NameUTF8 string `json:"name_utf8,omitempty" maxlength:"10" length:"bytes"`
+	Initial  string `json:"initial,omitempty" maxlength:"1" length:"graphemes"`
+	Slug     string `json:"slug,omitempty" maxlength:"3" trim:"true" normalize:"Test-Fold"`
+	Letters  string `json:"letters,omitempty" format:"unicode-alpha"`
+	Digits   string `json:"digits,omitempty" format:"unicode-numeric"`
+	Code     string `json:"code,omitempty" format:"unicode-alphanumeric"`
+	Raw      string `json:"raw,omitempty"`
+}
+
+// TestUnicodeValidation tests length counting in runes, bytes and grapheme
+// clusters, trimming and the normalize hook, and the Unicode character classes.
+func TestUnicodeValidation(t *testing.T) {
+	cases := []struct {
+		name string
+		in   unicodeProfile
+		want []string
+	}{
+		{"CJK within runes", unicodeProfile{Name: "山田太郎花子"}, nil},
+		{"CJK over bytes", unicodeProfile{Name: "a", NameUTF8: "山田太郎"},
+			[]string{"/name_utf8 maxlength"}},
+		{"too many runes", unicodeProfile{Name: "ありがとうございました"}, []string{"/name maxlength"}},
+		{"emoji ZWJ sequence is one grapheme", unicodeProfile{Name: "a", Initial: "👨‍👩‍👧"}, nil},
+		{"flag is one grapheme", unicodeProfile{Name: "a", Initial: "🇳🇱"}, nil},
+		{"combining mark is one grapheme", unicodeProfile{Name: "a", Initial: "e\u0301"}, nil},
+		{"two graphemes", unicodeProfile{Name: "a", Initial: "🇳🇱🇧🇪"}, []string{"/initial maxlength"}},
+		{"trimmed before length", unicodeProfile{Name: "a", Slug: " ABC "}, nil},
+		{"trimmed to empty", unicodeProfile{Name: " \u3000\t"}, []string{"/name required"}},
+		{"letters", unicodeProfile{Name: "a", Letters: "JoséZoëçДжон山田e\u0301"}, nil},
+		{"letters with digit", unicodeProfile{Name: "a", Letters: "Ana1"}, []string{"/letters unicode-alpha"}},
+		{"letters with emoji", unicodeProfile{Name: "a", Letters: "Ana😀"}, []string{"/letters unicode-alpha"}},
+		{"leading combining mark", unicodeProfile{Name: "a", Letters: "\u0301a"}, []string{"/letters unicode-alpha"}},
+		{"digits", unicodeProfile{Name: "a", Digits: "١٢٣४५"}, nil},
+		{"digits with letter", unicodeProfile{Name: "a", Digits: "12a"}, []string{"/digits unicode-numeric"}},
+		{"alphanumeric", unicodeProfile{Name: "a", Code: "Ärger123"}, nil},
+		{"alphanumeric with space", unicodeProfile{Name: "a", Code: "Ärger 123"}, []string{"/code unicode-alphanumeric"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := c.in
+			err := validateStruct(context.Background(), &p, "en")
+			var got []string
+			var ve ValidationErrors
+			if errors.As(err, &ve) {
+				for _, fe := range ve.Fields() {
+					got = append(got, fe.Field+" "+fe.Code)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, c.want) {
+				t.Errorf("violations = %q; want %q", got, c.want)
+			}
+		})
+	}
+
+	r := NewRouter()
+	r.PostFunc("/profiles", func(rc *ResponseContext) error {
+		var p unicodeProfile
+		if err := rc.BindValidated(&p); err != nil {
+			return err
+		}
+		return rc.JSON(http.StatusOK, p)
+	})
+	req := httptest.NewRequest("POST", "/profiles", strings.NewReader(`{"name":"  Rene\u0301e ","slug":" ABC ","raw":" e\u0301 "}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var out unicodeProfile
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("status %d, body %q", rr.Code, rr.Body.String())
+	}
+	if out.Name != "Rene\u0301e" || out.Slug != "abc" || out.Raw != " e\u0301 " {
+		t.Errorf("bound values = %+q, %+q, %+q; want trimmed, trimmed and normalized, untouched",
+			out.Name, out.Slug, out.Raw)
+	}
+
+}
+
+// TestUnregisteredNormalizer tests that a normalize tag naming an unregistered
+// form is reported once per type and makes Handle panic.
+func TestUnregisteredNormalizer(t *testing.T) {
+	type unknownForm struct {
+		Name string `json:"name,omitempty" normalize:"NFX"`
+	}
+	if err := checkTags(reflect.TypeFor[unknownForm]()); err == nil || !strings.Contains(err.Error(), `form "NFX"`) {
+		t.Errorf("checkTags() = %v; want the unregistered form", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Handle did not panic")
+		}
+	}()
+	Handle(NewRouter(), http.MethodPost, "/names", func(rc *ResponseContext, in *unknownForm) (string, error) {
+		return "", nil
+	})
+}
+
+// TestGraphemeCount tests grapheme cluster counting.
+func TestGraphemeCount(t *testing.T) {
+	cases := map[string]int{
+		"":                   0,
+		"abc":                3,
+		"\r\n":               1,
+		"e\u0301\u0302":      1,
+		"🇳🇱🇧🇪🇫":              3,
+		"👨\u200d👩\u200d👧":    1,
+		"👍🏽":                 1,
+		"a\u200d👩":           2,
+		"한국어":                3,
+		"\u1100\u1161\u11a8": 1,
+		"कि":                 1,
+		"\u0600a":            1,
+	}
+	for s, want := range cases {
+		if got := graphemeCount(s); got != want {
+			t.Errorf("graphemeCount(%+q) = %d; want %d", s, got, want)
+		}
+	}
+}
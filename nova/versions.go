@@ -0,0 +1,377 @@
+package nova
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersioningConfig configures where Router.Versions reads the requested API
+// version from. At least one source must be set.
+type VersioningConfig struct {
+	// PathPrefix serves each version below a path segment made of the prefix
+	// and the version name, e.g. "v" serves version "2" under "/v2". When set,
+	// the path alone selects the version and Header and AcceptParameter are
+	// ignored.
+	PathPrefix string
+	// Header names a request header carrying the version, e.g. "API-Version".
+	Header string
+	// AcceptParameter names a media type parameter of the Accept header
+	// carrying the version, e.g. "version" for "application/json; version=2".
+	// It is consulted after Header.
+	AcceptParameter string
+	// Default is the version used for requests that do not name one when the
+	// version is read from a header. Defaults to the latest version.
+	Default string
+}
+
+// VersionOptions holds the lifecycle of an API version. Responses of routes
+// served to requests for a deprecated version carry the Deprecation, Sunset
+// and Link headers of RFC 9745 and RFC 8594.
+type VersionOptions struct {
+	// Deprecation is the date the version was deprecated. A non-zero date
+	// marks the version, and its operations in the OpenAPI spec, as deprecated.
+	Deprecation time.Time
+	// Sunset is the date after which the version may stop being served.
+	Sunset time.Time
+	// Link is the URL of a document describing the deprecation, e.g. a
+	// migration guide.
+	Link string
+}
+
+// Versions serves several versions of an API side by side. Each version is a
+// subrouter created with Version. A request is served by the version it asks
+// for and falls back to earlier versions for routes that version does not
+// define, so a new version only registers the endpoints that changed.
+type Versions struct {
+	// config holds the version sources.
+	config VersioningConfig
+	// parent is the router Versions was called on.
+	parent *Router
+	// router is the subrouter that dispatches to the version routers.
+	router *Router
+	// versions contains the versions in registration order, oldest first.
+	versions []*apiVersion
+	// prefixIndex is the index of the path segment holding the version when
+	// PathPrefix is set.
+	prefixIndex int
+}
+
+// apiVersion is a single version registered with Versions.Version.
+type apiVersion struct {
+	// name is the version name, e.g. "2".
+	name string
+	// options holds the deprecation dates and link.
+	options VersionOptions
+	// router serves the routes registered for this version.
+	router *Router
+	// set is the Versions the version belongs to.
+	set *Versions
+}
+
+// deprecated reports whether the version is deprecated.
+func (v *apiVersion) deprecated() bool {
+	return !v.options.Deprecation.IsZero()
+}
+
+// Versions returns a set of API versions mounted on the router. The version of
+// a request is read from the sources configured in config. It panics if no
+// source is configured.
+//
+//	versions := router.Versions(nova.VersioningConfig{PathPrefix: "v"})
+//	v1 := versions.Version("1", &nova.VersionOptions{Deprecation: deprecated})
+//	v1.GetFunc("/users", listUsersV1)
+//	v1.GetFunc("/users/{id}", getUser)
+//	v2 := versions.Version("2")
+//	v2.GetFunc("/users", listUsersV2) // GET /v2/users/{id} is served by v1
+func (r *Router) Versions(config VersioningConfig) *Versions {
+	if config.PathPrefix == "" && config.Header == "" && config.AcceptParameter == "" {
+		panic("Versions: no version source configured")
+	}
+	config.Header = http.CanonicalHeaderKey(config.Header)
+	v := &Versions{
+		config:      config,
+		parent:      r,
+		router:      r.Subrouter(""),
+		prefixIndex: len(splitPath(r.basePath)),
+	}
+	v.router.versions = v
+	return v
+}
+
+// Version registers a version and returns the subrouter its routes are added
+// to. Versions must be registered from oldest to newest, as requests fall back
+// to the versions registered before the one they ask for. It panics if the
+// name is empty, contains a slash or is already registered.
+func (v *Versions) Version(name string, opts ...*VersionOptions) *Router {
+	if name == "" || strings.Contains(name, "/") {
+		panic(fmt.Sprintf("Versions: invalid version name %q", name))
+	}
+	if v.lookup(name) >= 0 {
+		panic(fmt.Sprintf("Versions: duplicate version %q", name))
+	}
+	prefix := ""
+	if v.config.PathPrefix != "" {
+		prefix = v.config.PathPrefix + name
+	}
+	ver := &apiVersion{name: name, router: v.router.Subrouter(prefix), set: v}
+	if len(opts) > 0 && opts[0] != nil {
+		ver.options = *opts[0]
+	}
+	ver.router.version = ver
+	v.versions = append(v.versions, ver)
+	return ver.router
+}
+
+// lookup returns the index of the named version, or -1.
+func (v *Versions) lookup(name string) int {
+	return slices.IndexFunc(v.versions, func(ver *apiVersion) bool { return ver.name == name })
+}
+
+// requested returns the index of the version req asks for, or -1 if it names
+// an unknown version or, with PathPrefix, none at all.
+func (v *Versions) requested(req *http.Request, parts []string) int {
+	if v.config.PathPrefix != "" {
+		if len(parts) <= v.prefixIndex {
+			return -1
+		}
+		name, ok := strings.CutPrefix(parts[v.prefixIndex], v.config.PathPrefix)
+		if !ok {
+			return -1
+		}
+		return v.lookup(name)
+	}
+	if v.config.Header != "" {
+		if name := strings.TrimSpace(req.Header.Get(v.config.Header)); name != "" {
+			return v.lookup(name)
+		}
+	}
+	if v.config.AcceptParameter != "" {
+		if name := acceptParameter(req.Header.Values("Accept"), v.config.AcceptParameter); name != "" {
+			return v.lookup(name)
+		}
+	}
+	if v.config.Default != "" {
+		return v.lookup(v.config.Default)
+	}
+	return len(v.versions) - 1
+}
+
+// acceptParameter returns the value of the named parameter of the first media
+// range in the Accept header values that has it, or "".
+func acceptParameter(values []string, name string) string {
+	for _, value := range values {
+		for part := range strings.SplitSeq(value, ",") {
+			for _, param := range strings.Split(part, ";")[1:] {
+				key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if strings.EqualFold(strings.TrimSpace(key), name) {
+					return strings.Trim(strings.TrimSpace(val), `"`)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// partsFor returns parts with the version segment replaced by that of version
+// i, so that a route of an earlier version matches a request for a later one.
+func (v *Versions) partsFor(i int, parts []string) []string {
+	if v.config.PathPrefix == "" {
+		return parts
+	}
+	p := slices.Clone(parts)
+	p[v.prefixIndex] = v.config.PathPrefix + v.versions[i].name
+	return p
+}
+
+// dispatch serves req from the requested version or, if that version has no
+// route for it, from the latest earlier version that has.
+func (v *Versions) dispatch(w http.ResponseWriter, req *http.Request, parts []string) (bool, *Router, bool) {
+	idx := v.requested(req, parts)
+	if idx < 0 {
+		return false, v.router, false
+	}
+	req = req.WithContext(context.WithValue(req.Context(), apiVersionKey, v.versions[idx]))
+
+	var notFound, mismatch *Router
+	for i := idx; i >= 0; i-- {
+		served, owner, methodMismatch := v.versions[i].router.dispatch(w, req, v.partsFor(i, parts))
+		if served {
+			return true, nil, false
+		}
+		if methodMismatch {
+			if mismatch == nil {
+				mismatch = owner
+			}
+		} else if notFound == nil {
+			notFound = owner
+		}
+	}
+	if mismatch != nil {
+		return false, mismatch, true
+	}
+	return false, notFound, false
+}
+
+// walk calls fn for the versions a request falls back to, with the path parts
+// as that version sees them.
+func (v *Versions) walk(req *http.Request, parts []string, fn func(r *Router, parts []string)) {
+	for i := v.requested(req, parts); i >= 0; i-- {
+		fn(v.versions[i].router, v.partsFor(i, parts))
+	}
+}
+
+// versionHeaders sets the deprecation headers of the requested version and,
+// when the version is read from request headers, the Vary header.
+func (v *Versions) versionHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ver, ok := req.Context().Value(apiVersionKey).(*apiVersion); ok && ver.set == v {
+			h := w.Header()
+			if v.config.PathPrefix == "" {
+				if v.config.Header != "" {
+					h.Add("Vary", v.config.Header)
+				}
+				if v.config.AcceptParameter != "" {
+					h.Add("Vary", "Accept")
+				}
+			}
+			if ver.deprecated() {
+				h.Set("Deprecation", "@"+strconv.FormatInt(ver.options.Deprecation.Unix(), 10))
+			}
+			if !ver.options.Sunset.IsZero() {
+				h.Set("Sunset", ver.options.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if ver.options.Link != "" && ver.deprecated() {
+				h.Add("Link", "<"+ver.options.Link+`>; rel="deprecation"`)
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// GetAPIVersion returns the API version the request asked for, or "" if the
+// request was not routed through Router.Versions. The route serving it may
+// belong to an earlier version.
+func GetAPIVersion(ctx context.Context) string {
+	if ver, ok := ctx.Value(apiVersionKey).(*apiVersion); ok {
+		return ver.name
+	}
+	return ""
+}
+
+// APIVersion returns the API version the request asked for. See GetAPIVersion.
+func (rc *ResponseContext) APIVersion() string {
+	return GetAPIVersion(rc.r.Context())
+}
+
+// effectiveRoutes calls fn for every route serving version i: its own routes
+// and those of earlier versions it does not redefine. With PathPrefix, routes
+// of earlier versions are moved below the prefix of version i.
+func (v *Versions) effectiveRoutes(i int, fn func(rt *route, conds []*matcher)) {
+	defined := make(map[string]bool)
+	for j := i; j >= 0; j-- {
+		own := make(map[string]bool)
+		walkRoutes(v.versions[j].router, nil, func(_ *Router, rt *route, conds []*matcher) {
+			if j != i && v.config.PathPrefix != "" {
+				rt = v.rebase(rt, i)
+			}
+			key := routeKey(rt, conds)
+			if defined[key] {
+				return
+			}
+			own[key] = true
+			fn(rt, conds)
+		})
+		for key := range own {
+			defined[key] = true
+		}
+	}
+}
+
+// rebase returns a copy of rt with its version segment replaced by that of
+// version i.
+func (v *Versions) rebase(rt *route, i int) *route {
+	c := *rt
+	c.segments = slices.Clone(rt.segments)
+	c.segments[v.prefixIndex] = segment{literal: v.config.PathPrefix + v.versions[i].name}
+	c.pattern = buildPathString(c.segments)
+	return &c
+}
+
+// GenerateVersionedOpenAPISpecs generates one OpenAPI specification per
+// version, keyed by version name. The spec of a version documents the routes it
+// serves, including those inherited from earlier versions, with the version
+// name as info.version. Operations of deprecated versions are marked as
+// deprecated, and a version header is documented as a header parameter.
+func GenerateVersionedOpenAPISpecs(v *Versions, config OpenAPIConfig) map[string]*OpenAPI {
+	def := len(v.versions) - 1
+	if v.config.Default != "" {
+		def = v.lookup(v.config.Default)
+	}
+	specs := make(map[string]*OpenAPI, len(v.versions))
+	for i, ver := range v.versions {
+		cfg := config
+		cfg.Version = ver.name
+		spec := newOpenAPISpec(cfg, func(spec *OpenAPI, schemaCtx *schemaGenCtx) {
+			v.effectiveRoutes(i, func(rt *route, conds []*matcher) {
+				addRoute(spec, schemaCtx, rt, conds)
+			})
+		})
+		for _, item := range spec.Paths {
+			for _, op := range []*Operation{item.Get, item.Post, item.Put, item.Delete, item.Patch} {
+				if op == nil {
+					continue
+				}
+				if ver.deprecated() {
+					op.Deprecated = true
+				}
+				if v.config.PathPrefix == "" && v.config.Header != "" {
+					op.Parameters = append(op.Parameters, ParameterObject{
+						Name:     v.config.Header,
+						In:       "header",
+						Required: i != def,
+						Schema:   &SchemaObject{Type: "string", Enum: []string{ver.name}},
+					})
+				}
+			}
+		}
+		specs[ver.name] = spec
+	}
+	return specs
+}
+
+// ServeOpenAPISpecs serves the specification of every version on the parent
+// router at pattern, which must contain a {version} parameter, e.g.
+// "/openapi/{version}.json".
+func (v *Versions) ServeOpenAPISpecs(pattern string, config OpenAPIConfig) {
+	if !strings.Contains(pattern, "{version}") {
+		panic(fmt.Sprintf("ServeOpenAPISpecs: pattern %q has no {version} parameter", pattern))
+	}
+	specs := make(map[string][]byte)
+	for name, spec := range GenerateVersionedOpenAPISpecs(v, config) {
+		specJSON, err := json.MarshalIndent(spec, "", "  ")
+		if err != nil {
+			panic(fmt.Sprintf("Failed to marshal OpenAPI spec: %v", err))
+		}
+		specs[name] = specJSON
+	}
+
+	v.parent.Handle(http.MethodGet, pattern, func(w http.ResponseWriter, req *http.Request) {
+		specJSON, ok := specs[v.parent.URLParam(req, "version")]
+		if !ok {
+			v.parent.notFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(specJSON)
+	})
+
+	slog.Info("OpenAPI specifications served", "pattern", pattern, "versions", len(specs))
+}
@@ -0,0 +1,229 @@
+package nova
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestVersionsPathPrefix tests that versions are selected by path prefix and
+// fall back to earlier versions for routes they do not define.
+func TestVersionsPathPrefix(t *testing.T) {
+	text := func(body string) HandlerFunc {
+		return func(rc *ResponseContext) error {
+			return rc.Text(http.StatusOK, body+" "+rc.APIVersion()+" "+rc.URLParam("id"))
+		}
+	}
+	r := NewRouter()
+	api := r.Subrouter("/api")
+	versions := api.Versions(VersioningConfig{PathPrefix: "v"})
+	v1 := versions.Version("1")
+	v1.GetFunc("/users", text("users v1"))
+	v1.GetFunc("/users/{id}", text("user v1"))
+	v1.DeleteFunc("/users/{id}", text("delete v1"))
+	v2 := versions.Version("2")
+	v2.GetFunc("/users", text("users v2"))
+	v2.PutFunc("/users/{id}", text("put v2"))
+	versions.Version("3")
+
+	cases := []struct {
+		method, path string
+		wantStatus   int
+		wantBody     string
+	}{
+		{"GET", "/api/v1/users", http.StatusOK, "users v1 1 "},
+		{"GET", "/api/v2/users", http.StatusOK, "users v2 2 "},
+		{"GET", "/api/v3/users", http.StatusOK, "users v2 3 "},
+		{"GET", "/api/v2/users/7", http.StatusOK, "user v1 2 7"},
+		{"DELETE", "/api/v3/users/7", http.StatusOK, "delete v1 3 7"},
+		{"PUT", "/api/v2/users/7", http.StatusOK, "put v2 2 7"},
+		{"PUT", "/api/v1/users/7", http.StatusMethodNotAllowed, ""},
+		{"GET", "/api/v4/users", http.StatusNotFound, ""},
+		{"GET", "/api/users", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(c.method, c.path, nil))
+		if rr.Code != c.wantStatus {
+			t.Errorf("%s %s status = %d; want %d", c.method, c.path, rr.Code, c.wantStatus)
+		}
+		if c.wantBody != "" && rr.Body.String() != c.wantBody {
+			t.Errorf("%s %s body = %q; want %q", c.method, c.path, rr.Body.String(), c.wantBody)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v2/users/7", nil))
+	if allow := rr.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS, PUT" {
+		t.Errorf("Allow = %q; want the methods of v2 and v1", allow)
+	}
+}
+
+// TestVersionsHeaderAndAccept tests selecting the version from a header or an
+// Accept parameter, the default version and the Vary header.
+func TestVersionsHeaderAndAccept(t *testing.T) {
+	r := NewRouter()
+	versions := r.Versions(VersioningConfig{Header: "api-version", AcceptParameter: "version", Default: "1"})
+	versions.Version("1").GetFunc("/items", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "items v1 "+rc.APIVersion())
+	})
+	versions.Version("2").GetFunc("/orders", func(rc *ResponseContext) error {
+		return rc.Text(http.StatusOK, "orders v2")
+	})
+
+	cases := []struct {
+		name       string
+		path       string
+		header     http.Header
+		wantStatus int
+		wantBody   string
+	}{
+		{"default", "/items", nil, http.StatusOK, "items v1 1"},
+		{"default lacks route", "/orders", nil, http.StatusNotFound, ""},
+		{"header", "/orders", http.Header{"Api-Version": {"2"}}, http.StatusOK, "orders v2"},
+		{"header fallback", "/items", http.Header{"Api-Version": {"2"}}, http.StatusOK, "items v1 2"},
+		{"accept parameter", "/orders", http.Header{"Accept": {"text/html, application/json; version=\"2\""}}, http.StatusOK, "orders v2"},
+		{"header before accept", "/orders", http.Header{"Api-Version": {"1"}, "Accept": {"application/json; version=2"}}, http.StatusNotFound, ""},
+		{"unknown version", "/items", http.Header{"Api-Version": {"9"}}, http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", c.path, nil)
+			for k, v := range c.header {
+				req.Header[k] = v
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			if rr.Code != c.wantStatus {
+				t.Errorf("status = %d; want %d", rr.Code, c.wantStatus)
+			}
+			if c.wantBody != "" && rr.Body.String() != c.wantBody {
+				t.Errorf("body = %q; want %q", rr.Body.String(), c.wantBody)
+			}
+			if c.wantStatus == http.StatusOK && len(rr.Header().Values("Vary")) != 2 {
+				t.Errorf("Vary = %q; want Api-Version and Accept", rr.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+// TestVersionsDeprecation tests that routes served to requests for a deprecated
+// version carry the Deprecation, Sunset and Link headers.
+func TestVersionsDeprecation(t *testing.T) {
+	deprecated := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := func(w http.ResponseWriter, req *http.Request) {}
+
+	r := NewRouter()
+	versions := r.Versions(VersioningConfig{PathPrefix: "v"})
+	v1 := versions.Version("1", &VersionOptions{Deprecation: deprecated, Sunset: sunset, Link: "https://example.com/migrate"})
+	v1.Get("/users", h)
+	v1.Subrouter("/admin").Get("/stats", h)
+	versions.Version("2")
+
+	for _, path := range []string{"/v1/users", "/v1/admin/stats"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if got := rr.Header().Get("Deprecation"); got != "@1735689600" {
+			t.Errorf("%s Deprecation = %q", path, got)
+		}
+		if got := rr.Header().Get("Sunset"); got != "Thu, 01 Jan 2026 00:00:00 GMT" {
+			t.Errorf("%s Sunset = %q", path, got)
+		}
+		if got := rr.Header().Get("Link"); got != `<https://example.com/migrate>; rel="deprecation"` {
+			t.Errorf("%s Link = %q", path, got)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/v2/users", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Deprecation") != "" || rr.Header().Get("Sunset") != "" {
+		t.Errorf("v2 fallback: status %d, headers %v", rr.Code, rr.Header())
+	}
+}
+
+// TestVersionsRoutesAndOpenAPI tests that Routes reports the version of each
+// route and that every version gets its own spec with the inherited routes.
+func TestVersionsRoutesAndOpenAPI(t *testing.T) {
+	h := func(w http.ResponseWriter, req *http.Request) {}
+	r := NewRouter()
+	versions := r.Versions(VersioningConfig{PathPrefix: "v"})
+	v1 := versions.Version("1", &VersionOptions{Deprecation: time.Now()})
+	v1.Get("/users", h, &RouteOptions{OperationID: "listUsersV1"})
+	v1.Get("/users/{id}", h)
+	v2 := versions.Version("2")
+	v2.Get("/users", h, &RouteOptions{OperationID: "listUsersV2"})
+
+	for _, rt := range r.Routes() {
+		if rt.Shadowed {
+			t.Errorf("%s %s marked as shadowed", rt.Method, rt.Pattern)
+		}
+		if want := rt.Pattern[2:3]; rt.Version != want {
+			t.Errorf("%s version = %q; want %q", rt.Pattern, rt.Version, want)
+		}
+	}
+
+	specs := GenerateVersionedOpenAPISpecs(versions, OpenAPIConfig{Title: "t"})
+	if len(specs) != 2 || specs["1"].Info.Version != "1" || specs["2"].Info.Version != "2" {
+		t.Fatalf("specs = %+v", specs)
+	}
+	v2spec := specs["2"]
+	if len(v2spec.Paths) != 2 || v2spec.Paths["/v2/users"].Get.OperationID != "listUsersV2" ||
+		v2spec.Paths["/v2/users/{id}"] == nil || v2spec.Paths["/v2/users/{id}"].Get.Deprecated {
+		t.Errorf("v2 paths = %+v", v2spec.Paths)
+	}
+	if op := specs["1"].Paths["/v1/users"].Get; op.OperationID != "listUsersV1" || !op.Deprecated {
+		t.Errorf("v1 operation = %+v", op)
+	}
+
+	hr := NewRouter()
+	hv := hr.Versions(VersioningConfig{Header: "API-Version"})
+	hv.Version("1").Get("/items", h)
+	hv.Version("2")
+	for name, spec := range GenerateVersionedOpenAPISpecs(hv, OpenAPIConfig{Title: "t"}) {
+		params := spec.Paths["/items"].Get.Parameters
+		if len(params) != 1 {
+			t.Fatalf("version %s parameters = %+v", name, params)
+		}
+		if enum, _ := params[0].Schema.Enum.([]string); params[0].Name != "Api-Version" || len(enum) != 1 || enum[0] != name ||
+			params[0].Required != (name == "1") {
+			t.Errorf("version %s parameters = %+v", name, params)
+		}
+	}
+
+	hv.ServeOpenAPISpecs("/openapi/{version}.json", OpenAPIConfig{Title: "t"})
+	for path, want := range map[string]int{"/openapi/2.json": http.StatusOK, "/openapi/3.json": http.StatusNotFound} {
+		rr := httptest.NewRecorder()
+		hr.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != want {
+			t.Errorf("GET %s status = %d; want %d", path, rr.Code, want)
+		}
+	}
+}
+
+// TestVersionsPanics tests the registration errors of Versions.
+func TestVersionsPanics(t *testing.T) {
+	cases := map[string]func(){
+		"no source": func() { NewRouter().Versions(VersioningConfig{}) },
+		"duplicate": func() {
+			v := NewRouter().Versions(VersioningConfig{PathPrefix: "v"})
+			v.Version("1")
+			v.Version("1")
+		},
+		"slash": func() { NewRouter().Versions(VersioningConfig{PathPrefix: "v"}).Version("1/2") },
+		"no param": func() {
+			NewRouter().Versions(VersioningConfig{PathPrefix: "v"}).ServeOpenAPISpecs("/openapi.json", OpenAPIConfig{})
+		},
+	}
+	for name, fn := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
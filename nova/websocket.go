@@ -0,0 +1,586 @@
+package nova
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket message types.
+const (
+	WebSocketText   = 1
+	WebSocketBinary = 2
+)
+
+// WebSocket close codes defined by RFC 6455.
+const (
+	WebSocketCloseNormal          = 1000
+	WebSocketCloseGoingAway       = 1001
+	WebSocketCloseProtocolError   = 1002
+	WebSocketCloseUnsupportedData = 1003
+	WebSocketCloseNoStatus        = 1005
+	WebSocketCloseInvalidPayload  = 1007
+	WebSocketClosePolicyViolation = 1008
+	WebSocketCloseMessageTooBig   = 1009
+	WebSocketCloseInternalError   = 1011
+)
+
+// Frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// webSocketGUID is appended to the client key to compute Sec-WebSocket-Accept.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	defaultWebSocketMaxMessageSize = 1 << 20
+	defaultWebSocketWriteTimeout   = 10 * time.Second
+)
+
+// ErrWebSocketClosed is returned when reading from or writing to a WebSocket
+// connection that has been closed.
+var ErrWebSocketClosed = errors.New("nova: websocket connection closed")
+
+// WebSocketConfig holds configuration for WebSocket connections. See
+// Router.SetWebSocketConfig.
+type WebSocketConfig struct {
+	// CheckOrigin reports whether a handshake with the request's Origin header is
+	// allowed. If nil, AllowedOrigins is used.
+	CheckOrigin func(r *http.Request) bool
+	// AllowedOrigins lists the origins, e.g. "https://example.com", that may open
+	// connections. "*" allows any origin. If empty, only requests without an Origin
+	// header or from the same host are accepted.
+	AllowedOrigins []string
+	// Subprotocols lists the supported subprotocols in order of preference. The
+	// first one in this list that the client offers is selected, regardless of
+	// the order of the client's offer.
+	Subprotocols []string
+	// MaxMessageSize limits the size of a received message in bytes. Larger messages
+	// close the connection with code 1009. Defaults to 1 MB.
+	MaxMessageSize int64
+	// WriteTimeout limits the time a single write may take. Defaults to 10 seconds.
+	WriteTimeout time.Duration
+	// PingInterval enables sending a ping at this interval. A connection that
+	// receives nothing, not even a pong, for twice the interval is closed. Pongs
+	// are only processed while the handler reads messages.
+	PingInterval time.Duration
+}
+
+// WebSocketHandler handles an upgraded WebSocket connection. The connection is
+// closed with code 1000 when the handler returns nil, or with code 1011 when it
+// returns an error.
+type WebSocketHandler func(conn *WebSocketConn) error
+
+// WebSocketCloseError is returned by ReadMessage when the connection is closed
+// with a close frame, either by the peer or because a protocol rule was violated.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *WebSocketCloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket closed with code %d: %s", e.Code, e.Reason)
+}
+
+// WebSocketConn is an upgraded WebSocket connection. One goroutine may read
+// while others write; writes are serialized.
+type WebSocketConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	req         *http.Request
+	subprotocol string
+	config      WebSocketConfig
+
+	wmu       sync.Mutex
+	bw        *bufio.Writer
+	closeSent bool
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// SetWebSocketConfig sets the configuration used by WebSocket routes and
+// ResponseContext.UpgradeWebSocket. Subrouters created afterwards inherit it.
+func (r *Router) SetWebSocketConfig(config WebSocketConfig) {
+	r.webSocket = config
+}
+
+// WebSocket registers a WebSocket endpoint for GET requests matching pattern. The
+// request is upgraded using the router's WebSocketConfig before handler runs.
+// Failed handshakes are passed to the error handler: 400 for requests that are not
+// a WebSocket handshake, 403 for a rejected origin and 426 for an unsupported
+// protocol version.
+func (r *Router) WebSocket(pattern string, handler WebSocketHandler, opts ...*RouteOptions) {
+	r.GetFunc(pattern, webSocketHandlerFunc(handler), opts...)
+}
+
+// WebSocket registers a WebSocket endpoint within the group. See Router.WebSocket.
+func (g *Group) WebSocket(pattern string, handler WebSocketHandler, opts ...*RouteOptions) {
+	g.GetFunc(pattern, webSocketHandlerFunc(handler), opts...)
+}
+
+// webSocketHandlerFunc adapts a WebSocketHandler to an enhanced handler.
+func webSocketHandlerFunc(handler WebSocketHandler) HandlerFunc {
+	return func(rc *ResponseContext) error {
+		conn, err := rc.UpgradeWebSocket()
+		if err != nil {
+			return err
+		}
+		return handler(conn)
+	}
+}
+
+// UpgradeWebSocket performs the RFC 6455 handshake and takes over the connection.
+// The response must not have been written yet. Handshake errors are returned as an
+// *HTTPError without upgrading. Once upgraded, errors returned by the handler are
+// no longer passed to the error handler; the connection is closed with code 1011
+// and the error is logged. The connection is closed when the handler returns.
+func (rc *ResponseContext) UpgradeWebSocket() (*WebSocketConn, error) {
+	config := rc.router.webSocket
+	if config.MaxMessageSize <= 0 {
+		config.MaxMessageSize = defaultWebSocketMaxMessageSize
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaultWebSocketWriteTimeout
+	}
+
+	req := rc.r
+	if req.Method != http.MethodGet || !isWebSocketUpgrade(req) {
+		return nil, NewHTTPError(http.StatusBadRequest, "not a websocket handshake")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		rc.w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, NewHTTPError(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, NewHTTPError(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	if !config.originAllowed(req) {
+		return nil, NewHTTPError(http.StatusForbidden, "origin not allowed")
+	}
+	subprotocol := selectSubprotocol(req, config.Subprotocols)
+
+	netConn, brw, err := http.NewResponseController(rc.w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("nova: websocket upgrade: %w", err)
+	}
+
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	resp.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n")
+	if subprotocol != "" {
+		resp.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	resp.WriteString("\r\n")
+
+	netConn.SetDeadline(time.Time{})
+	netConn.SetWriteDeadline(time.Now().Add(config.WriteTimeout))
+	if _, err := brw.WriteString(resp.String()); err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, fmt.Errorf("nova: websocket upgrade: %w", err)
+	}
+
+	c := &WebSocketConn{
+		conn:        netConn,
+		br:          brw.Reader,
+		bw:          brw.Writer,
+		req:         req,
+		subprotocol: subprotocol,
+		config:      config,
+		done:        make(chan struct{}),
+	}
+	rc.ws = c
+	if config.PingInterval > 0 {
+		go c.pingLoop()
+	}
+	return c, nil
+}
+
+// Request returns the request that was upgraded, e.g. to read URL parameters.
+func (c *WebSocketConn) Request() *http.Request {
+	return c.req
+}
+
+// Subprotocol returns the negotiated subprotocol, or "" if none was selected.
+func (c *WebSocketConn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (c *WebSocketConn) Done() <-chan struct{} {
+	return c.done
+}
+
+// ReadMessage reads the next text or binary message, reassembling fragmented
+// messages. Pings are answered automatically. When the peer closes the connection,
+// the close frame is echoed and a *WebSocketCloseError with the peer's code is
+// returned. Protocol violations and messages larger than MaxMessageSize close the
+// connection and return a *WebSocketCloseError with the code that was sent.
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		fin, op, payload, err := c.readFrame(c.config.MaxMessageSize - int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, c.handleClose(payload)
+		case opText, opBinary:
+			if messageType != 0 {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "expected continuation frame")
+			}
+			messageType = int(op)
+			data = payload
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "unexpected continuation frame")
+			}
+			data = append(data, payload...)
+		default:
+			return 0, nil, c.fail(WebSocketCloseProtocolError, "unknown opcode")
+		}
+
+		if fin {
+			if messageType == WebSocketText && !utf8.Valid(data) {
+				return 0, nil, c.fail(WebSocketCloseInvalidPayload, "invalid UTF-8")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (c *WebSocketConn) ReadJSON(v any) error {
+	_, data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends data as a single text or binary message.
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WebSocketText && messageType != WebSocketBinary {
+		return fmt.Errorf("nova: invalid websocket message type %d", messageType)
+	}
+	return c.writeFrame(byte(messageType), data)
+}
+
+// WriteJSON encodes v as JSON and sends it as a text message.
+func (c *WebSocketConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(opText, data)
+}
+
+// Ping sends a ping with the given application data of at most 125 bytes.
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > 125 {
+		return errors.New("nova: websocket ping data too long")
+	}
+	return c.writeFrame(opPing, data)
+}
+
+// Close sends a close frame with the given code and reason and closes the
+// connection. Closing an already closed connection does nothing.
+func (c *WebSocketConn) Close(code int, reason string) error {
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+
+	err := c.writeFrame(opClose, payload)
+	if errors.Is(err, ErrWebSocketClosed) {
+		err = nil
+	}
+	c.closeConn()
+	return err
+}
+
+// finish closes the connection after the handler returned err. It reports whether
+// err should be logged.
+func (c *WebSocketConn) finish(err error) bool {
+	if err == nil {
+		c.Close(WebSocketCloseNormal, "")
+		return false
+	}
+	c.Close(WebSocketCloseInternalError, "")
+	var ce *WebSocketCloseError
+	return !errors.As(err, &ce) && !errors.Is(err, ErrWebSocketClosed) && !errors.Is(err, net.ErrClosed)
+}
+
+// readFrame reads a single frame. limit is the remaining message size for data
+// frames.
+func (c *WebSocketConn) readFrame(limit int64) (fin bool, op byte, payload []byte, err error) {
+	if c.config.PingInterval > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.config.PingInterval))
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, c.readError(err)
+	}
+	fin = header[0]&0x80 != 0
+	op = header[0] & 0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(WebSocketCloseProtocolError, "reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(WebSocketCloseProtocolError, "client frame not masked")
+	}
+
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, c.readError(err)
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, c.readError(err)
+		}
+		if ext[0]&0x80 != 0 {
+			return false, 0, nil, c.fail(WebSocketCloseProtocolError, "invalid frame length")
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	if op >= opClose {
+		if !fin || length > 125 {
+			return false, 0, nil, c.fail(WebSocketCloseProtocolError, "invalid control frame")
+		}
+	} else if length > limit {
+		return false, 0, nil, c.fail(WebSocketCloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, c.readError(err)
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, c.readError(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, op, payload, nil
+}
+
+// readError closes the connection after a failed read. Reads from a connection
+// closed by this side report ErrWebSocketClosed.
+func (c *WebSocketConn) readError(err error) error {
+	select {
+	case <-c.done:
+		return ErrWebSocketClosed
+	default:
+	}
+	c.closeConn()
+	return err
+}
+
+// handleClose answers a close frame from the peer and closes the connection.
+func (c *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &WebSocketCloseError{Code: WebSocketCloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return c.fail(WebSocketCloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(WebSocketCloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(WebSocketCloseInvalidPayload, "invalid UTF-8")
+		}
+	}
+
+	echo := payload
+	if len(echo) > 2 {
+		echo = echo[:2]
+	}
+	c.writeFrame(opClose, echo)
+	c.closeConn()
+	return closeErr
+}
+
+// fail closes the connection with code after a protocol violation.
+func (c *WebSocketConn) fail(code int, reason string) error {
+	c.Close(code, reason)
+	return &WebSocketCloseError{Code: code, Reason: reason}
+}
+
+// writeFrame writes an unmasked frame with the FIN bit set.
+func (c *WebSocketConn) writeFrame(op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+	if op == opClose {
+		c.closeSent = true
+	}
+
+	var header [10]byte
+	header[0] = 0x80 | op
+	n := 2
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+		n = 4
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+		n = 10
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.config.WriteTimeout))
+	c.bw.Write(header[:n])
+	c.bw.Write(payload)
+	if err := c.bw.Flush(); err != nil {
+		c.closeSent = true
+		return err
+	}
+	return nil
+}
+
+// pingLoop sends pings until the connection is closed.
+func (c *WebSocketConn) pingLoop() {
+	ticker := time.NewTicker(c.config.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if c.Ping(nil) != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// closeConn closes the underlying connection once.
+func (c *WebSocketConn) closeConn() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// originAllowed reports whether the request's origin may open a connection.
+func (config WebSocketConfig) originAllowed(r *http.Request) bool {
+	if config.CheckOrigin != nil {
+		return config.CheckOrigin(r)
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(config.AllowedOrigins) > 0 {
+		return slices.ContainsFunc(config.AllowedOrigins, func(o string) bool {
+			return o == "*" || strings.EqualFold(o, origin)
+		})
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// isWebSocketUpgrade reports whether the request asks to upgrade to WebSocket.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// headerHasToken reports whether the comma-separated header contains token.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for t := range strings.SplitSeq(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// selectSubprotocol returns the first subprotocol of supported, which is in
+// order of the server's preference, that the client offers, or "".
+func selectSubprotocol(r *http.Request, supported []string) string {
+	var offered []string
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for p := range strings.SplitSeq(value, ",") {
+			offered = append(offered, strings.TrimSpace(p))
+		}
+	}
+	for _, p := range supported {
+		if slices.Contains(offered, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// webSocketAccept computes the Sec-WebSocket-Accept value for a client key.
+func webSocketAccept(key string) string {
+	h := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// validCloseCode reports whether code may be sent in a close frame.
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 3000 && code <= 4999:
+		return true
+	case code < 1000 || code > 1014:
+		return false
+	}
+	return code != 1004 && code != 1005 && code != 1006
+}
@@ -0,0 +1,359 @@
+package nova
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal WebSocket client for tests.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+// dialWebSocket performs the handshake against srv with extra request headers.
+func dialWebSocket(t *testing.T, srv *httptest.Server, path string, header http.Header) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", srv.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsClient{t: t, conn: conn, br: br, resp: resp}
+}
+
+// send writes a masked frame.
+func (c *wsClient) send(fin bool, op byte, payload []byte) {
+	c.t.Helper()
+	b0 := op
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// recv reads an unmasked server frame.
+func (c *wsClient) recv() (op byte, payload []byte) {
+	c.t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		c.t.Fatalf("reading frame: %v", err)
+	}
+	n := int(header[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		n = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatalf("reading payload: %v", err)
+	}
+	return header[0] & 0x0f, payload
+}
+
+// expectClose reads a close frame and checks its code.
+func (c *wsClient) expectClose(code int) {
+	c.t.Helper()
+	op, payload := c.recv()
+	if op != opClose || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		c.t.Fatalf("got frame %#x %q; want close %d", op, payload, code)
+	}
+}
+
+// closePayload returns a close frame payload.
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+// newEchoRouter returns a router with an echo endpoint that reports the error
+// that ended it on errs.
+func newEchoRouter(errs chan<- error) *Router {
+	r := NewRouter()
+	r.SetWebSocketConfig(WebSocketConfig{MaxMessageSize: 16, Subprotocols: []string{"chat"}})
+	r.WebSocket("/ws/{room}", func(conn *WebSocketConn) error {
+		if err := conn.WriteMessage(WebSocketText, []byte("room "+r.URLParam(conn.Request(), "room"))); err != nil {
+			return err
+		}
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return err
+			}
+			if err := conn.WriteMessage(typ, data); err != nil {
+				return err
+			}
+		}
+	})
+	return r
+}
+
+// TestWebSocketEcho tests the handshake, messages, fragmentation, pings and the
+// close handshake.
+func TestWebSocketEcho(t *testing.T) {
+	errs := make(chan error, 1)
+	srv := httptest.NewServer(newEchoRouter(errs))
+	defer srv.Close()
+
+	c := dialWebSocket(t, srv, "/ws/lobby", http.Header{"Sec-Websocket-Protocol": {"other, chat"}})
+	if c.resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d; want 101", c.resp.StatusCode)
+	}
+	if got := c.resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := c.resp.Header.Get("Sec-WebSocket-Protocol"); got != "chat" {
+		t.Errorf("Sec-WebSocket-Protocol = %q; want chat", got)
+	}
+	if op, payload := c.recv(); op != opText || string(payload) != "room lobby" {
+		t.Fatalf("greeting = %#x %q", op, payload)
+	}
+
+	c.send(true, opText, []byte("hello"))
+	if op, payload := c.recv(); op != opText || string(payload) != "hello" {
+		t.Errorf("echo = %#x %q", op, payload)
+	}
+
+	c.send(false, opBinary, []byte("frag"))
+	c.send(true, opPing, []byte("p"))
+	if op, payload := c.recv(); op != opPong || string(payload) != "p" {
+		t.Errorf("pong = %#x %q", op, payload)
+	}
+	c.send(true, opContinuation, []byte("ment"))
+	if op, payload := c.recv(); op != opBinary || string(payload) != "fragment" {
+		t.Errorf("fragmented echo = %#x %q", op, payload)
+	}
+
+	c.send(true, opClose, closePayload(WebSocketCloseGoingAway, "bye"))
+	c.expectClose(WebSocketCloseGoingAway)
+	var ce *WebSocketCloseError
+	if err := <-errs; !errors.As(err, &ce) || ce.Code != WebSocketCloseGoingAway || ce.Reason != "bye" {
+		t.Errorf("ReadMessage error = %v; want close 1001 bye", err)
+	}
+}
+
+// TestWebSocketProtocolErrors tests that violations close the connection with
+// the matching close code.
+func TestWebSocketProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		send func(c *wsClient)
+		code int
+	}{
+		{"too big", func(c *wsClient) { c.send(true, opText, []byte(strings.Repeat("x", 17))) }, WebSocketCloseMessageTooBig},
+		{"too big fragmented", func(c *wsClient) {
+			c.send(false, opText, []byte(strings.Repeat("x", 10)))
+			c.send(true, opContinuation, []byte(strings.Repeat("x", 10)))
+		}, WebSocketCloseMessageTooBig},
+		{"invalid utf-8", func(c *wsClient) { c.send(true, opText, []byte{0xff}) }, WebSocketCloseInvalidPayload},
+		{"unexpected continuation", func(c *wsClient) { c.send(true, opContinuation, []byte("x")) }, WebSocketCloseProtocolError},
+		{"reserved opcode", func(c *wsClient) { c.send(true, 0x3, nil) }, WebSocketCloseProtocolError},
+		{"invalid close code", func(c *wsClient) { c.send(true, opClose, closePayload(1005, "")) }, WebSocketCloseProtocolError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := make(chan error, 1)
+			srv := httptest.NewServer(newEchoRouter(errs))
+			defer srv.Close()
+
+			c := dialWebSocket(t, srv, "/ws/a", nil)
+			c.recv()
+			tc.send(c)
+			c.expectClose(tc.code)
+			var ce *WebSocketCloseError
+			if err := <-errs; !errors.As(err, &ce) || ce.Code != tc.code {
+				t.Errorf("ReadMessage error = %v; want close %d", err, tc.code)
+			}
+		})
+	}
+}
+
+// TestWebSocketHandshakeErrors tests rejected handshakes.
+func TestWebSocketHandshakeErrors(t *testing.T) {
+	r := NewRouter()
+	r.WebSocket("/ws", func(conn *WebSocketConn) error { return nil })
+	allowed := NewRouter()
+	allowed.SetWebSocketConfig(WebSocketConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	allowed.WebSocket("/ws", func(conn *WebSocketConn) error { return nil })
+
+	cases := []struct {
+		name       string
+		router     *Router
+		header     http.Header
+		wantStatus int
+	}{
+		{"same origin", r, http.Header{"Origin": {"http://example.com"}}, http.StatusSwitchingProtocols},
+		{"cross origin", r, http.Header{"Origin": {"https://evil.test"}}, http.StatusForbidden},
+		{"allowed origin", allowed, http.Header{"Origin": {"https://app.example.com"}}, http.StatusSwitchingProtocols},
+		{"disallowed origin", allowed, http.Header{"Origin": {"http://example.com"}}, http.StatusForbidden},
+		{"old version", r, http.Header{"Sec-Websocket-Version": {"8"}}, http.StatusUpgradeRequired},
+		{"bad key", r, http.Header{"Sec-Websocket-Key": {"short"}}, http.StatusBadRequest},
+		{"no upgrade", r, http.Header{"Upgrade": {"h2c"}}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/ws", nil)
+			req.Header.Set("Connection", "keep-alive, Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			req.Header.Set("Sec-WebSocket-Version", "13")
+			req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			for k, v := range c.header {
+				req.Header[k] = v
+			}
+			rr := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+			c.router.ServeHTTP(rr, req)
+			status := rr.Code
+			if rr.hijacked {
+				status = http.StatusSwitchingProtocols
+			}
+			if status != c.wantStatus {
+				t.Errorf("status = %d; want %d (body %q)", status, c.wantStatus, rr.Body.String())
+			}
+		})
+	}
+}
+
+// TestSelectSubprotocol tests that the server's order of preference decides
+// between the subprotocols the client offers.
+func TestSelectSubprotocol(t *testing.T) {
+	supported := []string{"v2.chat", "v1.chat"}
+	cases := []struct {
+		offered []string
+		want    string
+	}{
+		{[]string{"v1.chat, v2.chat"}, "v2.chat"},
+		{[]string{"v1.chat", "v2.chat"}, "v2.chat"},
+		{[]string{"other, v1.chat"}, "v1.chat"},
+		{[]string{"other"}, ""},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		req.Header["Sec-Websocket-Protocol"] = c.offered
+		if got := selectSubprotocol(req, supported); got != c.want {
+			t.Errorf("selectSubprotocol(%q) = %q; want %q", c.offered, got, c.want)
+		}
+	}
+}
+
+// hijackRecorder is a ResponseRecorder that can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+// Hijack returns one end of an in-memory connection.
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	server, client := net.Pipe()
+	go io.Copy(io.Discard, client)
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+// TestWebSocketMiddleware tests upgrades through middleware that wrap the
+// response writer.
+func TestWebSocketMiddleware(t *testing.T) {
+	cases := []struct {
+		name string
+		mw   func(logger *log.Logger) Middleware
+	}{
+		{"logging", func(l *log.Logger) Middleware { return LoggingMiddleware(&LoggingConfig{Logger: l}) }},
+		{"gzip", func(l *log.Logger) Middleware { return GzipMiddleware(&GzipConfig{Logger: l}) }},
+		{"etag", func(*log.Logger) Middleware { return ETagMiddleware(nil) }},
+		{"timeout", func(*log.Logger) Middleware {
+			return TimeoutMiddleware(TimeoutConfig{Duration: 50 * time.Millisecond})
+		}},
+		{"timeout handler", func(*log.Logger) Middleware {
+			return TimeoutMiddleware(TimeoutConfig{Duration: 50 * time.Millisecond, ProblemDetails: true})
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs strings.Builder
+			errs := make(chan error, 1)
+			r := newEchoRouter(errs)
+			r.Use(tc.mw(log.New(&logs, "", 0)))
+			served := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				defer close(served)
+				r.ServeHTTP(w, req)
+			}))
+			defer srv.Close()
+
+			c := dialWebSocket(t, srv, "/ws/a", http.Header{"Accept-Encoding": {"gzip"}})
+			if c.resp.StatusCode != http.StatusSwitchingProtocols {
+				t.Fatalf("status = %d; want 101", c.resp.StatusCode)
+			}
+			c.recv()
+			time.Sleep(100 * time.Millisecond) // outlive the timeout
+			c.send(true, opText, []byte("still here"))
+			if _, payload := c.recv(); string(payload) != "still here" {
+				t.Errorf("echo = %q", payload)
+			}
+			c.send(true, opClose, closePayload(WebSocketCloseNormal, ""))
+			c.expectClose(WebSocketCloseNormal)
+			<-errs
+			<-served
+
+			if strings.Contains(logs.String(), "ERROR") {
+				t.Errorf("middleware logged an error: %s", logs.String())
+			}
+			if tc.name == "logging" && !strings.Contains(logs.String(), "101 Switching Protocols") {
+				t.Errorf("log = %q; want status 101", logs.String())
+			}
+		})
+	}
+}